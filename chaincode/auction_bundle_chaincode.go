@@ -0,0 +1,149 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** AUCTION BUNDLES (suggested parcel groups for new auctions)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["SuggestAuctionBundles", "2", "30", "10", "24"]}'
+*/
+
+const (
+	// Time left to the courier between the end of the auction and the first required delivery date
+	bundleDeliveryLeadTime = 24 * time.Hour
+	// Shortest auction suggested
+	bundleMinimumAuctionDuration = 1 * time.Hour
+)
+
+type auctionBundle struct {
+	Parcels                  []models.AuctionHasParcel `json:"parcels"`
+	PickupPostalArea         string                    `json:"pickup_postal_area"`
+	DeliveryPostalArea       string                    `json:"delivery_postal_area"`
+	EarliestRequiredDelivery time.Time                 `json:"earliest_required_delivery_date"`
+	LatestRequiredDelivery   time.Time                 `json:"latest_required_delivery_date"`
+	TotalChargeableWeight    float64                   `json:"total_chargeable_weight"`
+	TotalVolumes             int                       `json:"total_volumes"`
+	TotalBitcircleReward     int                       `json:"total_bitcircle_reward"`
+	SuggestedStartDate       time.Time                 `json:"suggested_start_date"`
+	SuggestedEndDate         time.Time                 `json:"suggested_end_date"`
+}
+
+// The auction ends bundleDeliveryLeadTime before the first required delivery.
+// When there is not enough time for that it ends halfway to the delivery, and
+// never before bundleMinimumAuctionDuration.
+func getBundleEndDate(currentTime time.Time, earliestRequiredDelivery time.Time) time.Time {
+	endDate := earliestRequiredDelivery.Add(-bundleDeliveryLeadTime)
+	if endDate.Before(currentTime.Add(bundleMinimumAuctionDuration)) {
+		endDate = currentTime.Add(earliestRequiredDelivery.Sub(currentTime) / 2)
+	}
+	if endDate.Before(currentTime.Add(bundleMinimumAuctionDuration)) {
+		endDate = currentTime.Add(bundleMinimumAuctionDuration)
+	}
+	return endDate
+}
+
+// Groups the Pending parcels of the operator by pickup and delivery area. Parcels
+// of a group go in the same bundle while their required delivery dates are within
+// windowHours of the first one and the bundle stays under the weight (kg) and
+// volume limits. A parcel over the limits on its own gets its own bundle. Relay
+// parcels are left out, their legs are auctioned one by one.
+func (s *AuctionSmartContract) SuggestAuctionBundles(stub shim.ChaincodeStubInterface, logisticOperatorID int, maxWeight float64, maxVolumes int, windowHours int) pb.Response {
+	fmt.Println("SuggestAuctionBundles Invoke")
+	if !(maxWeight > 0) || !(maxVolumes > 0) || !(windowHours >= 0) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The maximum weight and volumes most be higher than 0 and the window most be higher or equal than 0"))
+	}
+
+	parcels, err := readParcelsWhere(stub, func(parcel models.Parcel) bool {
+		return parcel.LogisticOperatorId == logisticOperatorID && parcel.State == models.State(models.ParcelStatePending) && parcel.RelayLegs == 0
+	})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	for i := range parcels {
+		parcels[i].PickupPostalArea = normalizePostalArea(parcels[i].PickupPostalArea)
+		parcels[i].DeliveryPostalArea = normalizePostalArea(parcels[i].DeliveryPostalArea)
+		parcels[i].ComputeWeights()
+	}
+
+	// Parcels of the same route end up next to each other, by required delivery date
+	sort.SliceStable(parcels, func(i, j int) bool {
+		if parcels[i].PickupPostalArea != parcels[j].PickupPostalArea {
+			return parcels[i].PickupPostalArea < parcels[j].PickupPostalArea
+		}
+		if parcels[i].DeliveryPostalArea != parcels[j].DeliveryPostalArea {
+			return parcels[i].DeliveryPostalArea < parcels[j].DeliveryPostalArea
+		}
+		if !parcels[i].RequiredDeliveryDate.Equal(parcels[j].RequiredDeliveryDate) {
+			return parcels[i].RequiredDeliveryDate.Before(parcels[j].RequiredDeliveryDate)
+		}
+		return parcels[i].ID < parcels[j].ID
+	})
+
+	window := time.Duration(windowHours) * time.Hour
+	bundles := []auctionBundle{}
+	var bundle *auctionBundle
+	for _, parcel := range parcels {
+		fits := bundle != nil &&
+			bundle.PickupPostalArea == parcel.PickupPostalArea &&
+			bundle.DeliveryPostalArea == parcel.DeliveryPostalArea &&
+			parcel.RequiredDeliveryDate.Sub(bundle.EarliestRequiredDelivery) <= window &&
+			bundle.TotalChargeableWeight+parcel.ChargeableWeight <= maxWeight &&
+			bundle.TotalVolumes+parcel.Volumes <= maxVolumes
+
+		if !fits {
+			bundles = append(bundles, auctionBundle{
+				PickupPostalArea:         parcel.PickupPostalArea,
+				DeliveryPostalArea:       parcel.DeliveryPostalArea,
+				EarliestRequiredDelivery: parcel.RequiredDeliveryDate,
+			})
+			bundle = &bundles[len(bundles)-1]
+		}
+
+		bundle.Parcels = append(bundle.Parcels, models.AuctionHasParcel{ParcelID: parcel.ID})
+		bundle.LatestRequiredDelivery = parcel.RequiredDeliveryDate
+		bundle.TotalChargeableWeight = models.RoundWeight(bundle.TotalChargeableWeight + parcel.ChargeableWeight)
+		bundle.TotalVolumes = bundle.TotalVolumes + parcel.Volumes
+		bundle.TotalBitcircleReward = bundle.TotalBitcircleReward + parcel.BitcircleReward
+	}
+
+	// Suggested auction IDs, so the parcel lists can be sent to ParcelDeliveryAuctionStart as they are
+	for i := range bundles {
+		auctionID := fmt.Sprintf("%s-bundle-%d", stub.GetTxID(), i+1)
+		for j := range bundles[i].Parcels {
+			bundles[i].Parcels[j].AuctionID = auctionID
+		}
+		bundles[i].SuggestedStartDate = currentTime
+		bundles[i].SuggestedEndDate = getBundleEndDate(currentTime, bundles[i].EarliestRequiredDelivery)
+	}
+
+	bundlesJSON, err := json.Marshal(bundles)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(bundlesJSON)
+}
+
+// ** -----------------------------------------------------
+// ** AUCTION BUNDLES (suggested parcel groups for new auctions)
+// ** -> END
+// ** -----------------------------------------------------
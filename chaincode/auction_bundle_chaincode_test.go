@@ -0,0 +1,127 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBundleEndDate(t *testing.T) {
+	tests := []struct {
+		name                     string
+		earliestRequiredDelivery time.Duration
+		endDate                  time.Duration
+	}{
+		{"a day before the delivery", 48 * time.Hour, 24 * time.Hour},
+		{"halfway to a delivery within a day", 24 * time.Hour, 12 * time.Hour},
+		{"the shortest auction", 90 * time.Minute, time.Hour},
+		{"delivery already late", -time.Hour, time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			endDate := getBundleEndDate(testStartTime, testStartTime.Add(test.earliestRequiredDelivery))
+			require.Equal(t, testStartTime.Add(test.endDate), endDate)
+		})
+	}
+}
+
+func TestSuggestAuctionBundles(t *testing.T) {
+	c := newTestContract(t)
+	for _, parcel := range []struct {
+		id                 int
+		deliveryPostalArea string
+		requiredDelivery   time.Duration
+		weight             float64
+		logisticOperatorID int
+	}{
+		{1, "2000", 48 * time.Hour, 2, testOperatorID},
+		{2, "2000", 60 * time.Hour, 2, testOperatorID},
+		{3, "2000", 80 * time.Hour, 2, testOperatorID},
+		{4, "3000", 48 * time.Hour, 2, testOperatorID},
+		{5, "2000", 49 * time.Hour, 40, testOperatorID},
+		{6, "2000", 48 * time.Hour, 2, 8},
+	} {
+		c.parcel(parcel.id, map[string]interface{}{
+			"delivery_postal_area":   parcel.deliveryPostalArea,
+			"required_delivery_date": testStartTime.Add(parcel.requiredDelivery),
+			"weight":                 parcel.weight,
+			"logistic_operator_id":   parcel.logisticOperatorID,
+		})
+	}
+
+	tests := []struct {
+		name               string
+		logisticOperatorID int
+		maxWeight          string
+		maxVolumes         string
+		windowHours        string
+		bundles            [][]int
+	}{
+		{"within the limits and the window", testOperatorID, "100", "10", "24", [][]int{{1, 5, 2}, {3}, {4}}},
+		{"over the weight on its own", testOperatorID, "30", "10", "24", [][]int{{1}, {5}, {2, 3}, {4}}},
+		{"volume limit", testOperatorID, "100", "2", "24", [][]int{{1, 5}, {2, 3}, {4}}},
+		{"no window", testOperatorID, "100", "10", "0", [][]int{{1}, {5}, {2}, {3}, {4}}},
+		{"parcels of the operator only", 8, "100", "10", "24", [][]int{{6}}},
+		{"operator without parcels", 7, "100", "10", "24", [][]int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var bundles []auctionBundle
+			c.ok(&bundles, "SuggestAuctionBundles", fmt.Sprint(test.logisticOperatorID), test.maxWeight, test.maxVolumes, test.windowHours)
+
+			parcelIDs := [][]int{}
+			for _, bundle := range bundles {
+				var ids []int
+				for _, auctionHasParcel := range bundle.Parcels {
+					require.Equal(t, bundle.Parcels[0].AuctionID, auctionHasParcel.AuctionID)
+					ids = append(ids, auctionHasParcel.ParcelID)
+				}
+				parcelIDs = append(parcelIDs, ids)
+			}
+			require.Equal(t, test.bundles, parcelIDs)
+		})
+	}
+
+	c.fails(http.StatusBadRequest, "SuggestAuctionBundles", fmt.Sprint(testOperatorID), "0", "10")
+	c.fails(http.StatusBadRequest, "SuggestAuctionBundles", fmt.Sprint(testOperatorID), "100", "10", "-1")
+}
+
+func TestSubmitSuggestedAuctionBundle(t *testing.T) {
+	c := newTestContract(t).parcel(1, nil).parcel(2, nil)
+
+	var bundles []auctionBundle
+	c.ok(&bundles, "SuggestAuctionBundles", fmt.Sprint(testOperatorID), "100", "10")
+	require.Len(t, bundles, 1)
+	bundle := bundles[0]
+	require.Equal(t, 4.0, bundle.TotalChargeableWeight)
+	require.Equal(t, 2, bundle.TotalVolumes)
+	require.Equal(t, 20, bundle.TotalBitcircleReward)
+	require.Equal(t, bundle.EarliestRequiredDelivery.Add(-bundleDeliveryLeadTime), bundle.SuggestedEndDate)
+
+	// The parcel list is sent as it is to start the auction
+	c.ok(nil, "ParcelDeliveryAuctionStart", toJSON(t, bundle.Parcels), toJSON(t, map[string]interface{}{
+		"id":                          bundle.Parcels[0].AuctionID,
+		"start_date":                  bundle.SuggestedStartDate,
+		"end_date":                    bundle.SuggestedEndDate,
+		"maximum_accepted_licitation": 10000,
+		"currency":                    "EUR",
+		"participant_id":              testOperatorID,
+	}))
+
+	var parcel models.Parcel
+	for _, id := range []string{"1", "2"} {
+		require.True(t, c.entity(EntityParcel, &parcel, id))
+		require.EqualValues(t, models.ParcelStateAuction, parcel.State)
+		require.Equal(t, bundle.Parcels[0].AuctionID, parcel.AuctionId)
+	}
+
+	// Parcels on auction are not suggested again
+	c.ok(&bundles, "SuggestAuctionBundles", fmt.Sprint(testOperatorID), "100", "10")
+	require.Empty(t, bundles)
+}
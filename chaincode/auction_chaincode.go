@@ -20,7 +20,7 @@ func validateAuction(auction models.Auction) error {
 		errorMessages = append(errorMessages, "ID cannot be empty")
 	}
 
-	if auction.State != models.AuctionState(models.AuctionOpen) {
+	if auction.State != "" && auction.State != models.AuctionOpen {
 		errorMessages = append(errorMessages, "Auction State when create most be 'OPEN'")
 	}
 
@@ -32,6 +32,29 @@ func validateAuction(auction models.Auction) error {
 		errorMessages = append(errorMessages, "MaximumAmount Higher than 0")
 	}
 
+	if !models.IsCurrency(auction.Currency) {
+		errorMessages = append(errorMessages, fmt.Sprint("Unsupported Currency ", auction.Currency))
+	}
+
+	if auction.Type != "" && auction.Type != models.AuctionTypeStandard && auction.Type != models.AuctionTypeClock {
+		errorMessages = append(errorMessages, "Invalid Auction Type, most be 'STANDARD' or 'CLOCK'")
+	}
+
+	if auction.Type == models.AuctionTypeClock {
+		if !(auction.ClockStartPrice > 0) {
+			errorMessages = append(errorMessages, "ClockStartPrice Higher than 0")
+		}
+		if auction.ClockStartPrice > auction.MaximumAcceptedLicitation {
+			errorMessages = append(errorMessages, "ClockStartPrice cannot be higher than MaximumAcceptedLicitation")
+		}
+		if !(auction.ClockStepAmount > 0) {
+			errorMessages = append(errorMessages, "ClockStepAmount Higher than 0")
+		}
+		if !(auction.ClockStepMinutes > 0) {
+			errorMessages = append(errorMessages, "ClockStepMinutes Higher than 0")
+		}
+	}
+
 	if len(errorMessages) > 0 {
 		return errors.New(strings.Join(errorMessages, "\n"))
 	}
@@ -63,12 +86,24 @@ func GetAuctions(stub shim.ChaincodeStubInterface) ([]models.Auction, error) {
 	return auctions, nil
 }
 
+// Invite-only auctions are hidden from callers that cannot see them
 func (s *AuctionSmartContract) GetLastAuctions(stub shim.ChaincodeStubInterface) ([]models.Auction, error) {
-	allAuctions, err := GetAuctions(stub)
+	auctions, err := GetAuctions(stub)
 	if err != nil {
 		return nil, err
 	}
 
+	allAuctions := make([]models.Auction, 0)
+	for _, auction := range auctions {
+		visible, err := auctionIsVisibleToCaller(stub, auction)
+		if err != nil {
+			return nil, err
+		}
+		if visible {
+			allAuctions = append(allAuctions, auction)
+		}
+	}
+
 	lastAuctions := make([]models.Auction, 0)
 
 	// Sort bids by date in descending order
@@ -133,6 +168,10 @@ func (s *AuctionSmartContract) ParcelDeliveryAuctionStart(stub shim.ChaincodeStu
 		}
 	}()
 
+	if auction.Type == "" {
+		auction.Type = models.AuctionTypeStandard
+	}
+
 	// Validate auction
 	err = validateAuction(auction)
 	if err != nil {
@@ -145,7 +184,24 @@ func (s *AuctionSmartContract) ParcelDeliveryAuctionStart(stub shim.ChaincodeStu
 		return shim.Success(createErrorResponse(http.StatusBadRequest, "No parcel selected for the auction. Please choose a parcel to proceed."))
 	}
 
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{fmt.Sprint(auction.ID)})
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	auctionExists, err := s.EntityRecordExists(stub, auctionKey)
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if auctionExists {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusConflict, "Record Already Exists"))
+	}
+
 	var auctionParcels []int
+	auctionParcelsSeen := map[int]bool{}
 	// Process parcels
 	for _, auctionHasParcel := range parcels {
 		// Check if ParcelExists
@@ -169,19 +225,33 @@ func (s *AuctionSmartContract) ParcelDeliveryAuctionStart(stub shim.ChaincodeStu
 		// 	return shim.Success(createErrorResponse(http.StatusInternalServerError, fmt.Sprint("The parcel with id ", auctionHasParcel.ParcelID, " do not belong to current user")))
 		// }
 
-		if parcel.State != models.State(models.ParcelStatePending) {
+		if auctionParcelsSeen[auctionHasParcel.ParcelID] {
 			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, fmt.Sprint("The parcel with id ", auctionHasParcel.ParcelID, " is not on 'Pending' state.")))
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", auctionHasParcel.ParcelID, " is more than once on the auction")))
 		}
+		auctionParcelsSeen[auctionHasParcel.ParcelID] = true
+
+		// Relay parcels are auctioned one leg at a time
+		if auctionHasParcel.Leg != 0 || parcel.RelayLegs != 0 {
+			err = s.startParcelLegAuction(stub, &parcel, auctionHasParcel.Leg, auction.ID)
+			if err != nil {
+				transactionError = true
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+		} else {
+			if parcel.State != models.State(models.ParcelStatePending) {
+				transactionError = true
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, fmt.Sprint("The parcel with id ", auctionHasParcel.ParcelID, " is not on 'Pending' state.")))
+			}
 
-		parcel.State = models.State(models.ParcelStateAuction)
-		dataParcel, err := json.Marshal(parcel)
+			parcel.State = models.State(models.ParcelStateAuction)
+			parcel.AuctionId = auction.ID
+		}
+		_, err = s.putParcel(stub, parcelKey, &parcel, auction.ParticipantId)
 		if err != nil {
 			transactionError = true
 			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 		}
-
-		s.UpsertEntityRecord(stub, parcelKey, dataParcel)
 		// Create and store auctionHasParcel entity
 		var auctionHasParcelKey string
 		auctionHasParcelKey, err = s.CreateCompositeKey(stub, EntityAuctionHasParcel, []string{fmt.Sprint(auction.ID), fmt.Sprint(auctionHasParcel.ParcelID)})
@@ -204,21 +274,21 @@ func (s *AuctionSmartContract) ParcelDeliveryAuctionStart(stub shim.ChaincodeStu
 		auctionParcels = append(auctionParcels, auctionHasParcel.ParcelID)
 	}
 
-	// Create and store auction entity
-	var auctionKey string
-	auctionKey, err = s.CreateCompositeKey(stub, EntityAuction, []string{fmt.Sprint(auction.ID)})
+	// Auctions starting in the future are scheduled and opened later by OpenScheduledAuctions
+	currentTime, err := getTxTime(stub)
 	if err != nil {
 		transactionError = true
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
 
-	dataAuction, err := json.Marshal(auction)
-	if err != nil {
-		transactionError = true
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	initialState := models.AuctionOpen
+	if auction.StartDate.After(currentTime) {
+		initialState = models.AuctionScheduled
 	}
 
-	_, err = s.UpsertEntityRecord(stub, auctionKey, dataAuction)
+	auction.State = ""
+	auction.TransitionCount = 0
+	err = s.transitionAuction(stub, &auction, initialState, auction.ParticipantId, "Auction created")
 	if err != nil {
 		transactionError = true
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
@@ -242,23 +312,14 @@ func (s *AuctionSmartContract) ParcelDeliveryAuctionStart(stub shim.ChaincodeStu
 }
 
 func (s *AuctionSmartContract) GetAuctionByID(stub shim.ChaincodeStubInterface, id string) pb.Response {
-	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{fmt.Sprint(id)})
-	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
-	}
-
-	auctionJSON, err := s.ReadEntity(stub, auctionKey)
-	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
-	}
-
 	var response struct {
 		Auction models.Auction `json:"auction"`
 		Parcels []int          `json:"parcels"`
 		Bids    []models.Bid   `json:"bids"`
 	}
 
-	err = json.Unmarshal(auctionJSON, &response.Auction)
+	var err error
+	response.Auction, err = s.readVisibleAuction(stub, id)
 	if err != nil {
 		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
 	}
@@ -287,6 +348,7 @@ func (s *AuctionSmartContract) GetAuctionByID(stub shim.ChaincodeStubInterface,
 	return shim.Success(responseJSON)
 }
 
+// Invite-only auctions are hidden from callers that cannot see them
 func (s *AuctionSmartContract) GetAuctionByParcelID(stub shim.ChaincodeStubInterface, parcelId int) pb.Response {
 	parcelKey, err := s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(parcelId)})
 
@@ -334,6 +396,14 @@ func (s *AuctionSmartContract) GetAuctionByParcelID(stub shim.ChaincodeStubInter
 			return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
 		}
 
+		visible, err := auctionIsVisibleToCaller(stub, responseItem.Auction)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		if !visible {
+			continue
+		}
+
 		responseItem.WinningBid, err = getWinningBidForAuction(stub, responseItem.Auction.ID)
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
@@ -355,6 +425,7 @@ func (s *AuctionSmartContract) GetAuctionByParcelID(stub shim.ChaincodeStubInter
 	return shim.Success(responseJSON)
 }
 
+// Invite-only auctions are hidden from callers that cannot see them (see auctionIsVisibleToCaller)
 func (s *AuctionSmartContract) ReadAuctions(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("ReadAuctions Invoke")
 
@@ -390,6 +461,14 @@ func (s *AuctionSmartContract) ReadAuctions(stub shim.ChaincodeStubInterface) pb
 			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 		}
 
+		visible, err := auctionIsVisibleToCaller(stub, responseItem.Auction)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		if !visible {
+			continue
+		}
+
 		responseItem.Parcels, err = getParcelsForAuction(stub, responseItem.Auction.ID)
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
@@ -418,6 +497,33 @@ func (s *AuctionSmartContract) ReadAuctions(stub shim.ChaincodeStubInterface) pb
 	return shim.Success(responseJSON)
 }
 
+// Parcels of the auction with the leg auctioned, for relay parcels
+func getAuctionHasParcels(stub shim.ChaincodeStubInterface, auctionID string) ([]models.AuctionHasParcel, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityAuctionHasParcel), []string{auctionID})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	var auctionHasParcels []models.AuctionHasParcel
+	for iterator.HasNext() {
+		responseData, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var auctionHasParcel models.AuctionHasParcel
+		err = json.Unmarshal(responseData.Value, &auctionHasParcel)
+		if err != nil {
+			return nil, err
+		}
+
+		auctionHasParcels = append(auctionHasParcels, auctionHasParcel)
+	}
+
+	return auctionHasParcels, nil
+}
+
 // ! Mudar para de ficheiro
 func getParcelsForAuction(stub shim.ChaincodeStubInterface, auctionID string) ([]int, error) {
 	// Create iterator for all auctionHasParcel entities with the given auctionID
@@ -516,7 +622,12 @@ func (s *AuctionSmartContract) ReadAuctionsByState(stub shim.ChaincodeStubInterf
 			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 		}
 
-		if responseItem.Auction.State == models.AuctionState(state) {
+		visible, err := auctionIsVisibleToCaller(stub, responseItem.Auction)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		if responseItem.Auction.State == models.AuctionState(state) && visible {
 
 			responseItem.Parcels, err = getParcelsForAuction(stub, responseItem.Auction.ID)
 			if err != nil {
@@ -627,8 +738,11 @@ func (s *AuctionSmartContract) ListOfExpiredAuctions(stub shim.ChaincodeStubInte
 	}
 	defer iterator.Close()
 
-	// Get the current time
-	currentTime := getCurrentTime()
+	// Get the current time, the one CloseExpiredAuctions checks the auctions against
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
 
 	var response []string
 
@@ -648,7 +762,7 @@ func (s *AuctionSmartContract) ListOfExpiredAuctions(stub shim.ChaincodeStubInte
 		}
 
 		// Check if the auction's EndDate is before the current time
-		if auction.EndDate.Before(currentTime) && auction.State == models.AuctionState(models.AuctionOpen) {
+		if auction.EndDate.Before(currentTime) && auction.State == models.AuctionOpen {
 			response = append(response, auction.ID)
 		}
 	}
@@ -661,15 +775,15 @@ func (s *AuctionSmartContract) ListOfExpiredAuctions(stub shim.ChaincodeStubInte
 	return shim.Success(res)
 }
 
+// Closes an auction whose end date has passed and awards it to its lowest bid.
+// A failure once the auction moved fails the transaction, none of its writes
+// are committed.
 func (s *AuctionSmartContract) CloseExpiredAuctions(stub shim.ChaincodeStubInterface, auctionId string) pb.Response {
 	fmt.Println("CloseExpiredAuctions Invoke")
 	responseItem := struct {
-		AuctionID string `json:"auction"`
-		Deliverer int    `json:"deliverer_id"`
-		Parcels   []struct {
-			ID    int           `json:"id"`
-			State models.Status `json:"state"`
-		} `json:"parcels"`
+		AuctionID string               `json:"auction"`
+		Deliverer int                  `json:"deliverer_id"`
+		Parcels   []auctionParcelState `json:"parcels"`
 	}{}
 
 	responseItem.AuctionID = auctionId
@@ -692,6 +806,15 @@ func (s *AuctionSmartContract) CloseExpiredAuctions(stub shim.ChaincodeStubInter
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
 
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if !auction.EndDate.Before(currentTime) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The auction ", auction.ID, " has not expired yet")))
+	}
+
 	// Check if there are any bids for the auction
 	bids, err := getBidsForAuction(stub, auction.ID)
 	if err != nil {
@@ -701,126 +824,148 @@ func (s *AuctionSmartContract) CloseExpiredAuctions(stub shim.ChaincodeStubInter
 
 	fmt.Println("BIDS: ", bids)
 
+	// Only the current lowest bid wins, an auction whose lowest bid was deleted
+	// closes as one without bids
+	var winnerBid models.Bid
+	hasWinner := false
+	for _, bid := range bids {
+		if bid.Status == models.BitStatusLowerBid {
+			winnerBid = bid
+			hasWinner = true
+			break
+		}
+	}
+
 	// Update the auction's state based on the presence of bids
-	if len(bids) == 0 {
-		auction.State = models.AuctionState(models.AuctionClosedNoBids)
+	if !hasWinner {
+		err = s.transitionAuction(stub, &auction, models.AuctionClosedNoBids, PlatformWalletId, "Auction expired without bids")
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+		}
 	} else {
-		auction.State = models.AuctionState(models.AuctionClosedBids)
-		var winnerBid models.Bid
-		for _, bid := range bids {
-			if bid.Status == models.BitStatusLowerBid {
-				winnerBid = bid
-				break
-			}
+		err = s.transitionAuction(stub, &auction, models.AuctionClosedBids, PlatformWalletId, "Auction expired")
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 		}
 
 		description := fmt.Sprint("Auction ", auction.ID, " payment.")
-		err = s.TransferBitcirclesBetweenWallets(stub, winnerBid.CourierID, 0, winnerBid.BitcircleAmount, false, description)
+		err = s.captureEscrow(stub, winnerBid, PlatformWalletId, description)
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return shim.Error(err.Error())
 		}
 
 		winnerBid.Winner = true
 
 		winnerBidKey, err := s.CreateCompositeKey(stub, EntityBid, []string{fmt.Sprint(winnerBid.ID), fmt.Sprint(auction.ID)})
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return shim.Error(err.Error())
 		}
 
 		dataWinnerBid, err := json.Marshal(winnerBid)
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return shim.Error(err.Error())
 		}
 
 		_, err = s.UpsertEntityRecord(stub, winnerBidKey, dataWinnerBid)
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return shim.Error(err.Error())
 		}
 		// Set Deliverer
 		responseItem.Deliverer = winnerBid.CourierID
+
+		err = s.recordSettlement(stub, auction, winnerBid)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = s.transitionAuction(stub, &auction, models.AuctionAwarded, PlatformWalletId, fmt.Sprint("Awarded to courier ", winnerBid.CourierID))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
 	}
 
-	// Convert the updated auction to JSON
-	dataAuction, err := json.Marshal(auction)
+	responseItem.AuctionID = auction.ID
+
+	// Update the state of each parcel of the auction
+	parcelState := models.ParcelStateDelivery
+	if !hasWinner {
+		parcelState = models.ParcelStatePending
+	}
+	responseItem.Parcels, err = s.updateAuctionParcelsState(stub, auction.ID, parcelState, responseItem.Deliverer, PlatformWalletId)
 	if err != nil {
-		// transactionError = true
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		return shim.Error(err.Error())
 	}
 
-	// Update the auction record in the ledger within the transaction
-	_, err = s.UpsertEntityRecord(stub, auctionKey, dataAuction)
+	res, err := json.Marshal(responseItem)
 	if err != nil {
 		// transactionError = true
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
+	return shim.Success(res)
+}
 
-	responseItem.AuctionID = auction.ID
+type auctionParcelState struct {
+	ID    int           `json:"id"`
+	Leg   int           `json:"leg,omitempty"`
+	State models.Status `json:"state"`
+}
 
-	// Get the parcels associated with the auction
-	parcels, err := getParcelsForAuction(stub, auction.ID)
+// Move every parcel of the auction to the given state. The parcels are assigned
+// to the courier, or unassigned when courierID is 0. For relay parcels only the
+// auctioned leg moves, and the parcel when it is its current leg.
+func (s *AuctionSmartContract) updateAuctionParcelsState(stub shim.ChaincodeStubInterface, auctionID string, state models.Status, courierID int, actorID int) ([]auctionParcelState, error) {
+	auctionHasParcels, err := getAuctionHasParcels(stub, auctionID)
 	if err != nil {
-		// transactionError = true
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		return nil, err
 	}
 
-	// Update the state of each parcel to "Closed"
-	for _, parcelID := range parcels {
-		newParcel := struct {
-			ID    int           `json:"id"`
-			State models.Status `json:"state"`
-		}{}
-		parcelKey, err := s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(parcelID)})
+	var updatedParcels []auctionParcelState
+	for _, auctionHasParcel := range auctionHasParcels {
+		parcelKey, err := s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(auctionHasParcel.ParcelID)})
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return nil, err
 		}
 
 		entity, err := s.ReadEntity(stub, parcelKey)
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return nil, err
 		}
 
 		var parcel models.Parcel
 		err = json.Unmarshal(entity, &parcel)
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return nil, err
 		}
 
-		newParcel.ID = parcel.ID
-		// Update the parcel's state to "Closed"
-		if len(bids) == 0 {
-			parcel.State = models.State(models.ParcelStatePending)
-			newParcel.State = models.ParcelStatePending
-		} else {
-			parcel.State = models.State(models.ParcelStateDelivery)
-			newParcel.State = models.ParcelStateDelivery
+		if auctionHasParcel.Leg != 0 {
+			isCurrentLeg, err := s.updateParcelLegState(stub, parcel, auctionHasParcel.Leg, auctionID, state, courierID)
+			if err != nil {
+				return nil, err
+			}
+			if !isCurrentLeg {
+				updatedParcels = append(updatedParcels, auctionParcelState{ID: parcel.ID, Leg: auctionHasParcel.Leg, State: state})
+				continue
+			}
 		}
 
-		dataParcel, err := json.Marshal(parcel)
-		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		parcel.State = models.State(state)
+		parcel.AssignedCourierId = courierID
+		parcel.AssignedAuctionId = ""
+		if courierID != 0 {
+			parcel.AssignedAuctionId = auctionID
+		}
+		parcel.AuctionId = auctionID
+		if state == models.ParcelStatePending {
+			parcel.AuctionId = ""
 		}
 
 		// Update the parcel record in the ledger within the transaction
-		_, err = s.UpsertEntityRecord(stub, parcelKey, dataParcel)
+		_, err = s.putParcel(stub, parcelKey, &parcel, actorID)
 		if err != nil {
-			// transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return nil, err
 		}
-		responseItem.Parcels = append(responseItem.Parcels, newParcel)
+		updatedParcels = append(updatedParcels, auctionParcelState{ID: parcel.ID, Leg: auctionHasParcel.Leg, State: state})
 	}
 
-	res, err := json.Marshal(responseItem)
-	if err != nil {
-		// transactionError = true
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
-	}
-	return shim.Success(res)
+	return updatedParcels, nil
 }
@@ -0,0 +1,253 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** AUCTION STATE MACHINE
+// ** -> START
+// ** -----------------------------------------------------
+
+// Allowed moves between auction states. The empty state is an auction that
+// does not exist yet.
+var auctionTransitions = map[models.AuctionState][]models.AuctionState{
+	"":                         {models.AuctionScheduled, models.AuctionOpen},
+	models.AuctionScheduled:    {models.AuctionOpen, models.AuctionCancelled},
+	models.AuctionOpen:         {models.AuctionClosedBids, models.AuctionClosedNoBids, models.AuctionAwarded, models.AuctionCancelled},
+	models.AuctionClosedBids:   {models.AuctionAwarded, models.AuctionCancelled},
+	models.AuctionAwarded:      {models.AuctionSettled},
+	models.AuctionClosedNoBids: {},
+	models.AuctionSettled:      {},
+	models.AuctionCancelled:    {},
+}
+
+func canTransitionAuction(from models.AuctionState, to models.AuctionState) bool {
+	for _, state := range auctionTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// States of an auction that has a winning bid
+func auctionHasWinner(state models.AuctionState) bool {
+	return state == models.AuctionClosedBids || state == models.AuctionAwarded || state == models.AuctionSettled
+}
+
+// Every auction write goes through here: the move is checked against the
+// transition table, the auction is stored and the transition is logged.
+// The auction passed in is updated, so several transitions can be chained in
+// the same transaction.
+func (s *AuctionSmartContract) transitionAuction(stub shim.ChaincodeStubInterface, auction *models.Auction, to models.AuctionState, actorID int, reason string) error {
+	if !canTransitionAuction(auction.State, to) {
+		return fmt.Errorf("Auction %s cannot move from '%s' to '%s'", auction.ID, auction.State, to)
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	auction.TransitionCount = auction.TransitionCount + 1
+	transition := models.AuctionTransition{
+		AuctionID: auction.ID,
+		Sequence:  auction.TransitionCount,
+		From:      auction.State,
+		To:        to,
+		ActorID:   actorID,
+		Date:      currentTime,
+		Reason:    reason,
+		TxID:      stub.GetTxID(),
+	}
+	auction.State = to
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auction.ID})
+	if err != nil {
+		return err
+	}
+
+	dataAuction, err := json.Marshal(auction)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, auctionKey, dataAuction)
+	if err != nil {
+		return err
+	}
+
+	transitionKey, err := s.CreateCompositeKey(stub, EntityAuctionTransition, []string{auction.ID, fmt.Sprintf("%06d", transition.Sequence)})
+	if err != nil {
+		return err
+	}
+
+	dataTransition, err := json.Marshal(transition)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, transitionKey, dataTransition)
+	return err
+}
+
+func (s *AuctionSmartContract) GetAuctionTransitions(stub shim.ChaincodeStubInterface, auctionID string) pb.Response {
+	visible, err := auctionIDIsVisibleToCaller(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !visible {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("auction %v does not exist", auctionID)))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityAuctionTransition), []string{auctionID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var transitions []models.AuctionTransition
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var transition models.AuctionTransition
+		err = json.Unmarshal(response.Value, &transition)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		transitions = append(transitions, transition)
+	}
+
+	transitionsJSON, err := json.Marshal(transitions)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(transitionsJSON)
+}
+
+// Open the scheduled auctions whose start date was reached
+func (s *AuctionSmartContract) OpenScheduledAuctions(stub shim.ChaincodeStubInterface) pb.Response {
+	fmt.Println("OpenScheduledAuctions Invoke")
+	auctions, err := GetAuctions(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	openedAuctions := []string{}
+	for _, auction := range auctions {
+		if auction.State != models.AuctionScheduled || auction.StartDate.After(currentTime) {
+			continue
+		}
+
+		// The auctions opened before are written already
+		err = s.transitionAuction(stub, &auction, models.AuctionOpen, PlatformWalletId, "Start date reached")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		openedAuctions = append(openedAuctions, auction.ID)
+	}
+
+	res, err := json.Marshal(openedAuctions)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(res)
+}
+
+// Cancel an auction that was not awarded yet. The Bitcircles reserved for the
+// winning bid are refunded and the parcels go back to Pending.
+func (s *AuctionSmartContract) CancelAuction(stub shim.ChaincodeStubInterface, participantID int, auctionID string, reason string) pb.Response {
+	fmt.Println("CancelAuction Invoke")
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	auctionJSON, err := s.ReadEntity(stub, auctionKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	var auction models.Auction
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	err = checkCaller(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	if participantID != auction.ParticipantId && participantID != PlatformWalletId {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprintf("The auction %s does not belong to the participant %d", auctionID, participantID)))
+	}
+
+	if !canTransitionAuction(auction.State, models.AuctionCancelled) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("Auction %s cannot move from '%s' to '%s'", auction.ID, auction.State, models.AuctionCancelled)))
+	}
+
+	parcels, err := s.cancelAuction(stub, &auction, participantID, reason)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var response struct {
+		Auction models.Auction       `json:"auction"`
+		Parcels []auctionParcelState `json:"parcels"`
+	}
+	response.Auction = auction
+	response.Parcels = parcels
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// Cancels the auction, refunds its winning bid and moves its parcels back to
+// Pending. An error may come after the transition was written, so the callers
+// fail the transaction.
+func (s *AuctionSmartContract) cancelAuction(stub shim.ChaincodeStubInterface, auction *models.Auction, actorID int, reason string) ([]auctionParcelState, error) {
+	err := s.transitionAuction(stub, auction, models.AuctionCancelled, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	lowestBid, _, err := getLowestBidForAuction(stub, auction.ID)
+	if err != nil {
+		return nil, err
+	}
+	if lowestBid.ID != "" {
+		err = s.releaseEscrow(stub, lowestBid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.updateAuctionParcelsState(stub, auction.ID, models.ParcelStatePending, 0, actorID)
+}
+
+// ** -----------------------------------------------------
+// ** AUCTION STATE MACHINE
+// ** -> END
+// ** -----------------------------------------------------
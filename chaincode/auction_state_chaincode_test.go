@@ -0,0 +1,188 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransitionAuction(t *testing.T) {
+	tests := []struct {
+		from    models.AuctionState
+		to      models.AuctionState
+		allowed bool
+	}{
+		{"", models.AuctionScheduled, true},
+		{"", models.AuctionOpen, true},
+		{"", models.AuctionAwarded, false},
+		{models.AuctionScheduled, models.AuctionOpen, true},
+		{models.AuctionScheduled, models.AuctionCancelled, true},
+		{models.AuctionScheduled, models.AuctionClosedBids, false},
+		{models.AuctionOpen, models.AuctionClosedBids, true},
+		{models.AuctionOpen, models.AuctionClosedNoBids, true},
+		{models.AuctionOpen, models.AuctionAwarded, true},
+		{models.AuctionOpen, models.AuctionCancelled, true},
+		{models.AuctionOpen, models.AuctionSettled, false},
+		{models.AuctionClosedBids, models.AuctionAwarded, true},
+		{models.AuctionClosedBids, models.AuctionOpen, false},
+		{models.AuctionAwarded, models.AuctionSettled, true},
+		{models.AuctionAwarded, models.AuctionCancelled, false},
+		{models.AuctionClosedNoBids, models.AuctionOpen, false},
+		{models.AuctionSettled, models.AuctionCancelled, false},
+		{models.AuctionCancelled, models.AuctionOpen, false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s to %s", test.from, test.to), func(t *testing.T) {
+			require.Equal(t, test.allowed, canTransitionAuction(test.from, test.to))
+		})
+	}
+}
+
+func TestCloseExpiredAuctions(t *testing.T) {
+	tests := []struct {
+		name        string
+		bids        func(c *testContract)
+		expire      bool
+		code        int
+		state       models.AuctionState
+		parcelState models.Status
+		deliverer   int
+	}{
+		{"not expired yet", nil, false, http.StatusBadRequest, models.AuctionOpen, models.ParcelStatePending, 0},
+		{"without bids", nil, true, 0, models.AuctionClosedNoBids, models.ParcelStatePending, 0},
+		{"awarded to the lowest bid", func(c *testContract) {
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "0", "2")
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "A1", "80.00", "2", "3")
+		}, true, 0, models.AuctionAwarded, models.ParcelStateDelivery, 3},
+		{"only outbid bids", func(c *testContract) {
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "0", "2")
+
+			// A lowest bid that is no longer there leaves only outbid bids
+			key, err := c.stub.CreateCompositeKey(string(EntityBid), []string{"b1", "A1"})
+			require.NoError(t, err)
+			var bid models.Bid
+			require.NoError(t, json.Unmarshal(c.stub.State[key], &bid))
+			bid.Status = models.BitStatusOutBidded
+			c.stub.State[key] = []byte(toJSON(t, bid))
+		}, true, 0, models.AuctionClosedNoBids, models.ParcelStatePending, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+			c.auction("A1", []int{1}, nil)
+			if test.bids != nil {
+				test.bids(c)
+			}
+			if test.expire {
+				c.after(6 * time.Hour)
+			}
+
+			var auction models.Auction
+			var parcel models.Parcel
+			var settlement models.Settlement
+			if test.code != 0 {
+				c.fails(test.code, "CloseExpiredAuctions", "A1")
+
+				require.True(t, c.entity(EntityAuction, &auction, "A1"))
+				require.Equal(t, test.state, auction.State)
+				return
+			}
+
+			var response struct {
+				Deliverer int `json:"deliverer_id"`
+			}
+			c.ok(&response, "CloseExpiredAuctions", "A1")
+			require.Equal(t, test.deliverer, response.Deliverer)
+
+			require.True(t, c.entity(EntityAuction, &auction, "A1"))
+			require.Equal(t, test.state, auction.State)
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, test.parcelState, parcel.State)
+
+			// Only an awarded auction is settled with its winner
+			hasSettlement := c.entity(EntitySettlement, &settlement, "A1")
+			require.Equal(t, test.deliverer != 0, hasSettlement)
+			if hasSettlement {
+				require.Equal(t, "b2", settlement.BidID)
+				require.Equal(t, int64(8000), settlement.MoneyAmount)
+				require.Equal(t, 98, c.wallet(3).Balance)
+			}
+		})
+	}
+}
+
+func TestGetAuctionTransitions(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+	c.award("b1", "A1", "90.00", "0", "2")
+
+	var transitions []models.AuctionTransition
+	c.ok(&transitions, "GetAuctionTransitions", "A1")
+
+	tests := []struct {
+		from models.AuctionState
+		to   models.AuctionState
+	}{
+		{"", models.AuctionOpen},
+		{models.AuctionOpen, models.AuctionClosedBids},
+		{models.AuctionClosedBids, models.AuctionAwarded},
+	}
+
+	require.Len(t, transitions, len(tests))
+	for i, test := range tests {
+		require.Equal(t, i+1, transitions[i].Sequence)
+		require.Equal(t, test.from, transitions[i].From)
+		require.Equal(t, test.to, transitions[i].To)
+	}
+
+	// A terminal auction does not move again
+	c.fails(http.StatusBadRequest, "CancelAuction", fmt.Sprint(PlatformWalletId), "A1")
+}
+
+func TestCancelAuction(t *testing.T) {
+	tests := []struct {
+		name     string
+		callerID int
+		code     int
+	}{
+		{"by the platform", PlatformWalletId, 0},
+		{"by the owning operator", testOperatorID, 0},
+		{"by another participant", 2, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+			c.auction("A1", []int{1}, nil)
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "3")
+			require.Equal(t, 5, c.wallet(3).Escrowed)
+
+			var auction models.Auction
+			if test.code != 0 {
+				c.as(test.callerID).fails(test.code, "CancelAuction", fmt.Sprint(test.callerID), "A1", "No longer needed")
+				require.True(t, c.entity(EntityAuction, &auction, "A1"))
+				require.Equal(t, models.AuctionOpen, auction.State)
+				return
+			}
+
+			c.as(test.callerID).ok(nil, "CancelAuction", fmt.Sprint(test.callerID), "A1", "No longer needed")
+			require.True(t, c.entity(EntityAuction, &auction, "A1"))
+			require.Equal(t, models.AuctionCancelled, auction.State)
+
+			// The reserved Bitcircles are refunded and the parcel can be auctioned again
+			wallet := c.as(PlatformWalletId).wallet(3)
+			require.Equal(t, 0, wallet.Escrowed)
+			require.Equal(t, 100, wallet.UsableBalance)
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, models.ParcelStatePending, parcel.State)
+		})
+	}
+}
@@ -18,7 +18,7 @@ import (
 // ** -----------------------------------------------------
 
 /*
-CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryBidingRequest", "1", "1", "500.50", "100.25"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryBidingRequest", "1", "1", "500.50", "10", "4", "2022-05-09T12:00:00Z", "EUR"]}'
 */
 
 func GetBids(stub shim.ChaincodeStubInterface) ([]models.Bid, error) {
@@ -45,12 +45,18 @@ func GetBids(stub shim.ChaincodeStubInterface) ([]models.Bid, error) {
 	return bids, nil
 }
 
+// Bids on invite-only auctions are hidden from callers that cannot see them
 func (s *AuctionSmartContract) GetLastBids(stub shim.ChaincodeStubInterface) ([]models.Bid, error) {
 	allBids, err := GetBids(stub)
 	if err != nil {
 		return nil, err
 	}
 
+	allBids, err = filterVisibleBids(stub, allBids)
+	if err != nil {
+		return nil, err
+	}
+
 	lastBids := make([]models.Bid, 0)
 
 	// Sort bids by date in descending order
@@ -70,17 +76,9 @@ func (s *AuctionSmartContract) GetLastBids(stub shim.ChaincodeStubInterface) ([]
 }
 
 func (s *AuctionSmartContract) GetBidsForAuction(stub shim.ChaincodeStubInterface, auctionID string) pb.Response {
-	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
-	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
-	}
-
-	exists, err := s.EntityRecordExists(stub, auctionKey)
+	_, err := s.readVisibleAuction(stub, auctionID)
 	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
-	}
-	if !exists {
-		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("auction %v does not exist", auctionID)))
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
 	}
 
 	allBids, err := GetBids(stub)
@@ -104,6 +102,100 @@ func (s *AuctionSmartContract) GetBidsForAuction(stub shim.ChaincodeStubInterfac
 	return shim.Success(bidJSON)
 }
 
+type bidLadderEntry struct {
+	Rank                    int        `json:"rank"`
+	Bid                     models.Bid `json:"bid"`
+	MoneyDeltaToLeader      int64      `json:"money_delta_to_leader"`
+	BitcircleDeltaToLeader  int        `json:"bitcircle_delta_to_leader"`
+	SecondsSincePreviousBid int64      `json:"seconds_since_previous_bid"`
+}
+
+type bidPricePoint struct {
+	Date                   time.Time `json:"date"`
+	BidID                  string    `json:"bid_id"`
+	CourierID              int       `json:"courier_id"`
+	MoneyAmount            int64     `json:"money_amount"`
+	Currency               string    `json:"currency"`
+	BitcircleAmount        int       `json:"bitcircle_amount"`
+	LeadingMoneyAmount     int64     `json:"leading_money_amount"`
+	LeadingBitcircleAmount int       `json:"leading_bitcircle_amount"`
+}
+
+// Bids of an auction ranked with the same comparator used to accept them (the
+// earliest bid wins a tie), plus the price of the auction over time
+func (s *AuctionSmartContract) GetAuctionBidLadder(stub shim.ChaincodeStubInterface, auctionID string) pb.Response {
+	_, err := s.readVisibleAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	bids, err := getBidsForAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// Chronological order for the time between bids and the price history
+	sort.SliceStable(bids, func(i, j int) bool {
+		return bids[i].Date.Before(bids[j].Date)
+	})
+
+	secondsSincePreviousBid := make(map[string]int64)
+	var priceHistory []bidPricePoint
+	var leadingBid models.Bid
+	for i, bid := range bids {
+		if i > 0 {
+			secondsSincePreviousBid[bid.ID] = int64(bid.Date.Sub(bids[i-1].Date).Seconds())
+		}
+		if leadingBid.ID == "" || bidBeats(bid, leadingBid) {
+			leadingBid = bid
+		}
+		priceHistory = append(priceHistory, bidPricePoint{
+			Date:                   bid.Date,
+			BidID:                  bid.ID,
+			CourierID:              bid.CourierID,
+			MoneyAmount:            bid.MoneyAmount,
+			Currency:               bid.Currency,
+			BitcircleAmount:        bid.BitcircleAmount,
+			LeadingMoneyAmount:     leadingBid.MoneyAmount,
+			LeadingBitcircleAmount: leadingBid.BitcircleAmount,
+		})
+	}
+
+	rankedBids := make([]models.Bid, len(bids))
+	copy(rankedBids, bids)
+	sort.SliceStable(rankedBids, func(i, j int) bool {
+		return bidBeats(rankedBids[i], rankedBids[j])
+	})
+
+	var ladder []bidLadderEntry
+	for i, bid := range rankedBids {
+		ladder = append(ladder, bidLadderEntry{
+			Rank:                    i + 1,
+			Bid:                     bid,
+			MoneyDeltaToLeader:      bid.MoneyAmount - rankedBids[0].MoneyAmount,
+			BitcircleDeltaToLeader:  bid.BitcircleAmount - rankedBids[0].BitcircleAmount,
+			SecondsSincePreviousBid: secondsSincePreviousBid[bid.ID],
+		})
+	}
+
+	var response struct {
+		AuctionID    string           `json:"auction_id"`
+		Ladder       []bidLadderEntry `json:"ladder"`
+		PriceHistory []bidPricePoint  `json:"price_history"`
+	}
+	response.AuctionID = auctionID
+	response.Ladder = ladder
+	response.PriceHistory = priceHistory
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// Bids on invite-only auctions are hidden from callers that cannot see them
 func (s *AuctionSmartContract) GetParticipantBids(stub shim.ChaincodeStubInterface, userID int) pb.Response {
 	allBids, err := GetBids(stub)
 	if err != nil {
@@ -116,6 +208,11 @@ func (s *AuctionSmartContract) GetParticipantBids(stub shim.ChaincodeStubInterfa
 		}
 	}
 
+	bids, err = filterVisibleBids(stub, bids)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
 	// Convert the slice of bids to JSON
 	bidsJSON, err := json.Marshal(bids)
 	if err != nil {
@@ -125,7 +222,9 @@ func (s *AuctionSmartContract) GetParticipantBids(stub shim.ChaincodeStubInterfa
 	return shim.Success(bidsJSON)
 }
 
-func (s *AuctionSmartContract) ParcelDeliveryBidingRequest(stub shim.ChaincodeStubInterface, bidID string, auctionID string, moneyAmount float32, bitcircleAmount int, participantId int, date time.Time) pb.Response {
+// The money amount is a decimal in the currency of the auction, bids in another
+// currency are rejected. The bid is dated at the time of the transaction.
+func (s *AuctionSmartContract) ParcelDeliveryBidingRequest(stub shim.ChaincodeStubInterface, bidID string, auctionID string, money string, currency string, bitcircleAmount int, participantId int) pb.Response {
 	transactionError := false
 
 	// Start a new transaction
@@ -136,11 +235,6 @@ func (s *AuctionSmartContract) ParcelDeliveryBidingRequest(stub shim.ChaincodeSt
 	// Roolback in case of error Or Commit in case of success
 	defer s.CloseTransaction(stub, transactionId, transactionError)
 
-	if moneyAmount < 0 {
-		transactionError = true
-		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("The money ammount most be higher or equal than 0")))
-	}
-
 	if bitcircleAmount < 0 {
 		transactionError = true
 		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("The bitcircle ammount most be higher or equal than 0")))
@@ -165,139 +259,214 @@ func (s *AuctionSmartContract) ParcelDeliveryBidingRequest(stub shim.ChaincodeSt
 		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
 	}
 
+	if auction.Type == models.AuctionTypeClock {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("This is a clock auction, bids are not accepted. Use AcceptClockAuctionPrice to accept the current price.")))
+	}
+
+	err = checkCourierAuctionAccess(stub, auction, participantId)
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	if currency != "" && currency != auction.Currency {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The auction ", auction.ID, " is in ", auction.Currency, ", bids in ", currency, " are not accepted")))
+	}
+
+	moneyAmount, err := models.ParseMoney(money, auction.Currency)
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	if moneyAmount < 0 {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("The money ammount most be higher or equal than 0")))
+	}
+
 	if moneyAmount > auction.MaximumAcceptedLicitation {
 		transactionError = true
 		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("The bid amount cannot exceed the maximum limit set for this auction. Please enter a lower bid amount.")))
 	}
 
 	// Get the current time
-	currentTime := getCurrentTime()
-	if auction.State != models.AuctionState(models.AuctionOpen) || auction.EndDate.Before(currentTime) {
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if auction.State == models.AuctionScheduled {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "This auction has not started yet"))
+	}
+	if auction.State != models.AuctionOpen || auction.EndDate.Before(currentTime) {
 		transactionError = true
 		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("This auction is already closed")))
 	}
 
 	// Check if the new bid is the lowest bid
-	bidsIterator, err := stub.GetStateByPartialCompositeKey(string(EntityBid), []string{})
+	lowestBid, lowestBidKey, err := getLowestBidForAuction(stub, auctionID)
 	if err != nil {
 		transactionError = true
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
+
+	fmt.Println("lowestBid: ", lowestBidKey)
+
+	// Create new bid
+	bid := models.Bid{
+		ID:              bidID,
+		Date:            currentTime,
+		BitcircleAmount: bitcircleAmount,
+		MoneyAmount:     moneyAmount,
+		Currency:        auction.Currency,
+		Status:          models.BitStatusLowerBid,
+		Winner:          false,
+		AuctionID:       auctionID,
+		CourierID:       participantId,
+	}
+
+	// Check if new bid is lower than lowest bid
+	if lowestBid.ID != "" && !bidBeats(bid, lowestBid) {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The current winner bid have ", models.FormatMoney(lowestBid.MoneyAmount, lowestBid.Currency), " and ", lowestBid.BitcircleAmount, " bitcircles. The bid amount cannot be higher than the current lowest bid. If the bid amount is equal to the lowest bid, please increase the number of Bitcircles instead.")))
+	}
+
+	if lowestBid.CourierID == participantId {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("You cannot place a new bid because you are the owner of the current winning bid.")))
+	}
+
+	err = s.VerifyWalletAmount(stub, participantId, bid.BitcircleAmount)
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	// Couriers with a proxy bid on this auction answer the new bid automatically
+	biddingRound, proxyBids, err := s.resolveProxyBids(stub, auction, &bid, nil, lowestBid)
+	if err != nil {
+		transactionError = true
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	err = s.storeBiddingRound(stub, lowestBid, lowestBidKey, biddingRound, proxyBids)
+	if err != nil {
+		transactionError = true
+		return shim.Error(err.Error())
+	}
+
+	jsonDataBid, err := json.Marshal(biddingRound[0])
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(jsonDataBid)
+}
+
+// A bid beats another one when it asks for less money, or for the same money
+// while offering more Bitcircles.
+func bidBeats(bid models.Bid, otherBid models.Bid) bool {
+	return bid.MoneyAmount < otherBid.MoneyAmount || (bid.MoneyAmount == otherBid.MoneyAmount && bid.BitcircleAmount > otherBid.BitcircleAmount)
+}
+
+// Returns the current winning bid of the auction and its key, or an empty bid
+func getLowestBidForAuction(stub shim.ChaincodeStubInterface, auctionID string) (models.Bid, string, error) {
+	bidsIterator, err := stub.GetStateByPartialCompositeKey(string(EntityBid), []string{})
+	if err != nil {
+		return models.Bid{}, "", err
+	}
 	defer bidsIterator.Close()
 
-	var lowestBid models.Bid
-	var lowestBidKey string
 	for bidsIterator.HasNext() {
 		bidResponse, err := bidsIterator.Next()
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return models.Bid{}, "", err
 		}
 
 		var bid models.Bid
 		err = json.Unmarshal(bidResponse.Value, &bid)
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return models.Bid{}, "", err
 		}
 
-		fmt.Println("Iterate Bid: ", bid.ID)
-		fmt.Println("Auction: ", bid.AuctionID)
-
-		// Checks if this bid is the current winner and if input money amount is less than or equal to winner bid amount
+		// If it finds the winner bid, there is no need to continue iterating
 		if bid.AuctionID == auctionID && bid.Status == models.BitStatusLowerBid {
-			lowestBid = bid
-			lowestBidKey = bidResponse.Key
-			// If it finds the winner bid, there is no need to continue iterating
-			break
+			return bid, bidResponse.Key, nil
 		}
 	}
 
-	fmt.Println("lowestBid: ", lowestBidKey)
+	return models.Bid{}, "", nil
+}
 
-	// Check if new bid is lower than lowest bid
-	if lowestBid.ID != "" && (moneyAmount > lowestBid.MoneyAmount || (moneyAmount == lowestBid.MoneyAmount && bitcircleAmount <= lowestBid.BitcircleAmount)) {
-		transactionError = true
-		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The current winner bid have ", lowestBid.MoneyAmount, "€ and ", lowestBid.BitcircleAmount, " bitcircles. The bid amount cannot be higher than the current lowest bid. If the bid amount is equal to the lowest bid, please increase the number of Bitcircles instead.")))
+// Store the bids placed in one transaction. The previous winner and every bid
+// but the last one of the round are outbidded, and the Bitcircles reserved for
+// the previous winner move to the new winner. An error leaves the round half
+// written, the callers fail the transaction.
+func (s *AuctionSmartContract) storeBiddingRound(stub shim.ChaincodeStubInterface, prevBid models.Bid, prevBidKey string, biddingRound []models.Bid, proxyBids []models.ProxyBid) error {
+	for _, proxyBid := range proxyBids {
+		err := s.putProxyBid(stub, proxyBid)
+		if err != nil {
+			return err
+		}
 	}
 
-	if lowestBid.CourierID == participantId {
-		transactionError = true
-		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("You cannot place a new bid because you are the owner of the current winning bid.")))
+	if len(biddingRound) == 0 {
+		return nil
 	}
+	winnerBid := biddingRound[len(biddingRound)-1]
 
 	// Set previous lowest bid to "Outbidded" status and not a winner
-	if lowestBidKey != "" {
-		var prevBid models.Bid
-		prevBidByte, err := stub.GetState(lowestBidKey)
+	if prevBidKey != "" {
+		prevBid.Status = models.BitStatusOutBidded
+		jsonDataPrevBid, err := json.Marshal(prevBid)
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return err
 		}
-		err = json.Unmarshal(prevBidByte, &prevBid)
+		fmt.Println("Update Bid")
+		_, err = s.UpsertEntityRecord(stub, prevBidKey, jsonDataPrevBid)
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return err
 		}
+	}
 
-		prevBid.Status = models.BitStatusOutBidded
+	for i, bid := range biddingRound {
+		if i < len(biddingRound)-1 {
+			bid.Status = models.BitStatusOutBidded
+		} else {
+			bid.Status = models.BitStatusLowerBid
+		}
+		biddingRound[i] = bid
 
-		err = s.RefundBitcirclesForAuction(stub, prevBid.CourierID, prevBid.BitcircleAmount, prevBid.MoneyAmount, prevBid.AuctionID, false)
+		bidCompositeKey, err := s.CreateCompositeKey(stub, EntityBid, []string{fmt.Sprint(bid.ID), fmt.Sprint(bid.AuctionID)})
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+			return err
 		}
 
-		jsonDataPrevBid, err := json.Marshal(prevBid)
+		jsonDataBid, err := json.Marshal(bid)
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return err
 		}
-		fmt.Println("Update Bid")
-		_, err = s.UpsertEntityRecord(stub, lowestBidKey, jsonDataPrevBid)
+
+		_, err = s.UpsertEntityRecord(stub, bidCompositeKey, jsonDataBid)
 		if err != nil {
-			transactionError = true
-			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			return err
 		}
-
 	}
 
-	// Create new bid
-	bid := models.Bid{
-		ID:              bidID,
-		Date:            date,
-		BitcircleAmount: bitcircleAmount,
-		MoneyAmount:     moneyAmount,
-		Status:          models.BitStatusLowerBid,
-		Winner:          false,
-		AuctionID:       auctionID,
-		CourierID:       participantId,
-	}
-
-	bidCompositeKey, err := s.CreateCompositeKey(stub, EntityBid, []string{fmt.Sprint(bidID), fmt.Sprint(auctionID)})
-	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
-	}
-
-	jsonDataBid, err := json.Marshal(bid)
-	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
-	}
-
-	err = s.ReserveBitcirclesForBid(stub, participantId, bid.BitcircleAmount, bid.MoneyAmount, bid.AuctionID, false)
-	if err != nil {
-		transactionError = true
-		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
-	}
-
-	_, err = s.UpsertEntityRecord(stub, bidCompositeKey, jsonDataBid)
-	if err != nil {
-		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	// The escrow follows the lowest bid
+	if prevBidKey != "" {
+		err := s.releaseEscrow(stub, prevBid)
+		if err != nil {
+			return err
+		}
 	}
-
-	return shim.Success(jsonDataBid)
+	return s.openEscrow(stub, winnerBid)
 }
 
+// Bids on invite-only auctions are hidden from callers that cannot see them
 func (s *AuctionSmartContract) ReadBids(stub shim.ChaincodeStubInterface) pb.Response {
 	// Create iterator for all bid entities
 	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityBid), []string{})
@@ -325,6 +494,11 @@ func (s *AuctionSmartContract) ReadBids(stub shim.ChaincodeStubInterface) pb.Res
 		bids = append(bids, bid)
 	}
 
+	bids, err = filterVisibleBids(stub, bids)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
 	// Convert the slice of bids to JSON
 	bidJSON, err := json.Marshal(bids)
 	if err != nil {
@@ -0,0 +1,77 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBidBeats(t *testing.T) {
+	tests := []struct {
+		name  string
+		bid   models.Bid
+		other models.Bid
+		beats bool
+	}{
+		{"less money", models.Bid{MoneyAmount: 8000}, models.Bid{MoneyAmount: 9000, BitcircleAmount: 5}, true},
+		{"more money", models.Bid{MoneyAmount: 9000, BitcircleAmount: 5}, models.Bid{MoneyAmount: 8000}, false},
+		{"same money and more Bitcircles", models.Bid{MoneyAmount: 8000, BitcircleAmount: 2}, models.Bid{MoneyAmount: 8000}, true},
+		{"same money and fewer Bitcircles", models.Bid{MoneyAmount: 8000}, models.Bid{MoneyAmount: 8000, BitcircleAmount: 2}, false},
+		{"the same bid", models.Bid{MoneyAmount: 8000, BitcircleAmount: 2}, models.Bid{MoneyAmount: 8000, BitcircleAmount: 2}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.beats, bidBeats(test.bid, test.other))
+		})
+	}
+}
+
+func TestGetAuctionBidLadder(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100, 4: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+
+	// Bids are dated at the time of their transaction, not by the client
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "0", "2", "2000-01-01T00:00:00Z")
+	c.after(30*time.Second).ok(nil, "ParcelDeliveryBidingRequest", "b2", "A1", "80.00", "0", "3", "2000-01-01T00:00:00Z")
+	c.after(60*time.Second).ok(nil, "ParcelDeliveryBidingRequest", "b3", "A1", "80.00", "2", "4", "2000-01-01T00:00:00Z")
+
+	var response struct {
+		Ladder       []bidLadderEntry `json:"ladder"`
+		PriceHistory []bidPricePoint  `json:"price_history"`
+	}
+	c.ok(&response, "GetAuctionBidLadder", "A1")
+
+	tests := []struct {
+		bidID                   string
+		moneyDeltaToLeader      int64
+		bitcircleDeltaToLeader  int
+		secondsSincePreviousBid int64
+	}{
+		{"b3", 0, 0, 60},
+		{"b2", 0, -2, 30},
+		{"b1", 1000, -2, 0},
+	}
+
+	require.Len(t, response.Ladder, len(tests))
+	for i, test := range tests {
+		t.Run(test.bidID, func(t *testing.T) {
+			entry := response.Ladder[i]
+			require.Equal(t, i+1, entry.Rank)
+			require.Equal(t, test.bidID, entry.Bid.ID)
+			require.Equal(t, test.moneyDeltaToLeader, entry.MoneyDeltaToLeader)
+			require.Equal(t, test.bitcircleDeltaToLeader, entry.BitcircleDeltaToLeader)
+			require.Equal(t, test.secondsSincePreviousBid, entry.SecondsSincePreviousBid)
+		})
+	}
+
+	var leading []int64
+	for _, point := range response.PriceHistory {
+		require.True(t, point.Date.After(testStartTime))
+		leading = append(leading, point.LeadingMoneyAmount)
+	}
+	require.Equal(t, []int64{9000, 8000, 8000}, leading)
+	require.Equal(t, 2, response.PriceHistory[2].LeadingBitcircleAmount)
+}
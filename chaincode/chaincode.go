@@ -26,11 +26,58 @@ const (
 	EntityBid                  Entity = "BID"
 	EntityWallet               Entity = "WALLET"
 	EntityBitcircleTransaction Entity = "BITCIRCLETRANSACTION"
+	EntityProxyBid             Entity = "PROXY_BID"
+	EntityAuctionInvite        Entity = "AUCTION_INVITE"
+	EntityOperatorDenylist     Entity = "OPERATOR_DENYLIST"
+	EntityAuctionTransition    Entity = "AUCTION_TRANSITION"
+	EntityDeliveryConfirmation Entity = "DELIVERY_CONFIRMATION"
+	EntityDeliveryAttempt      Entity = "DELIVERY_ATTEMPT"
+	EntityPlatformConfig       Entity = "PLATFORM_CONFIG"
+	EntitySlaBreach            Entity = "SLA_BREACH"
+	EntityParcelAmendment      Entity = "PARCEL_AMENDMENT"
+	EntityPostalArea           Entity = "POSTAL_AREA"
+	EntityDeliveryPreference   Entity = "DELIVERY_PREFERENCE"
+	EntityParcelLeg            Entity = "PARCEL_LEG"
+	EntityParcelHandover       Entity = "PARCEL_HANDOVER"
+	EntityEscrow               Entity = "ESCROW"
+	EntityBitcircleSupply      Entity = "BITCIRCLE_SUPPLY"
+	EntityRewardLot            Entity = "REWARD_LOT"
+	EntityWalletFreeze         Entity = "WALLET_FREEZE"
+	EntityWalletHold           Entity = "WALLET_HOLD"
+	EntitySettlement           Entity = "SETTLEMENT"
 )
 
 const PlatformWalletId = 0
 
-var Bitcircle_Transaction_ID = 0
+// State kept by a running invocation, as its world state reads do not see its
+// own writes. It lives on the stub given to Init or Invoke and goes with it, so
+// a transaction simulated again starts again from the world state.
+type txScope struct {
+	bitcircleTransactions int
+	wallets               map[int]*bookWallet
+	rewardLots            map[int][]*bookRewardLot
+}
+
+type scopedStub struct {
+	shim.ChaincodeStubInterface
+	scope *txScope
+}
+
+func newScopedStub(stub shim.ChaincodeStubInterface) shim.ChaincodeStubInterface {
+	return &scopedStub{
+		ChaincodeStubInterface: stub,
+		scope:                  &txScope{wallets: map[int]*bookWallet{}, rewardLots: map[int][]*bookRewardLot{}},
+	}
+}
+
+// A stub that did not come through Init or Invoke gets a scope for this call only
+func withTxScope(stub shim.ChaincodeStubInterface, update func(scope *txScope)) {
+	scoped, ok := stub.(*scopedStub)
+	if !ok {
+		scoped = newScopedStub(stub).(*scopedStub)
+	}
+	update(scoped.scope)
+}
 
 type ErrorResponse struct {
 	ErrorCode    int    `json:"errorCode"`
@@ -60,6 +107,15 @@ func getCurrentTime() time.Time {
 	return time.Now().Add(1 * time.Hour)
 }
 
+// Deterministic time of the transaction, the same on every endorsing peer
+func getTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	txTimestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return txTimestamp.AsTime().UTC(), nil
+}
+
 // ** -----------------------------------------------------
 // ** ENTITY RECORDS METHODS
 // ** -> BEGIN
@@ -160,12 +216,18 @@ func (s *AuctionSmartContract) CloseTransaction(stub shim.ChaincodeStubInterface
 // ** -----------------------------------------------------
 
 func (t *AuctionSmartContract) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	stub = newScopedStub(stub)
+	err := t.initTreasury(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
 	fmt.Println("Init returning with success")
 	return shim.Success(nil)
 }
 
 func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
 	fmt.Println("Invoke")
+	stub = newScopedStub(stub)
 	if os.Getenv("DEVMODE_ENABLED") != "" {
 		fmt.Println("invoking in devmode")
 	}
@@ -183,6 +245,23 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
 		}
 		return t.ParcelDeliveryParcelAdded(stub, parcel)
+	case "ParcelDeliveryParcelsAdded":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting a JSON array and optionally \"AllOrNothing\" as arguments"))
+		}
+		var parcels []models.Parcel
+		err := json.Unmarshal([]byte(args[0]), &parcels)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		allOrNothing := false
+		if len(args) > 1 {
+			allOrNothing, err = strconv.ParseBool(args[1])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, "Invalid \"AllOrNothing\" argument, most be true or false"))
+			}
+		}
+		return t.ParcelDeliveryParcelsAdded(stub, parcels, allOrNothing)
 	case "ReadParcels":
 		return t.ReadParcels(stub)
 	case "ReadParcelsByState":
@@ -191,6 +270,287 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 		}
 		state := args[0]
 		return t.ReadParcelsByState(stub, state)
+	case "UpdateParcel":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\" and a JSON object as arguments"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		var parcel models.Parcel
+		err = json.Unmarshal([]byte(args[1]), &parcel)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		return t.UpdateParcel(stub, logisticOperatorID, parcel)
+	case "GetParcelAmendments":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParcelId\" as an argument"))
+		}
+		parcelID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[0])))
+		}
+		return t.GetParcelAmendments(stub, parcelID)
+	case "MigrateParcelWeights":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" as an argument"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.MigrateParcelWeights(stub, participantID)
+	case "MigrateMoneyAmounts":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" as an argument"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.MigrateMoneyAmounts(stub, participantID)
+	case "MarkSettlementPaid":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\", \"AuctionId\" and \"PaymentReference\" as arguments"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Logistic Operator id: ", args[0])))
+		}
+		return t.MarkSettlementPaid(stub, logisticOperatorID, args[1], args[2])
+	case "DisputeSettlement":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\", \"AuctionId\" and \"Reason\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.DisputeSettlement(stub, participantID, args[1], args[2])
+	case "GetOperatorSettlements":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\" and an optional \"State\" as arguments"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Logistic Operator id: ", args[0])))
+		}
+		state := ""
+		if len(args) > 1 {
+			state = args[1]
+		}
+		return t.GetOperatorSettlements(stub, logisticOperatorID, models.SettlementState(state))
+	case "GetCourierSettlements":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"CourierId\" and an optional \"State\" as arguments"))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Courier id: ", args[0])))
+		}
+		state := ""
+		if len(args) > 1 {
+			state = args[1]
+		}
+		return t.GetCourierSettlements(stub, courierID, models.SettlementState(state))
+	case "RegisterPostalAreas":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" and a JSON array as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		var postalAreas []models.PostalArea
+		err = json.Unmarshal([]byte(args[1]), &postalAreas)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		return t.RegisterPostalAreas(stub, participantID, postalAreas)
+	case "GetPostalAreas":
+		region := ""
+		if len(args) > 0 {
+			region = args[0]
+		}
+		return t.GetPostalAreas(stub, region)
+	case "ReadParcelsByRoute":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"PickupArea\", \"DeliveryArea\" and optionally \"IncludeAdjacent\" as arguments"))
+		}
+		includeAdjacent := false
+		if len(args) > 2 {
+			var err error
+			includeAdjacent, err = strconv.ParseBool(args[2])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, "Invalid \"IncludeAdjacent\" argument, most be true or false"))
+			}
+		}
+		return t.ReadParcelsByRoute(stub, args[0], args[1], includeAdjacent)
+	case "ReadParcelsByRegion":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"PickupRegion\" and \"DeliveryRegion\" as arguments, an empty region matches any region"))
+		}
+		return t.ReadParcelsByRegion(stub, args[0], args[1])
+	case "SuggestAuctionBundles":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\", \"MaxWeight\", \"MaxVolumes\" and optionally \"WindowHours\" as arguments"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Logistic Operator id: ", args[0])))
+		}
+		maxWeight, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Max Weight: ", args[1])))
+		}
+		maxVolumes, err := strconv.Atoi(args[2])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Max Volumes: ", args[2])))
+		}
+		windowHours := 24
+		if len(args) > 3 {
+			windowHours, err = strconv.Atoi(args[3])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Window Hours: ", args[3])))
+			}
+		}
+		return t.SuggestAuctionBundles(stub, logisticOperatorID, maxWeight, maxVolumes, windowHours)
+	case "SetDeliveryPreferences":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"EndCustomerId\" and a JSON object as arguments"))
+		}
+		endCustomerID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		var preference models.DeliveryPreference
+		err = json.Unmarshal([]byte(args[1]), &preference)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		return t.SetDeliveryPreferences(stub, endCustomerID, preference)
+	case "GetDeliveryPreferences":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" and \"ParcelId\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.GetDeliveryPreferences(stub, participantID, parcelID)
+	case "SplitParcelRoute":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\", \"ParcelId\" and a JSON array of legs as arguments"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		var legs []models.ParcelLeg
+		err = json.Unmarshal([]byte(args[2]), &legs)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		return t.SplitParcelRoute(stub, logisticOperatorID, parcelID, legs)
+	case "SignHandover":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"CourierId\", \"ParcelId\" and \"ProofHash\""))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.SignHandover(stub, courierID, parcelID, args[2])
+	case "GetParcelChainOfCustody":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParcelId\" as an argument"))
+		}
+		parcelID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[0])))
+		}
+		return t.GetParcelChainOfCustody(stub, parcelID)
+	case "GetParcelTimeline":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParcelId\" as an argument"))
+		}
+		parcelID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[0])))
+		}
+		return t.GetParcelTimeline(stub, parcelID)
+	case "CancelParcel":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\" and \"ParcelId\" as arguments"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.CancelParcel(stub, logisticOperatorID, parcelID)
+	case "DeleteParcel":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\", \"ParcelId\" and optionally \"Cascade\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		cascade := false
+		if len(args) > 2 {
+			cascade, err = strconv.ParseBool(args[2])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, "Invalid \"Cascade\" argument, most be true or false"))
+			}
+		}
+		return t.DeleteParcel(stub, participantID, parcelID, cascade)
+	case "DeleteAuction":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\", \"AuctionId\" and optionally \"Cascade\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		cascade := false
+		if len(args) > 2 {
+			cascade, err = strconv.ParseBool(args[2])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, "Invalid \"Cascade\" argument, most be true or false"))
+			}
+		}
+		return t.DeleteAuction(stub, participantID, args[1], cascade)
+	case "DeleteBid":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\", \"BidId\" and \"AuctionId\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.DeleteBid(stub, participantID, args[1], args[2])
 	case "DeleteAllParcels":
 		return t.DeleteAllParcels(stub)
 	case "ParcelDeliveryAuctionStart":
@@ -232,34 +592,291 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 		}
 		state := args[0]
 		return t.ReadAuctionsByState(stub, state)
+	case "InviteCouriersToAuction":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"LogisticOperatorId\", \"Auction Id\" and a JSON array of \"CourierId\""))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		auctionID := args[1]
+		var courierIDs []int
+		err = json.Unmarshal([]byte(args[2]), &courierIDs)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		return t.InviteCouriersToAuction(stub, logisticOperatorID, auctionID, courierIDs)
+	case "RevokeAuctionInvite":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"LogisticOperatorId\", \"Auction Id\" and \"CourierId\""))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		auctionID := args[1]
+		courierID, err := strconv.Atoi(args[2])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[2])))
+		}
+		return t.RevokeAuctionInvite(stub, logisticOperatorID, auctionID, courierID)
+	case "GetAuctionInvites":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 2 arguments: \"LogisticOperatorId\" and \"Auction Id\""))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		auctionID := args[1]
+		return t.GetAuctionInvites(stub, logisticOperatorID, auctionID)
+	case "DenyCourier":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"LogisticOperatorId\", \"CourierId\" and \"Reason\""))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		courierID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[1])))
+		}
+		reason := args[2]
+		return t.DenyCourier(stub, logisticOperatorID, courierID, reason)
+	case "AllowCourier":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 2 arguments: \"LogisticOperatorId\" and \"CourierId\""))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		courierID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[1])))
+		}
+		return t.AllowCourier(stub, logisticOperatorID, courierID)
+	case "ConfirmPickup":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"CourierId\", \"ParcelId\" and \"ProofHash\""))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.ConfirmPickup(stub, courierID, parcelID, args[2])
+	case "ConfirmDelivery":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"CourierId\", \"ParcelId\" and \"ProofHash\""))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.ConfirmDelivery(stub, courierID, parcelID, args[2])
+	case "CountersignDelivery":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 2 arguments: \"ParticipantId\" and \"ParcelId\""))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.CountersignDelivery(stub, participantID, parcelID)
+	case "GetDeliveryConfirmation":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParcelId\" as an argument"))
+		}
+		parcelID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[0])))
+		}
+		return t.GetDeliveryConfirmation(stub, parcelID)
+	case "RecordFailedDeliveryAttempt":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"CourierId\", \"ParcelId\", \"ReasonCode\" and optionally \"Notes\" as arguments"))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		notes := ""
+		if len(args) > 3 {
+			notes = args[3]
+		}
+		return t.RecordFailedDeliveryAttempt(stub, courierID, parcelID, models.FailedAttemptReason(args[2]), notes)
+	case "ResolveFailedAttempt":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"ParticipantId\", \"ParcelId\" and \"Action\""))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		parcelID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[1])))
+		}
+		return t.ResolveFailedAttempt(stub, participantID, parcelID, args[2])
+	case "GetParcelDeliveryAttempts":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParcelId\" as an argument"))
+		}
+		parcelID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Parcel id: ", args[0])))
+		}
+		return t.GetParcelDeliveryAttempts(stub, parcelID)
+	case "GetPlatformConfig":
+		return t.GetPlatformConfig(stub)
+	case "SetPlatformConfig":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" and a JSON object as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		config := newPlatformConfig()
+		err = json.Unmarshal([]byte(args[1]), &config)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Failed to parse JSON object: "+err.Error()))
+		}
+		return t.SetPlatformConfig(stub, participantID, config)
+	case "GetCourierSlaBreaches":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"CourierId\" as an argument"))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.GetCourierSlaBreaches(stub, courierID)
+	case "GetOperatorSlaBreaches":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\" as an argument"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.GetOperatorSlaBreaches(stub, logisticOperatorID)
+	case "GetOperatorDenylist":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"LogisticOperatorId\" as an argument"))
+		}
+		logisticOperatorID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.GetOperatorDenylist(stub, logisticOperatorID)
 	case "DeleteAllAuctions":
 		return t.DeleteAllAuctions(stub)
 	case "ParcelDeliveryBidingRequest":
 		if len(args) < 5 {
-			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 5 arguments: \"Id\", \"Auction Id\", \"Money Amount\", \"Bitcircles\", \"CourierId\" and \"Date\""))
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 5 arguments: \"Id\", \"Auction Id\", \"Money Amount\", \"Bitcircles\", \"CourierId\", \"Date\" and optionally \"Currency\""))
 		}
 		bidID := args[0]
 		auctionID := args[1]
 
-		moneyAmount, err := strconv.ParseFloat(args[2], 32)
+		bitcircle, err := strconv.Atoi(args[3])
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 		}
-		bitcircle, err := strconv.Atoi(args[3])
+		courierID, err := strconv.Atoi(args[4])
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 		}
-		courierID, err := strconv.Atoi(args[4])
+
+		// The Date argument is kept for the clients that send it, bids are
+		// dated at the time of the transaction
+		currency := ""
+		if len(args) > 6 {
+			currency = args[6]
+		}
+
+		return t.ParcelDeliveryBidingRequest(stub, bidID, auctionID, args[2], currency, bitcircle, courierID)
+	case "GetAuctionClockPrice":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"auctionId\" as an argument"))
+		}
+		auctionID := args[0]
+		return t.GetAuctionClockPrice(stub, auctionID)
+	case "AcceptClockAuctionPrice":
+		if len(args) < 4 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 4 arguments: \"Id\", \"Auction Id\", \"Bitcircles\" and \"CourierId\""))
+		}
+		bidID := args[0]
+		auctionID := args[1]
+
+		bitcircle, err := strconv.Atoi(args[2])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+		}
+		courierID, err := strconv.Atoi(args[3])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+		}
+
+		return t.AcceptClockAuctionPrice(stub, bidID, auctionID, bitcircle, courierID)
+	case "RegisterProxyBid":
+		if len(args) < 4 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 4 arguments: \"CourierId\", \"Auction Id\", \"Floor Amount\", \"Max Bitcircles\" and optionally \"Currency\""))
+		}
+		courierID, err := strconv.Atoi(args[0])
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 		}
+		auctionID := args[1]
 
-		date, err := time.Parse(time.RFC3339, args[5])
+		maxBitcircles, err := strconv.Atoi(args[3])
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 		}
 
-		return t.ParcelDeliveryBidingRequest(stub, bidID, auctionID, float32(moneyAmount), bitcircle, courierID, date)
+		currency := ""
+		if len(args) > 4 {
+			currency = args[4]
+		}
+
+		return t.RegisterProxyBid(stub, courierID, auctionID, args[2], currency, maxBitcircles)
+	case "CancelProxyBid":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 2 arguments: \"CourierId\" and \"Auction Id\""))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+		}
+		auctionID := args[1]
+		return t.CancelProxyBid(stub, courierID, auctionID)
+	case "GetCourierProxyBids":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"CourierId\" as an argument"))
+		}
+		courierID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.GetCourierProxyBids(stub, courierID)
 	case "ReadBids":
 		return t.ReadBids(stub)
 	case "GetBidsForAuction":
@@ -268,6 +885,12 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 		}
 		auctionID := args[0]
 		return t.GetBidsForAuction(stub, auctionID)
+	case "GetAuctionBidLadder":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"auctionId\"  as an argument"))
+		}
+		auctionID := args[0]
+		return t.GetAuctionBidLadder(stub, auctionID)
 	case "GetParticipantBids":
 		if len(args) < 1 {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"UserId\" as an argument"))
@@ -287,6 +910,26 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 		return t.CloseExpiredAuctions(stub, id)
 	case "ListOfExpiredAuctions":
 		return t.ListOfExpiredAuctions(stub)
+	case "OpenScheduledAuctions":
+		return t.OpenScheduledAuctions(stub)
+	case "CancelAuction":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"participantId\", \"auctionId\" and optionally \"reason\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Invalid \"participantId\" argument"))
+		}
+		reason := "Cancelled by the logistic operator"
+		if len(args) > 2 {
+			reason = args[2]
+		}
+		return t.CancelAuction(stub, participantID, args[1], reason)
+	case "GetAuctionTransitions":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"auctionId\" as an argument"))
+		}
+		return t.GetAuctionTransitions(stub, args[0])
 	case "CreateParticipantWallet":
 		if len(args) < 1 {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting a JSON object as an argument"))
@@ -296,7 +939,50 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 		if err != nil {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 		}
-		return t.CreateParticipantWallet(stub, wallet)
+		grant := 0
+		if len(args) > 1 {
+			grant, err = strconv.Atoi(args[1])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Grant: ", args[1])))
+			}
+		}
+		return t.CreateParticipantWallet(stub, wallet, grant)
+	case "MintBitcircles":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\", \"BitcircleAmount\" and optionally \"Description\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		bitcircleAmount, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting bitcircleAmount: ", args[1])))
+		}
+		description := ""
+		if len(args) > 2 {
+			description = args[2]
+		}
+		return t.MintBitcircles(stub, participantID, bitcircleAmount, description)
+	case "BurnBitcircles":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\", \"BitcircleAmount\" and optionally \"Description\" as arguments"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		bitcircleAmount, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting bitcircleAmount: ", args[1])))
+		}
+		description := ""
+		if len(args) > 2 {
+			description = args[2]
+		}
+		return t.BurnBitcircles(stub, participantID, bitcircleAmount, description)
+	case "GetBitcircleSupply":
+		return t.GetBitcircleSupply(stub)
 	case "GetParticipantWalletById":
 		if len(args) < 1 {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"UserId\" as an argument"))
@@ -306,6 +992,141 @@ func (t *AuctionSmartContract) Invoke(stub shim.ChaincodeStubInterface) pb.Respo
 			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
 		}
 		return t.GetParticipantWalletById(stub, userID)
+	case "SweepExpiredRewards":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" as an argument"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.SweepExpiredRewards(stub, participantID)
+	case "FreezeWallet":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"ParticipantId\", \"UserId\" and \"Reason\""))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		userID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[1])))
+		}
+		return t.FreezeWallet(stub, participantID, userID, args[2])
+	case "UnfreezeWallet":
+		if len(args) < 2 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 2 arguments: \"ParticipantId\" and \"UserId\""))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		userID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[1])))
+		}
+		return t.UnfreezeWallet(stub, participantID, userID)
+	case "PlaceWalletHold":
+		if len(args) < 4 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 4 arguments: \"ParticipantId\", \"UserId\", \"BitcircleAmount\", \"Reason\" and optionally \"ExpiresAt\""))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		userID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[1])))
+		}
+		bitcircleAmount, err := strconv.Atoi(args[2])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting bitcircleAmount: ", args[2])))
+		}
+		var expiresAt *time.Time
+		if len(args) > 4 && args[4] != "" {
+			date, err := time.Parse(time.RFC3339, args[4])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+			}
+			expiresAt = &date
+		}
+		return t.PlaceWalletHold(stub, participantID, userID, bitcircleAmount, args[3], expiresAt)
+	case "ReleaseWalletHold":
+		if len(args) < 3 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting 3 arguments: \"ParticipantId\", \"UserId\" and \"HoldId\""))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		userID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[1])))
+		}
+		return t.ReleaseWalletHold(stub, participantID, userID, args[2])
+	case "GetWalletEscrows":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"UserId\" and optionally \"State\" as arguments"))
+		}
+		userID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		state := ""
+		if len(args) > 1 {
+			state = args[1]
+		}
+		return t.GetWalletEscrows(stub, userID, models.EscrowState(state))
+	case "GetWalletStatement":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"UserId\" and optionally \"From\", \"To\", \"PageSize\" and \"Bookmark\" as arguments"))
+		}
+		userID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		var dates [2]*time.Time
+		for i := 0; i < 2 && len(args) > i+1; i++ {
+			if args[i+1] == "" {
+				continue
+			}
+			date, err := time.Parse(time.RFC3339, args[i+1])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+			}
+			dates[i] = &date
+		}
+		pageSize := 0
+		if len(args) > 3 && args[3] != "" {
+			pageSize, err = strconv.Atoi(args[3])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting PageSize: ", args[3])))
+			}
+		}
+		bookmark := ""
+		if len(args) > 4 {
+			bookmark = args[4]
+		}
+		return t.GetWalletStatement(stub, userID, dates[0], dates[1], pageSize, bookmark)
+	case "ReconcileWallet":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"UserId\" as an argument"))
+		}
+		userID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.ReconcileWallet(stub, userID)
+	case "IndexBitcircleTransactions":
+		if len(args) < 1 {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"ParticipantId\" as an argument"))
+		}
+		participantID, err := strconv.Atoi(args[0])
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Error getting Participant id: ", args[0])))
+		}
+		return t.IndexBitcircleTransactions(stub, participantID)
 	case "GetParticipantBitCircleTransactions":
 		if len(args) < 1 {
 			return shim.Success(createErrorResponse(http.StatusBadRequest, "Expecting \"UserId\" as an argument"))
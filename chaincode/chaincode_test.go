@@ -0,0 +1,387 @@
+package micolec
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"micolec/chaincode/models"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ** -----------------------------------------------------
+// ** TEST CONTRACT (mock stub with a clock, key history and callers)
+// ** -> START
+// ** -----------------------------------------------------
+
+// What the mock stub does not keep between the invocations of a test: the time
+// of the transactions and the history of the keys
+type testLedger struct {
+	now     time.Time
+	history map[string][]*queryresult.KeyModification
+}
+
+type testChaincode struct {
+	AuctionSmartContract
+	ledger *testLedger
+}
+
+func (c *testChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return c.AuctionSmartContract.Init(&testStub{ChaincodeStubInterface: stub, ledger: c.ledger})
+}
+
+func (c *testChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return c.AuctionSmartContract.Invoke(&testStub{ChaincodeStubInterface: stub, ledger: c.ledger})
+}
+
+type testStub struct {
+	shim.ChaincodeStubInterface
+	ledger *testLedger
+}
+
+func (s *testStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.ledger.now), nil
+}
+
+func (s *testStub) recordHistory(key string, value []byte) {
+	s.ledger.history[key] = append(s.ledger.history[key], &queryresult.KeyModification{
+		TxId:      s.GetTxID(),
+		Value:     value,
+		Timestamp: timestamppb.New(s.ledger.now),
+		IsDelete:  len(value) == 0,
+	})
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	err := s.ChaincodeStubInterface.PutState(key, value)
+	if err == nil {
+		s.recordHistory(key, value)
+	}
+	return err
+}
+
+func (s *testStub) DelState(key string) error {
+	err := s.ChaincodeStubInterface.DelState(key)
+	if err == nil {
+		s.recordHistory(key, nil)
+	}
+	return err
+}
+
+// Newest modification first, as the peer returns them
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	history := s.ledger.history[key]
+	modifications := make([]*queryresult.KeyModification, len(history))
+	for i, modification := range history {
+		modifications[len(history)-1-i] = modification
+	}
+	return &testHistoryIterator{modifications: modifications}, nil
+}
+
+// The bookmark is the key the next page starts at
+func (s *testStub) GetStateByRangeWithPagination(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	iterator, err := s.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	page := &testStateIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if int32(len(page.records)) == pageSize {
+			metadata.Bookmark = record.Key
+			break
+		}
+		page.records = append(page.records, record)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.records))
+
+	return page, metadata, nil
+}
+
+type testStateIterator struct {
+	records []*queryresult.KV
+	next    int
+}
+
+func (it *testStateIterator) HasNext() bool { return it.next < len(it.records) }
+func (it *testStateIterator) Close() error  { return nil }
+func (it *testStateIterator) Next() (*queryresult.KV, error) {
+	it.next = it.next + 1
+	return it.records[it.next-1], nil
+}
+
+type testHistoryIterator struct {
+	modifications []*queryresult.KeyModification
+	next          int
+}
+
+func (it *testHistoryIterator) HasNext() bool { return it.next < len(it.modifications) }
+func (it *testHistoryIterator) Close() error  { return nil }
+func (it *testHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	it.next = it.next + 1
+	return it.modifications[it.next-1], nil
+}
+
+var testStartTime = time.Date(2023, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+// Contract under test, every invocation runs one second after the previous one
+type testContract struct {
+	t       *testing.T
+	stub    *shimtest.MockStub
+	ledger  *testLedger
+	callers map[int][]byte
+	txs     int
+}
+
+// Contract with the platform as caller
+func newTestContract(t *testing.T) *testContract {
+	ledger := &testLedger{now: testStartTime, history: map[string][]*queryresult.KeyModification{}}
+	c := &testContract{
+		t:       t,
+		stub:    shimtest.NewMockStub("micolec", &testChaincode{ledger: ledger}),
+		ledger:  ledger,
+		callers: map[int][]byte{},
+	}
+
+	response := c.stub.MockInit("init", nil)
+	require.Equal(t, int32(shim.OK), response.Status, response.Message)
+
+	return c.as(PlatformWalletId)
+}
+
+// The next invocations are signed by the participant
+func (c *testContract) as(participantID int) *testContract {
+	creator, found := c.callers[participantID]
+	if !found {
+		creator = testCreator(c.t, participantID)
+		c.callers[participantID] = creator
+	}
+	c.stub.Creator = creator
+	return c
+}
+
+// The next invocations are signed by an identity without participant id
+func (c *testContract) anonymous() *testContract {
+	c.stub.Creator = nil
+	return c
+}
+
+// The next invocation runs at the given time
+func (c *testContract) at(when time.Time) *testContract {
+	c.ledger.now = when.Add(-time.Second)
+	return c
+}
+
+func (c *testContract) after(duration time.Duration) *testContract {
+	return c.at(c.ledger.now.Add(duration))
+}
+
+func (c *testContract) invoke(args ...string) pb.Response {
+	c.txs = c.txs + 1
+	c.ledger.now = c.ledger.now.Add(time.Second)
+
+	byteArgs := make([][]byte, len(args))
+	for i, arg := range args {
+		byteArgs[i] = []byte(arg)
+	}
+	return c.stub.MockInvoke(fmt.Sprint("tx", c.txs), byteArgs)
+}
+
+// Invokes the function and decodes its response, which must not be an error
+func (c *testContract) ok(out interface{}, args ...string) {
+	c.t.Helper()
+	response := c.invoke(args...)
+	require.Equal(c.t, int32(shim.OK), response.Status, "%s: %s", args[0], response.Message)
+
+	var errorResponse ErrorResponse
+	_ = json.Unmarshal(response.Payload, &errorResponse)
+	require.Zero(c.t, errorResponse.ErrorCode, "%s: %s", args[0], errorResponse.ErrorMessage)
+
+	if out != nil {
+		require.NoError(c.t, json.Unmarshal(response.Payload, out), "%s: %s", args[0], response.Payload)
+	}
+}
+
+// Invokes the function, which must answer the error code, and returns its message
+func (c *testContract) fails(errorCode int, args ...string) string {
+	c.t.Helper()
+	response := c.invoke(args...)
+	require.Equal(c.t, int32(shim.OK), response.Status, "%s: %s", args[0], response.Message)
+
+	var errorResponse ErrorResponse
+	require.NoError(c.t, json.Unmarshal(response.Payload, &errorResponse), "%s: %s", args[0], response.Payload)
+	require.Equal(c.t, errorCode, errorResponse.ErrorCode, "%s: %s", args[0], errorResponse.ErrorMessage)
+
+	return errorResponse.ErrorMessage
+}
+
+// Reads an entity from the world state, false when it does not exist
+func (c *testContract) entity(entity Entity, out interface{}, ids ...string) bool {
+	c.t.Helper()
+	key, err := c.stub.CreateCompositeKey(string(entity), ids)
+	require.NoError(c.t, err)
+
+	value, found := c.stub.State[key]
+	if !found {
+		return false
+	}
+	require.NoError(c.t, json.Unmarshal(value, out))
+	return true
+}
+
+func (c *testContract) wallet(participantID int) models.Wallet {
+	c.t.Helper()
+	var wallet models.Wallet
+	c.ok(&wallet, "GetParticipantWalletById", fmt.Sprint(participantID))
+	return wallet
+}
+
+// Mints the Bitcircles the wallets are granted and creates a wallet for each
+// participant with its grant
+func (c *testContract) wallets(grants map[int]int) *testContract {
+	c.t.Helper()
+	total := 0
+	for _, grant := range grants {
+		total = total + grant
+	}
+	c.ok(nil, "MintBitcircles", fmt.Sprint(PlatformWalletId), fmt.Sprint(total+1000))
+
+	for participantID, grant := range grants {
+		c.ok(nil, "CreateParticipantWallet", toJSON(c.t, map[string]interface{}{"participant_id": participantID}), fmt.Sprint(grant))
+	}
+	return c
+}
+
+const testOperatorID = 9
+
+// Pending parcel of the test operator from area 1000 to area 2000, with the fields
+// overridden
+func (c *testContract) parcel(id int, fields map[string]interface{}) *testContract {
+	c.t.Helper()
+	parcel := map[string]interface{}{
+		"id":                     id,
+		"state":                  "Pending",
+		"added_to_platform":      c.ledger.now,
+		"required_delivery_date": c.ledger.now.Add(48 * time.Hour),
+		"pickup_postal_area":     "1000",
+		"delivery_postal_area":   "2000",
+		"bitcircle_reward":       10,
+		"weight":                 "2",
+		"volume":                 1,
+		"logistic_operator_id":   testOperatorID,
+		"end_customer_id":        11,
+	}
+	for field, value := range fields {
+		parcel[field] = value
+	}
+	c.ok(nil, "ParcelDeliveryParcelAdded", toJSON(c.t, parcel))
+	return c
+}
+
+// Open standard auction of the test operator for the parcels, ending in five
+// hours, with the fields overridden
+func (c *testContract) auction(id string, parcelIDs []int, fields map[string]interface{}) *testContract {
+	c.t.Helper()
+	auction := map[string]interface{}{
+		"id":                          id,
+		"start_date":                  c.ledger.now.Add(-time.Hour),
+		"end_date":                    c.ledger.now.Add(5 * time.Hour),
+		"maximum_accepted_licitation": 10000,
+		"currency":                    "EUR",
+		"participant_id":              testOperatorID,
+	}
+	for field, value := range fields {
+		auction[field] = value
+	}
+
+	auctionHasParcels := []map[string]interface{}{}
+	for _, parcelID := range parcelIDs {
+		auctionHasParcels = append(auctionHasParcels, map[string]interface{}{"auction_id": id, "parcel_id": parcelID})
+	}
+	c.ok(nil, "ParcelDeliveryAuctionStart", toJSON(c.t, auctionHasParcels), toJSON(c.t, auction))
+	return c
+}
+
+// The courier places the bid, and the auction is closed once it expired
+func (c *testContract) award(bidID string, auctionID string, moneyAmount string, bitcircleAmount string, courierID string) *testContract {
+	c.t.Helper()
+	c.ok(nil, "ParcelDeliveryBidingRequest", bidID, auctionID, moneyAmount, bitcircleAmount, courierID)
+	c.after(6*time.Hour).ok(nil, "CloseExpiredAuctions", auctionID)
+	return c
+}
+
+// Couriers 2 and 3 with 100 Bitcircles, and auction A1 of parcel 1 awarded to
+// courier 3 for the amount, with the auction fields overridden
+func newAwardedContract(t *testing.T, moneyAmount string, auctionFields map[string]interface{}) *testContract {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	return c.auction("A1", []int{1}, auctionFields).award("b1", "A1", moneyAmount, "0", "3")
+}
+
+func toJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// Serialized identity with a self-signed enrollment certificate that carries the
+// participant id attribute
+func testCreator(t *testing.T, participantID int) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	attrs, err := json.Marshal(map[string]interface{}{
+		"attrs": map[string]string{ParticipantIDAttribute: fmt.Sprint(participantID)},
+	})
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(participantID) + 1),
+		Subject:      pkix.Name{CommonName: fmt.Sprint("participant", participantID)},
+		NotBefore:    testStartTime.AddDate(-1, 0, 0),
+		NotAfter:     testStartTime.AddDate(1, 0, 0),
+		// Attributes extension of the Fabric CA
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attrs}},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	// msp.SerializedIdentity: mspid = 1, id_bytes = 2
+	var identity []byte
+	identity = protowire.AppendTag(identity, 1, protowire.BytesType)
+	identity = protowire.AppendString(identity, "Org1MSP")
+	identity = protowire.AppendTag(identity, 2, protowire.BytesType)
+	identity = protowire.AppendBytes(identity, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}))
+	return identity
+}
+
+// ** -----------------------------------------------------
+// ** TEST CONTRACT (mock stub with a clock, key history and callers)
+// ** -> END
+// ** -----------------------------------------------------
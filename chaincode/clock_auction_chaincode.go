@@ -0,0 +1,235 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** CLOCK AUCTION (reverse dutch)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["AcceptClockAuctionPrice", "10", "5", "20", "4"]}'
+*/
+
+// The offered price starts at ClockStartPrice and rises ClockStepAmount every
+// ClockStepMinutes, capped at MaximumAcceptedLicitation.
+func getClockPrice(auction models.Auction, currentTime time.Time) int64 {
+	if currentTime.Before(auction.StartDate) {
+		return auction.ClockStartPrice
+	}
+
+	steps := int(currentTime.Sub(auction.StartDate) / (time.Duration(auction.ClockStepMinutes) * time.Minute))
+	price := auction.ClockStartPrice + int64(steps)*auction.ClockStepAmount
+	if price > auction.MaximumAcceptedLicitation {
+		price = auction.MaximumAcceptedLicitation
+	}
+
+	return price
+}
+
+func (s *AuctionSmartContract) readClockAuction(stub shim.ChaincodeStubInterface, auctionID string) (models.Auction, string, error) {
+	var auction models.Auction
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return auction, auctionKey, err
+	}
+
+	auctionJSON, err := s.ReadEntity(stub, auctionKey)
+	if err != nil {
+		return auction, auctionKey, err
+	}
+
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return auction, auctionKey, err
+	}
+
+	if auction.Type != models.AuctionTypeClock {
+		return auction, auctionKey, fmt.Errorf("Auction %s is not a clock auction", auctionID)
+	}
+
+	return auction, auctionKey, nil
+}
+
+func (s *AuctionSmartContract) GetAuctionClockPrice(stub shim.ChaincodeStubInterface, auctionID string) pb.Response {
+	auction, _, err := s.readClockAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	visible, err := auctionIsVisibleToCaller(stub, auction)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !visible {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprintf("auction %v does not exist", auctionID)))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var response struct {
+		AuctionID    string              `json:"auction_id"`
+		State        models.AuctionState `json:"state"`
+		CurrentPrice int64               `json:"current_price"`
+		NextPrice    int64               `json:"next_price"`
+		Currency     string              `json:"currency"`
+		NextStepDate time.Time           `json:"next_step_date"`
+	}
+
+	stepDuration := time.Duration(auction.ClockStepMinutes) * time.Minute
+	nextStepDate := auction.StartDate
+	if !currentTime.Before(auction.StartDate) {
+		nextStepDate = auction.StartDate.Add((currentTime.Sub(auction.StartDate)/stepDuration + 1) * stepDuration)
+	}
+
+	response.AuctionID = auction.ID
+	response.State = auction.State
+	response.CurrentPrice = getClockPrice(auction, currentTime)
+	response.NextPrice = getClockPrice(auction, nextStepDate)
+	response.Currency = auction.Currency
+	response.NextStepDate = nextStepDate
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// The first courier to accept the current clock price wins the auction: the bid is
+// stored as the winner, the Bitcircles are charged and the parcels move to Delivery.
+// Everything is checked before the first write, a failure after it fails the
+// transaction so that none of its writes are committed.
+func (s *AuctionSmartContract) AcceptClockAuctionPrice(stub shim.ChaincodeStubInterface, bidID string, auctionID string, bitcircleAmount int, courierID int) pb.Response {
+	fmt.Println("AcceptClockAuctionPrice Invoke")
+
+	if bitcircleAmount < 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The bitcircle ammount most be higher or equal than 0"))
+	}
+
+	auction, _, err := s.readClockAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	err = checkCourierAuctionAccess(stub, auction, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if auction.State != models.AuctionOpen || auction.EndDate.Before(currentTime) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "This auction is already closed"))
+	}
+
+	if currentTime.Before(auction.StartDate) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "This auction has not started yet"))
+	}
+
+	bidCompositeKey, err := s.CreateCompositeKey(stub, EntityBid, []string{bidID, auctionID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	bidExists, err := s.EntityRecordExists(stub, bidCompositeKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if bidExists {
+		return shim.Success(createErrorResponse(http.StatusConflict, "Record Already Exists"))
+	}
+
+	if !canTransitionAuction(auction.State, models.AuctionAwarded) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("Auction %s cannot move from '%s' to '%s'", auction.ID, auction.State, models.AuctionAwarded)))
+	}
+
+	err = s.VerifyWalletAmount(stub, courierID, bitcircleAmount)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	bid := models.Bid{
+		ID:              bidID,
+		Date:            currentTime,
+		BitcircleAmount: bitcircleAmount,
+		MoneyAmount:     getClockPrice(auction, currentTime),
+		Currency:        auction.Currency,
+		Status:          models.BitStatusLowerBid,
+		Winner:          true,
+		AuctionID:       auctionID,
+		CourierID:       courierID,
+	}
+
+	jsonDataBid, err := json.Marshal(bid)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, bidCompositeKey, jsonDataBid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// The Bitcircles were never reserved by a bid, so they also leave the usable balance
+	description := fmt.Sprint("Auction ", auction.ID, " payment.")
+	err = s.postBitcircles(stub, models.LedgerReasonAuctionPayment, availableAccount(courierID), availableAccount(PlatformWalletId), bitcircleAmount, LedgerReferenceAuction, auction.ID, description)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = s.recordSettlement(stub, auction, bid)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	err = s.transitionAuction(stub, &auction, models.AuctionAwarded, courierID, fmt.Sprint("Clock price accepted by courier ", courierID))
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	parcels, err := s.updateAuctionParcelsState(stub, auction.ID, models.ParcelStateDelivery, courierID, courierID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var response struct {
+		AuctionID string               `json:"auction"`
+		Deliverer int                  `json:"deliverer_id"`
+		Bid       models.Bid           `json:"bid"`
+		Parcels   []auctionParcelState `json:"parcels"`
+	}
+	response.AuctionID = auction.ID
+	response.Deliverer = courierID
+	response.Bid = bid
+	response.Parcels = parcels
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** CLOCK AUCTION (reverse dutch)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,101 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetClockPrice(t *testing.T) {
+	auction := models.Auction{
+		StartDate:                 testStartTime,
+		MaximumAcceptedLicitation: 3000,
+		ClockStartPrice:           1000,
+		ClockStepAmount:           500,
+		ClockStepMinutes:          30,
+	}
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		price int64
+	}{
+		{"before the start", -time.Hour, 1000},
+		{"at the start", 0, 1000},
+		{"within the first step", 29 * time.Minute, 1000},
+		{"after one step", 30 * time.Minute, 1500},
+		{"after two steps", 65 * time.Minute, 2000},
+		{"capped at the maximum", 10 * time.Hour, 3000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.price, getClockPrice(auction, testStartTime.Add(test.after)))
+		})
+	}
+}
+
+func TestAcceptClockAuctionPrice(t *testing.T) {
+	tests := []struct {
+		name       string
+		before     func(c *testContract)
+		bitcircles string
+		code       int
+	}{
+		{"accepted at the current price", nil, "5", 0},
+		{"negative Bitcircles", nil, "-1", http.StatusBadRequest},
+		{"more Bitcircles than the wallet has", nil, "500", http.StatusBadRequest},
+		{"after the end date", func(c *testContract) { c.after(6 * time.Hour) }, "5", http.StatusBadRequest},
+		{"already accepted", func(c *testContract) {
+			c.ok(nil, "AcceptClockAuctionPrice", "first", "C1", "0", "3")
+		}, "5", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+			c.auction("C1", []int{1}, map[string]interface{}{
+				"type":               models.AuctionTypeClock,
+				"start_date":         c.ledger.now.Add(-65 * time.Minute),
+				"clock_start_price":  1000,
+				"clock_step_amount":  500,
+				"clock_step_minutes": 30,
+			})
+			if test.before != nil {
+				test.before(c)
+			}
+
+			var bid models.Bid
+			if test.code != 0 {
+				c.fails(test.code, "AcceptClockAuctionPrice", "cb1", "C1", test.bitcircles, "2")
+
+				// Nothing is written when the price is not accepted
+				require.False(t, c.entity(EntityBid, &bid, "cb1", "C1"))
+				require.Equal(t, 100, c.wallet(2).Balance)
+				return
+			}
+
+			var response struct {
+				Deliverer int        `json:"deliverer_id"`
+				Bid       models.Bid `json:"bid"`
+			}
+			c.ok(&response, "AcceptClockAuctionPrice", "cb1", "C1", test.bitcircles, "2")
+			require.Equal(t, 2, response.Deliverer)
+			require.Equal(t, int64(2000), response.Bid.MoneyAmount)
+			require.True(t, response.Bid.Winner)
+
+			var auction models.Auction
+			require.True(t, c.entity(EntityAuction, &auction, "C1"))
+			require.Equal(t, models.AuctionAwarded, auction.State)
+
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, models.ParcelStateDelivery, parcel.State)
+
+			require.Equal(t, 95, c.wallet(2).Balance)
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** COURIER ACCESS (auction invites and operator denylists)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["InviteCouriersToAuction", "2", "1", "[4,7]"]}'
+*/
+
+func courierIsDenied(stub shim.ChaincodeStubInterface, logisticOperatorID int, courierID int) (bool, error) {
+	deniedKey, err := stub.CreateCompositeKey(string(EntityOperatorDenylist), []string{fmt.Sprint(logisticOperatorID), fmt.Sprint(courierID)})
+	if err != nil {
+		return false, err
+	}
+	deniedJSON, err := stub.GetState(deniedKey)
+	if err != nil {
+		return false, err
+	}
+	return deniedJSON != nil, nil
+}
+
+func courierIsInvited(stub shim.ChaincodeStubInterface, auctionID string, courierID int) (bool, error) {
+	inviteKey, err := stub.CreateCompositeKey(string(EntityAuctionInvite), []string{auctionID, fmt.Sprint(courierID)})
+	if err != nil {
+		return false, err
+	}
+	inviteJSON, err := stub.GetState(inviteKey)
+	if err != nil {
+		return false, err
+	}
+	return inviteJSON != nil, nil
+}
+
+// Returns an error when the courier is denied by the auction operator or the
+// auction is invite-only and the courier was not invited
+func checkCourierAuctionAccess(stub shim.ChaincodeStubInterface, auction models.Auction, courierID int) error {
+	denied, err := courierIsDenied(stub, auction.ParticipantId, courierID)
+	if err != nil {
+		return err
+	}
+	if denied {
+		return fmt.Errorf("You are not allowed to bid on the auctions of this logistic operator")
+	}
+
+	if auction.InviteOnly {
+		invited, err := courierIsInvited(stub, auction.ID, courierID)
+		if err != nil {
+			return err
+		}
+		if !invited {
+			return fmt.Errorf("This auction is invite-only and you were not invited")
+		}
+	}
+
+	return nil
+}
+
+// Invite-only auctions are shown to the platform (participant 0), to the owning
+// logistic operator and to the invited couriers, as told by the identity of the
+// caller. Callers without a participant id only see the other auctions.
+func auctionIsVisibleToCaller(stub shim.ChaincodeStubInterface, auction models.Auction) (bool, error) {
+	if !auction.InviteOnly {
+		return true, nil
+	}
+
+	callerID, err := getCallerParticipantID(stub)
+	if err != nil {
+		return false, nil
+	}
+	if callerID == PlatformWalletId || callerID == auction.ParticipantId {
+		return true, nil
+	}
+	return courierIsInvited(stub, auction.ID, callerID)
+}
+
+// The records of an auction (bids, transitions) are visible with the auction,
+// those of an auction that no longer exists stay visible
+func auctionIDIsVisibleToCaller(stub shim.ChaincodeStubInterface, auctionID string) (bool, error) {
+	auctionKey, err := stub.CreateCompositeKey(string(EntityAuction), []string{auctionID})
+	if err != nil {
+		return false, err
+	}
+
+	auctionJSON, err := stub.GetState(auctionKey)
+	if err != nil {
+		return false, err
+	}
+	if auctionJSON == nil {
+		return true, nil
+	}
+
+	var auction models.Auction
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return false, err
+	}
+	return auctionIsVisibleToCaller(stub, auction)
+}
+
+// Leaves out the bids on auctions the caller can not see
+func filterVisibleBids(stub shim.ChaincodeStubInterface, bids []models.Bid) ([]models.Bid, error) {
+	visibleAuctions := make(map[string]bool)
+	var visibleBids []models.Bid
+	for _, bid := range bids {
+		visible, found := visibleAuctions[bid.AuctionID]
+		if !found {
+			var err error
+			visible, err = auctionIDIsVisibleToCaller(stub, bid.AuctionID)
+			if err != nil {
+				return nil, err
+			}
+			visibleAuctions[bid.AuctionID] = visible
+		}
+
+		if visible {
+			visibleBids = append(visibleBids, bid)
+		}
+	}
+	return visibleBids, nil
+}
+
+// An auction the caller can not see is reported as missing
+func (s *AuctionSmartContract) readVisibleAuction(stub shim.ChaincodeStubInterface, auctionID string) (models.Auction, error) {
+	var auction models.Auction
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return auction, err
+	}
+
+	auctionJSON, err := stub.GetState(auctionKey)
+	if err != nil {
+		return auction, err
+	}
+	if auctionJSON == nil {
+		return auction, fmt.Errorf("auction %v does not exist", auctionID)
+	}
+
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return auction, err
+	}
+
+	visible, err := auctionIsVisibleToCaller(stub, auction)
+	if err != nil {
+		return auction, err
+	}
+	if !visible {
+		return auction, fmt.Errorf("auction %v does not exist", auctionID)
+	}
+	return auction, nil
+}
+
+func (s *AuctionSmartContract) readOperatorAuction(stub shim.ChaincodeStubInterface, logisticOperatorID int, auctionID string) (models.Auction, error) {
+	var auction models.Auction
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return auction, err
+	}
+
+	auctionJSON, err := s.ReadEntity(stub, auctionKey)
+	if err != nil {
+		return auction, err
+	}
+
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return auction, err
+	}
+
+	if auction.ParticipantId != logisticOperatorID {
+		return auction, fmt.Errorf("The auction %s does not belong to the logistic operator %d", auctionID, logisticOperatorID)
+	}
+
+	return auction, nil
+}
+
+func (s *AuctionSmartContract) InviteCouriersToAuction(stub shim.ChaincodeStubInterface, logisticOperatorID int, auctionID string, courierIDs []int) pb.Response {
+	fmt.Println("InviteCouriersToAuction Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	auction, err := s.readOperatorAuction(stub, logisticOperatorID, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	if len(courierIDs) == 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "No courier selected to invite"))
+	}
+
+	// Every courier is checked before the first invite is written
+	for _, courierID := range courierIDs {
+		denied, err := courierIsDenied(stub, auction.ParticipantId, courierID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		if denied {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The courier ", courierID, " is on your denylist")))
+		}
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var invites []models.AuctionInvite
+	for _, courierID := range courierIDs {
+		invite := models.AuctionInvite{
+			AuctionID: auction.ID,
+			CourierID: courierID,
+			InvitedBy: logisticOperatorID,
+			Date:      currentTime,
+		}
+
+		inviteKey, err := s.CreateCompositeKey(stub, EntityAuctionInvite, []string{auction.ID, fmt.Sprint(courierID)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		dataInvite, err := json.Marshal(invite)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		_, err = s.UpsertEntityRecord(stub, inviteKey, dataInvite)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		invites = append(invites, invite)
+	}
+
+	invitesJSON, err := json.Marshal(invites)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(invitesJSON)
+}
+
+func (s *AuctionSmartContract) RevokeAuctionInvite(stub shim.ChaincodeStubInterface, logisticOperatorID int, auctionID string, courierID int) pb.Response {
+	fmt.Println("RevokeAuctionInvite Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	auction, err := s.readOperatorAuction(stub, logisticOperatorID, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	inviteKey, err := s.CreateCompositeKey(stub, EntityAuctionInvite, []string{auction.ID, fmt.Sprint(courierID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	exists, err := s.EntityRecordExists(stub, inviteKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The courier ", courierID, " is not invited to the auction ", auction.ID)))
+	}
+
+	err = stub.DelState(inviteKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(nil)
+}
+
+func (s *AuctionSmartContract) GetAuctionInvites(stub shim.ChaincodeStubInterface, logisticOperatorID int, auctionID string) pb.Response {
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	auction, err := s.readOperatorAuction(stub, logisticOperatorID, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityAuctionInvite), []string{auction.ID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var invites []models.AuctionInvite
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var invite models.AuctionInvite
+		err = json.Unmarshal(response.Value, &invite)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		invites = append(invites, invite)
+	}
+
+	invitesJSON, err := json.Marshal(invites)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(invitesJSON)
+}
+
+func (s *AuctionSmartContract) DenyCourier(stub shim.ChaincodeStubInterface, logisticOperatorID int, courierID int, reason string) pb.Response {
+	fmt.Println("DenyCourier Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	if logisticOperatorID == courierID {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "A logistic operator cannot deny itself"))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	deniedCourier := models.DeniedCourier{
+		LogisticOperatorID: logisticOperatorID,
+		CourierID:          courierID,
+		Reason:             reason,
+		Date:               currentTime,
+	}
+
+	deniedKey, err := s.CreateCompositeKey(stub, EntityOperatorDenylist, []string{fmt.Sprint(logisticOperatorID), fmt.Sprint(courierID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataDeniedCourier, err := json.Marshal(deniedCourier)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, deniedKey, dataDeniedCourier)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataDeniedCourier)
+}
+
+func (s *AuctionSmartContract) AllowCourier(stub shim.ChaincodeStubInterface, logisticOperatorID int, courierID int) pb.Response {
+	fmt.Println("AllowCourier Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	deniedKey, err := s.CreateCompositeKey(stub, EntityOperatorDenylist, []string{fmt.Sprint(logisticOperatorID), fmt.Sprint(courierID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	exists, err := s.EntityRecordExists(stub, deniedKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The courier ", courierID, " is not on your denylist")))
+	}
+
+	err = stub.DelState(deniedKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(nil)
+}
+
+func (s *AuctionSmartContract) GetOperatorDenylist(stub shim.ChaincodeStubInterface, logisticOperatorID int) pb.Response {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityOperatorDenylist), []string{fmt.Sprint(logisticOperatorID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var deniedCouriers []models.DeniedCourier
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var deniedCourier models.DeniedCourier
+		err = json.Unmarshal(response.Value, &deniedCourier)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		deniedCouriers = append(deniedCouriers, deniedCourier)
+	}
+
+	deniedCouriersJSON, err := json.Marshal(deniedCouriers)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(deniedCouriersJSON)
+}
+
+// ** -----------------------------------------------------
+// ** COURIER ACCESS (auction invites and operator denylists)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,209 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Public auction A1 and invite-only auction A2 of the test operator, courier 3
+// invited to A2 and courier 4 denied
+func newCourierAccessContract(t *testing.T) *testContract {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100, 4: 100}).parcel(1, nil).parcel(2, nil)
+	c.auction("A1", []int{1}, nil)
+	c.auction("A2", []int{2}, map[string]interface{}{"invite_only": true})
+
+	c.as(testOperatorID).ok(nil, "InviteCouriersToAuction", fmt.Sprint(testOperatorID), "A2", "[3]")
+	c.ok(nil, "DenyCourier", fmt.Sprint(testOperatorID), "4", "Damaged parcels")
+	return c.as(PlatformWalletId)
+}
+
+func TestCheckCourierAuctionAccess(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    func(c *testContract)
+		auctionID string
+		courierID string
+		code      int
+	}{
+		{"public auction", nil, "A1", "2", 0},
+		{"denied courier", nil, "A1", "4", http.StatusForbidden},
+		{"allowed again", func(c *testContract) {
+			c.as(testOperatorID).ok(nil, "AllowCourier", fmt.Sprint(testOperatorID), "4")
+		}, "A1", "4", 0},
+		{"invited courier", nil, "A2", "3", 0},
+		{"courier not invited", nil, "A2", "2", http.StatusForbidden},
+		{"invite revoked", func(c *testContract) {
+			c.as(testOperatorID).ok(nil, "RevokeAuctionInvite", fmt.Sprint(testOperatorID), "A2", "3")
+		}, "A2", "3", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCourierAccessContract(t)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			args := []string{"ParcelDeliveryBidingRequest", "b1", test.auctionID, "50.00", "0", test.courierID}
+			if test.code != 0 {
+				c.fails(test.code, args...)
+				return
+			}
+			c.ok(nil, args...)
+		})
+	}
+}
+
+func TestCourierAccessCallers(t *testing.T) {
+	tests := []struct {
+		name     string
+		callerID int
+		args     []string
+	}{
+		{"courier inviting itself", 2, []string{"InviteCouriersToAuction", fmt.Sprint(testOperatorID), "A2", "[2]"}},
+		{"courier revoking an invite", 2, []string{"RevokeAuctionInvite", fmt.Sprint(testOperatorID), "A2", "3"}},
+		{"courier denying another", 2, []string{"DenyCourier", fmt.Sprint(testOperatorID), "3", "Competition"}},
+		{"denied courier allowing itself", 4, []string{"AllowCourier", fmt.Sprint(testOperatorID), "4"}},
+		{"another operator allowing the courier", 8, []string{"AllowCourier", fmt.Sprint(testOperatorID), "4"}},
+		{"courier reading the invites", 2, []string{"GetAuctionInvites", fmt.Sprint(testOperatorID), "A2"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCourierAccessContract(t)
+			c.as(test.callerID).fails(http.StatusForbidden, test.args...)
+
+			// The invites and the denylist are left as they were
+			var invites []models.AuctionInvite
+			c.as(testOperatorID).ok(&invites, "GetAuctionInvites", fmt.Sprint(testOperatorID), "A2")
+			require.Len(t, invites, 1)
+			require.Equal(t, 3, invites[0].CourierID)
+			var deniedCouriers []models.DeniedCourier
+			c.ok(&deniedCouriers, "GetOperatorDenylist", fmt.Sprint(testOperatorID))
+			require.Len(t, deniedCouriers, 1)
+			require.Equal(t, 4, deniedCouriers[0].CourierID)
+		})
+	}
+}
+
+func TestInviteCouriersToAuction(t *testing.T) {
+	tests := []struct {
+		name       string
+		courierIDs string
+		code       int
+		invited    []int
+	}{
+		{"several couriers", "[2,5]", 0, []int{2, 3, 5}},
+		{"a denied courier among them", "[2,4]", http.StatusBadRequest, []int{3}},
+		{"nobody", "[]", http.StatusBadRequest, []int{3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCourierAccessContract(t).as(testOperatorID)
+			if test.code != 0 {
+				c.fails(test.code, "InviteCouriersToAuction", fmt.Sprint(testOperatorID), "A2", test.courierIDs)
+			} else {
+				c.ok(nil, "InviteCouriersToAuction", fmt.Sprint(testOperatorID), "A2", test.courierIDs)
+			}
+
+			// Either every courier is invited or none
+			var invites []models.AuctionInvite
+			c.ok(&invites, "GetAuctionInvites", fmt.Sprint(testOperatorID), "A2")
+			invited := []int{}
+			for _, invite := range invites {
+				invited = append(invited, invite.CourierID)
+			}
+			require.Equal(t, test.invited, invited)
+		})
+	}
+}
+
+func TestAuctionIsVisibleToCaller(t *testing.T) {
+	tests := []struct {
+		name     string
+		callerID *int
+		visible  bool
+	}{
+		{"platform", intPointer(PlatformWalletId), true},
+		{"owning operator", intPointer(testOperatorID), true},
+		{"invited courier", intPointer(3), true},
+		{"courier not invited", intPointer(2), false},
+		{"caller without participant id", nil, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newCourierAccessContract(t).parcel(3, nil)
+			c.auction("C1", []int{3}, map[string]interface{}{
+				"type":               models.AuctionTypeClock,
+				"invite_only":        true,
+				"clock_start_price":  1000,
+				"clock_step_amount":  500,
+				"clock_step_minutes": 30,
+			})
+			c.as(testOperatorID).ok(nil, "InviteCouriersToAuction", fmt.Sprint(testOperatorID), "C1", "[3]")
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A2", "50.00", "0", "3")
+			if test.callerID == nil {
+				c.anonymous()
+			} else {
+				c.as(*test.callerID)
+			}
+
+			var auctions []struct {
+				Auction models.Auction `json:"auction"`
+			}
+			c.ok(&auctions, "ReadAuctions")
+			auctionIDs := []string{}
+			for _, item := range auctions {
+				auctionIDs = append(auctionIDs, item.Auction.ID)
+			}
+			require.Contains(t, auctionIDs, "A1")
+
+			var bids []models.Bid
+			c.ok(&bids, "ReadBids")
+			var participantBids []models.Bid
+			c.ok(&participantBids, "GetParticipantBids", "3")
+			var parcelAuctions []struct {
+				Auction models.Auction `json:"auction"`
+			}
+			c.ok(&parcelAuctions, "GetAuctionByParcelID", "2")
+
+			// Hidden auctions are missing from every auction and bid query
+			if !test.visible {
+				require.NotContains(t, auctionIDs, "A2")
+				require.Empty(t, bids)
+				require.Empty(t, participantBids)
+				require.Empty(t, parcelAuctions)
+				c.fails(http.StatusNotFound, "GetAuctionByID", "A2")
+				c.fails(http.StatusNotFound, "GetBidsForAuction", "A2")
+				c.fails(http.StatusNotFound, "GetAuctionBidLadder", "A2")
+				c.fails(http.StatusNotFound, "GetAuctionTransitions", "A2")
+				c.fails(http.StatusNotFound, "GetAuctionClockPrice", "C1")
+				return
+			}
+			require.Contains(t, auctionIDs, "A2")
+			require.Len(t, bids, 1)
+			require.Len(t, participantBids, 1)
+			require.Len(t, parcelAuctions, 1)
+
+			var auction struct {
+				Auction models.Auction `json:"auction"`
+			}
+			c.ok(&auction, "GetAuctionByID", "A2")
+			require.True(t, auction.Auction.InviteOnly)
+			c.ok(nil, "GetBidsForAuction", "A2")
+			c.ok(nil, "GetAuctionBidLadder", "A2")
+			c.ok(nil, "GetAuctionTransitions", "A2")
+			c.ok(nil, "GetAuctionClockPrice", "C1")
+		})
+	}
+}
+
+func intPointer(value int) *int {
+	return &value
+}
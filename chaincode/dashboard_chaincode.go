@@ -44,7 +44,7 @@ func GetClosedAuctionsByMonthYear(auctions []models.Auction) []map[string]interf
 
 	for _, auction := range auctions {
 		monthYear := fmt.Sprintf("%02d/%d", auction.EndDate.Month(), auction.EndDate.Year())
-		if auction.State == models.AuctionState(models.AuctionClosedBids) {
+		if auctionHasWinner(auction.State) {
 			closedAuctionsBids[monthYear]++
 		}
 		closedAuctions[monthYear]++
@@ -76,13 +76,13 @@ func (s *AuctionSmartContract) LogisticOperatorDashboard(stub shim.ChaincodeStub
 	// Closed Auctions
 	var myClosedAuctionAmount = 0
 	for _, auction := range auctions {
-		if auction.State == models.AuctionState(models.AuctionOpen) {
+		if auction.State == models.AuctionOpen {
 			openAuctions += 1
 		}
 
 		if auction.ParticipantId == userId {
 			myAuctions = append(myAuctions, auction)
-			if auction.State == models.AuctionState(models.AuctionClosedBids) {
+			if auctionHasWinner(auction.State) {
 				myClosedAuctionAmount += 1
 			}
 		}
@@ -129,7 +129,7 @@ func (s *AuctionSmartContract) CourierDashboard(stub shim.ChaincodeStubInterface
 	// Quantidade Leilões Abertos
 	openAuctions := 0
 	for _, auction := range auctions {
-		if auction.State == models.AuctionState(models.AuctionOpen) {
+		if auction.State == models.AuctionOpen {
 			openAuctions += 1
 		}
 	}
@@ -0,0 +1,704 @@
+package micolec
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** DELIVERY CONFIRMATION (pickup, delivery and countersign)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ConfirmPickup", "4", "12", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ConfirmDelivery", "4", "12", "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["CountersignDelivery", "11", "12"]}'
+*/
+
+// Proof hashes are hex encoded SHA-256 digests
+func validateProofHash(proofHash string) error {
+	decoded, err := hex.DecodeString(proofHash)
+	if err != nil || len(decoded) != 32 {
+		return fmt.Errorf("The proof hash most be a hex encoded SHA-256 digest")
+	}
+	return nil
+}
+
+// Courier and auction a parcel in Delivery was awarded to. Parcels awarded
+// before the assignment was stored on the parcel are resolved through the
+// winning bid of their auction.
+func (s *AuctionSmartContract) getParcelAssignment(stub shim.ChaincodeStubInterface, parcel models.Parcel) (int, string, error) {
+	if parcel.AssignedCourierId != 0 {
+		return parcel.AssignedCourierId, parcel.AssignedAuctionId, nil
+	}
+
+	auctionsHasParcel, err := GetAuctionsHasParcel(stub)
+	if err != nil {
+		return 0, "", err
+	}
+
+	for _, auctionHasParcel := range auctionsHasParcel {
+		if auctionHasParcel.ParcelID != parcel.ID {
+			continue
+		}
+
+		winningBid, err := getWinningBidForAuction(stub, auctionHasParcel.AuctionID)
+		if err != nil {
+			return 0, "", err
+		}
+		if winningBid.ID != "" {
+			return winningBid.CourierID, winningBid.AuctionID, nil
+		}
+	}
+
+	return 0, "", fmt.Errorf("The parcel %d is not assigned to any courier", parcel.ID)
+}
+
+func (s *AuctionSmartContract) readDeliveryConfirmation(stub shim.ChaincodeStubInterface, parcelID int) (models.DeliveryConfirmation, bool, error) {
+	var confirmation models.DeliveryConfirmation
+
+	confirmationKey, err := s.CreateCompositeKey(stub, EntityDeliveryConfirmation, []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return confirmation, false, err
+	}
+
+	confirmationJSON, err := stub.GetState(confirmationKey)
+	if err != nil {
+		return confirmation, false, err
+	}
+	if confirmationJSON == nil {
+		return confirmation, false, nil
+	}
+
+	err = json.Unmarshal(confirmationJSON, &confirmation)
+	return confirmation, true, err
+}
+
+func (s *AuctionSmartContract) putDeliveryConfirmation(stub shim.ChaincodeStubInterface, confirmation models.DeliveryConfirmation) ([]byte, error) {
+	confirmationKey, err := s.CreateCompositeKey(stub, EntityDeliveryConfirmation, []string{fmt.Sprint(confirmation.ParcelID)})
+	if err != nil {
+		return nil, err
+	}
+
+	dataConfirmation, err := json.Marshal(confirmation)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, confirmationKey, dataConfirmation)
+	return dataConfirmation, err
+}
+
+// Reads a parcel in Delivery and checks it is assigned to the courier
+func (s *AuctionSmartContract) readCourierParcel(stub shim.ChaincodeStubInterface, courierID int, parcelID int) (models.Parcel, string, int, error) {
+	parcel, _, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return parcel, "", http.StatusNotFound, err
+	}
+
+	if parcel.State != models.State(models.ParcelStateDelivery) {
+		return parcel, "", http.StatusBadRequest, fmt.Errorf("The parcel with id %d is not on 'Delivery' state.", parcelID)
+	}
+
+	assignedCourierID, auctionID, err := s.getParcelAssignment(stub, parcel)
+	if err != nil {
+		return parcel, "", http.StatusBadRequest, err
+	}
+	if assignedCourierID != courierID {
+		return parcel, "", http.StatusForbidden, fmt.Errorf("The parcel with id %d is not assigned to the courier %d", parcelID, courierID)
+	}
+
+	return parcel, auctionID, http.StatusOK, nil
+}
+
+func (s *AuctionSmartContract) ConfirmPickup(stub shim.ChaincodeStubInterface, courierID int, parcelID int, proofHash string) pb.Response {
+	fmt.Println("ConfirmPickup Invoke")
+	proofHash = strings.ToLower(strings.TrimSpace(proofHash))
+	err := validateProofHash(proofHash)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	err = checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, auctionID, status, err := s.readCourierParcel(stub, courierID, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(status, err.Error()))
+	}
+
+	_, exists, err := s.readDeliveryConfirmation(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if exists {
+		return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprint("The pickup of the parcel ", parcelID, " was already confirmed")))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	confirmation := models.DeliveryConfirmation{
+		ParcelID:        parcelID,
+		AuctionID:       auctionID,
+		CourierID:       courierID,
+		PickedUpAt:      currentTime,
+		PickupProofHash: proofHash,
+	}
+
+	dataConfirmation, err := s.putDeliveryConfirmation(stub, confirmation)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if parcel.RelayLegs != 0 {
+		leg, legKey, err := s.readParcelLeg(stub, parcel.ID, parcel.CurrentLeg)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		leg.PickedUpAt = currentTime
+		_, err = s.putParcelLeg(stub, legKey, leg)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+	}
+
+	return shim.Success(dataConfirmation)
+}
+
+func (s *AuctionSmartContract) ConfirmDelivery(stub shim.ChaincodeStubInterface, courierID int, parcelID int, proofHash string) pb.Response {
+	fmt.Println("ConfirmDelivery Invoke")
+	proofHash = strings.ToLower(strings.TrimSpace(proofHash))
+	err := validateProofHash(proofHash)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	err = checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, _, status, err := s.readCourierParcel(stub, courierID, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(status, err.Error()))
+	}
+
+	if parcel.CurrentLeg < parcel.RelayLegs {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The parcel with id %d is on leg %d of %d and is handed over at the hub", parcelID, parcel.CurrentLeg, parcel.RelayLegs)))
+	}
+
+	confirmation, exists, err := s.readDeliveryConfirmation(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The pickup of the parcel ", parcelID, " was not confirmed yet")))
+	}
+	if confirmation.DeliveryProofHash != "" {
+		return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprint("The delivery of the parcel ", parcelID, " was already confirmed")))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	confirmation.DeliveredAt = currentTime
+	confirmation.DeliveryProofHash = proofHash
+
+	reward, err := s.getCourierReward(stub, parcel)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// Late deliveries are detected here, the penalty is charged back as a fee at countersign
+	err = s.checkDeliverySla(stub, parcel, reward, &confirmation)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataConfirmation, err := s.putDeliveryConfirmation(stub, confirmation)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataConfirmation)
+}
+
+// Rewards are paid out of the platform wallet usable balance
+func (s *AuctionSmartContract) checkPlatformFunds(stub shim.ChaincodeStubInterface, bitcircleAmount int) error {
+	treasury, err := s.getBookWallet(stub, PlatformWalletId)
+	if err != nil {
+		return err
+	}
+	if treasury.wallet.UsableBalance < bitcircleAmount {
+		return fmt.Errorf("The platform wallet can not pay the reward of %d Bitcircles", bitcircleAmount)
+	}
+	return nil
+}
+
+// The end customer or the logistic operator of the parcel countersigns the
+// delivery: the parcel is Delivered and the courier receives the reward. The
+// auction is settled once all of its parcels are Delivered.
+func (s *AuctionSmartContract) CountersignDelivery(stub shim.ChaincodeStubInterface, participantID int, parcelID int) pb.Response {
+	fmt.Println("CountersignDelivery Invoke")
+	err := checkCaller(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if participantID != parcel.EndCustomerId && participantID != parcel.LogisticOperatorId {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("Only the end customer or the logistic operator can countersign the delivery of the parcel ", parcelID)))
+	}
+
+	if parcel.State != models.State(models.ParcelStateDelivery) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", parcelID, " is not on 'Delivery' state.")))
+	}
+
+	confirmation, exists, err := s.readDeliveryConfirmation(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists || confirmation.DeliveryProofHash == "" {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The delivery of the parcel ", parcelID, " was not confirmed by the courier yet")))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// Relay parcels pay the last leg here, the other legs were paid on handover
+	courierReward, err := s.getCourierReward(stub, parcel)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// The reward comes out of the platform wallet usable balance, nothing was
+	// reserved for it. It is checked before the first write, a failure after it
+	// fails the transaction so that the parcel is not Delivered unpaid.
+	err = s.checkPlatformFunds(stub, courierReward)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusConflict, err.Error()))
+	}
+
+	_, _, err = s.GetWallet(stub, confirmation.CourierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	parcel.State = models.State(models.ParcelStateDelivered)
+	_, err = s.putParcel(stub, parcelKey, &parcel, participantID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	rewardReferenceType, rewardReferenceID := LedgerReferenceParcel, fmt.Sprint(parcel.ID)
+	if parcel.RelayLegs != 0 {
+		rewardReferenceType, rewardReferenceID = LedgerReferenceParcelLeg, ledgerReferenceID(parcel.ID, parcel.CurrentLeg)
+	}
+	description := fmt.Sprint("Parcel ", parcel.ID, " delivery reward.")
+	err = s.postBitcircles(stub, models.LedgerReasonDeliveryReward, availableAccount(PlatformWalletId), availableAccount(confirmation.CourierID), courierReward, rewardReferenceType, rewardReferenceID, description)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Late deliveries are charged the SLA penalty back as a fee
+	description = fmt.Sprint("Parcel ", parcel.ID, " SLA penalty, ", confirmation.HoursLate, " hours late.")
+	err = s.postBitcircles(stub, models.LedgerReasonFee, availableAccount(confirmation.CourierID), availableAccount(PlatformWalletId), confirmation.SlaPenalty, LedgerReferenceSlaBreach, fmt.Sprint(parcel.ID), description)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	reward := courierReward - confirmation.SlaPenalty
+
+	confirmation.CountersignedBy = participantID
+	confirmation.CountersignedAt = currentTime
+	confirmation.RewardPaid = reward
+
+	if parcel.RelayLegs != 0 {
+		leg, legKey, err := s.readParcelLeg(stub, parcel.ID, parcel.CurrentLeg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		leg.State = models.State(models.ParcelStateDelivered)
+		leg.DroppedOffAt = confirmation.DeliveredAt
+		leg.RewardPaid = reward
+		_, err = s.putParcelLeg(stub, legKey, leg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	dataConfirmation, err := s.putDeliveryConfirmation(stub, confirmation)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	if confirmation.AuctionID != "" {
+		err = s.settleAuctionIfFinished(stub, confirmation.AuctionID, parcel.ID, participantID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success(dataConfirmation)
+}
+
+// A parcel is done with an auction once it was delivered or returned, or when
+// it was re-queued and left the auction
+func parcelIsDoneForAuction(parcel models.Parcel, auctionID string) bool {
+	switch parcel.State {
+	case models.State(models.ParcelStateDelivered), models.State(models.ParcelStateReturnedToSender),
+		models.State(models.ParcelStatePending), models.State(models.ParcelStateAuction):
+		return true
+	}
+	return parcel.AuctionId != "" && parcel.AuctionId != auctionID
+}
+
+// Moves the auction to SETTLED when every parcel of it is done. The parcel
+// finished in this transaction is not read back from the ledger.
+func (s *AuctionSmartContract) settleAuctionIfFinished(stub shim.ChaincodeStubInterface, auctionID string, finishedParcelID int, actorID int) error {
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return err
+	}
+
+	auctionJSON, err := s.ReadEntity(stub, auctionKey)
+	if err != nil {
+		return err
+	}
+
+	var auction models.Auction
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return err
+	}
+
+	if auction.State != models.AuctionAwarded {
+		return nil
+	}
+
+	auctionHasParcels, err := getAuctionHasParcels(stub, auctionID)
+	if err != nil {
+		return err
+	}
+
+	for _, auctionHasParcel := range auctionHasParcels {
+		if auctionHasParcel.ParcelID == finishedParcelID {
+			continue
+		}
+
+		if auctionHasParcel.Leg != 0 {
+			leg, _, err := s.readParcelLeg(stub, auctionHasParcel.ParcelID, auctionHasParcel.Leg)
+			if err != nil {
+				return err
+			}
+			if !parcelLegIsDoneForAuction(leg, auctionID) {
+				return nil
+			}
+			continue
+		}
+
+		parcel, _, err := s.readParcel(stub, auctionHasParcel.ParcelID)
+		if err != nil {
+			return err
+		}
+		if !parcelIsDoneForAuction(parcel, auctionID) {
+			return nil
+		}
+	}
+
+	return s.transitionAuction(stub, &auction, models.AuctionSettled, actorID, "All parcels delivered or returned")
+}
+
+func (s *AuctionSmartContract) GetDeliveryConfirmation(stub shim.ChaincodeStubInterface, parcelID int) pb.Response {
+	confirmation, exists, err := s.readDeliveryConfirmation(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The parcel ", parcelID, " has no delivery confirmation")))
+	}
+
+	confirmationJSON, err := json.Marshal(confirmation)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(confirmationJSON)
+}
+
+// ** -----------------------------------------------------
+// ** DELIVERY CONFIRMATION (pickup, delivery and countersign)
+// ** -> END
+// ** -----------------------------------------------------
+
+// ** -----------------------------------------------------
+// ** FAILED DELIVERY ATTEMPTS (re-queue and return to sender)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["RecordFailedDeliveryAttempt", "4", "12", "RECIPIENT_ABSENT", "Nobody at home"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ResolveFailedAttempt", "2", "12", "REQUEUE"]}'
+*/
+
+const (
+	FailedAttemptActionRequeue = "REQUEUE"
+	FailedAttemptActionReturn  = "RETURN"
+)
+
+func validateFailedAttemptReason(reasonCode models.FailedAttemptReason) error {
+	switch reasonCode {
+	case models.FailedAttemptRecipientAbsent, models.FailedAttemptAddressNotFound, models.FailedAttemptRefused,
+		models.FailedAttemptAccessDenied, models.FailedAttemptDamaged, models.FailedAttemptOther:
+		return nil
+	}
+	return fmt.Errorf("Invalid reason code '%s'", reasonCode)
+}
+
+// The assigned courier records a failed delivery attempt. The parcel waits in
+// FailedAttempt until the logistic operator resolves it.
+func (s *AuctionSmartContract) RecordFailedDeliveryAttempt(stub shim.ChaincodeStubInterface, courierID int, parcelID int, reasonCode models.FailedAttemptReason, notes string) pb.Response {
+	fmt.Println("RecordFailedDeliveryAttempt Invoke")
+	err := validateFailedAttemptReason(reasonCode)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	err = checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, auctionID, status, err := s.readCourierParcel(stub, courierID, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(status, err.Error()))
+	}
+
+	if parcel.CurrentLeg < parcel.RelayLegs {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The parcel with id %d is on leg %d of %d and is handed over at the hub", parcelID, parcel.CurrentLeg, parcel.RelayLegs)))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	parcel.DeliveryAttempts = parcel.DeliveryAttempts + 1
+	attempt := models.DeliveryAttempt{
+		ParcelID:   parcel.ID,
+		Attempt:    parcel.DeliveryAttempts,
+		AuctionID:  auctionID,
+		CourierID:  courierID,
+		ReasonCode: reasonCode,
+		Notes:      notes,
+		Date:       currentTime,
+	}
+
+	attemptKey, err := s.CreateCompositeKey(stub, EntityDeliveryAttempt, []string{fmt.Sprint(parcel.ID), fmt.Sprintf("%03d", attempt.Attempt)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataAttempt, err := json.Marshal(attempt)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, attemptKey, dataAttempt)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	parcelKey, err := s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(parcel.ID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	parcel.State = models.State(models.ParcelStateFailedAttempt)
+	_, err = s.putParcel(stub, parcelKey, &parcel, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataAttempt)
+}
+
+// The logistic operator (or the platform) resolves a failed attempt:
+// REQUEUE -> the parcel goes back to Pending for a new auction, only while the
+// maximum number of attempts was not reached. The courier gets no reward.
+// RETURN -> the parcel was returned to the sender, the courier gets
+// ReturnRewardPercent of the parcel reward.
+func (s *AuctionSmartContract) ResolveFailedAttempt(stub shim.ChaincodeStubInterface, participantID int, parcelID int, action string) pb.Response {
+	fmt.Println("ResolveFailedAttempt Invoke")
+	parcel, parcelKey, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	err = checkCaller(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	if participantID != parcel.LogisticOperatorId && participantID != PlatformWalletId {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("The parcel with id ", parcelID, " does not belong to the participant ", participantID)))
+	}
+
+	if parcel.State != models.State(models.ParcelStateFailedAttempt) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", parcelID, " is not on 'FailedAttempt' state.")))
+	}
+
+	config, err := s.getPlatformConfig(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	courierID, auctionID, err := s.getParcelAssignment(stub, parcel)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	courierReward, err := s.getCourierReward(stub, parcel)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	switch action {
+	case FailedAttemptActionRequeue:
+		if parcel.DeliveryAttempts >= config.MaxDeliveryAttempts {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", parcelID, " reached the maximum of ", config.MaxDeliveryAttempts, " delivery attempts and must be returned")))
+		}
+		parcel.State = models.State(models.ParcelStatePending)
+		parcel.AuctionId = ""
+		parcel.AssignedCourierId = 0
+		parcel.AssignedAuctionId = ""
+	case FailedAttemptActionReturn:
+		parcel.State = models.State(models.ParcelStateReturnedToSender)
+	default:
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid action '%s', most be '%s' or '%s'", action, FailedAttemptActionRequeue, FailedAttemptActionReturn)))
+	}
+
+	// Returns pay part of the reward, out of the platform wallet usable balance.
+	// It is checked before the first write, a failure after it fails the transaction.
+	returnReward := 0
+	if action == FailedAttemptActionReturn {
+		returnReward = courierReward * config.ReturnRewardPercent / 100
+	}
+	err = s.checkPlatformFunds(stub, returnReward)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusConflict, err.Error()))
+	}
+
+	dataParcel, err := s.putParcel(stub, parcelKey, &parcel, participantID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	// Relay parcels fail on their last leg, which is re-queued or returned with the parcel
+	if parcel.RelayLegs != 0 {
+		leg, legKey, err := s.readParcelLeg(stub, parcel.ID, parcel.CurrentLeg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		leg.State = parcel.State
+		if action == FailedAttemptActionRequeue {
+			leg.AuctionID = ""
+			leg.CourierID = 0
+			leg.PickedUpAt = time.Time{}
+		}
+		_, err = s.putParcelLeg(stub, legKey, leg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// The pickup of a re-queued parcel is confirmed again by the next courier
+	if action == FailedAttemptActionRequeue {
+		confirmationKey, err := s.CreateCompositeKey(stub, EntityDeliveryConfirmation, []string{fmt.Sprint(parcel.ID)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = stub.DelState(confirmationKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if returnReward > 0 {
+		description := fmt.Sprint("Parcel ", parcel.ID, " return reward.")
+		err = s.postBitcircles(stub, models.LedgerReasonDeliveryReward, availableAccount(PlatformWalletId), availableAccount(courierID), returnReward, LedgerReferenceParcel, fmt.Sprint(parcel.ID), description)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	if auctionID != "" {
+		err = s.settleAuctionIfFinished(stub, auctionID, parcel.ID, participantID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	return shim.Success(dataParcel)
+}
+
+func (s *AuctionSmartContract) GetParcelDeliveryAttempts(stub shim.ChaincodeStubInterface, parcelID int) pb.Response {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityDeliveryAttempt), []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var attempts []models.DeliveryAttempt
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var attempt models.DeliveryAttempt
+		err = json.Unmarshal(response.Value, &attempt)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		attempts = append(attempts, attempt)
+	}
+
+	attemptsJSON, err := json.Marshal(attempts)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(attemptsJSON)
+}
+
+// ** -----------------------------------------------------
+// ** FAILED DELIVERY ATTEMPTS (re-queue and return to sender)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,271 @@
+package micolec
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Hex SHA-256 digest of the proof
+func testProofHash(proof string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(proof)))
+}
+
+// Parcel 1 of end customer 11 in Delivery, awarded to courier 3 on auction A1
+// for a reward of 10 Bitcircles
+func newDeliveryContract(t *testing.T) *testContract {
+	return newAwardedContract(t, "80.00", nil)
+}
+
+func TestValidateProofHash(t *testing.T) {
+	tests := []struct {
+		name      string
+		proofHash string
+		valid     bool
+	}{
+		{"SHA-256 digest", testProofHash("signature"), true},
+		{"not hex", "zz" + testProofHash("signature")[2:], false},
+		{"too short", testProofHash("signature")[:62], false},
+		{"empty", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.valid, validateProofHash(test.proofHash) == nil)
+		})
+	}
+}
+
+func TestConfirmPickup(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    func(c *testContract)
+		callerID  int
+		courierID string
+		proofHash string
+		code      int
+	}{
+		{"by the assigned courier", nil, 3, "3", testProofHash("pickup"), 0},
+		{"upper case proof", nil, 3, "3", "  " + fmt.Sprintf("%X", sha256.Sum256([]byte("pickup"))), 0},
+		{"invalid proof", nil, 3, "3", "signature", http.StatusBadRequest},
+		{"by another courier", nil, 2, "2", testProofHash("pickup"), http.StatusForbidden},
+		{"signed by another courier", nil, 2, "3", testProofHash("pickup"), http.StatusForbidden},
+		{"already picked up", func(c *testContract) {
+			c.as(3).ok(nil, "ConfirmPickup", "3", "1", testProofHash("first"))
+		}, 3, "3", testProofHash("pickup"), http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newDeliveryContract(t)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			c.as(test.callerID)
+			if test.code != 0 {
+				c.fails(test.code, "ConfirmPickup", test.courierID, "1", test.proofHash)
+				return
+			}
+
+			var confirmation models.DeliveryConfirmation
+			c.ok(&confirmation, "ConfirmPickup", test.courierID, "1", test.proofHash)
+			require.Equal(t, "A1", confirmation.AuctionID)
+			require.Equal(t, 3, confirmation.CourierID)
+			require.Equal(t, testProofHash("pickup"), confirmation.PickupProofHash)
+			require.True(t, confirmation.PickedUpAt.Equal(c.ledger.now))
+		})
+	}
+}
+
+func TestConfirmDelivery(t *testing.T) {
+	c := newDeliveryContract(t).as(3)
+
+	// The pickup is confirmed first
+	c.fails(http.StatusBadRequest, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+	c.ok(nil, "ConfirmPickup", "3", "1", testProofHash("pickup"))
+
+	// Only by the courier the parcel is assigned to
+	c.as(2).fails(http.StatusForbidden, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+	c.as(2).fails(http.StatusForbidden, "ConfirmDelivery", "2", "1", testProofHash("delivery"))
+
+	var confirmation models.DeliveryConfirmation
+	c.as(3).ok(&confirmation, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+	require.Equal(t, testProofHash("delivery"), confirmation.DeliveryProofHash)
+	require.True(t, confirmation.DeliveredAt.After(confirmation.PickedUpAt))
+
+	c.fails(http.StatusConflict, "ConfirmDelivery", "3", "1", testProofHash("again"))
+}
+
+func TestCountersignDelivery(t *testing.T) {
+	tests := []struct {
+		name          string
+		before        func(c *testContract)
+		callerID      int
+		participantID int
+		code          int
+	}{
+		{"by the end customer", nil, 11, 11, 0},
+		{"by the logistic operator", nil, testOperatorID, testOperatorID, 0},
+		{"by the courier", nil, 3, 3, http.StatusForbidden},
+		{"by the courier as the end customer", nil, 3, 11, http.StatusForbidden},
+		{"the platform can not pay the reward", func(c *testContract) {
+			treasury := c.wallet(PlatformWalletId)
+			c.ok(nil, "BurnBitcircles", fmt.Sprint(PlatformWalletId), fmt.Sprint(treasury.UsableBalance-5))
+		}, 11, 11, http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newDeliveryContract(t)
+			c.as(3).ok(nil, "ConfirmPickup", "3", "1", testProofHash("pickup"))
+			c.ok(nil, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+			c.as(PlatformWalletId)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			var parcel models.Parcel
+			var auction models.Auction
+			if test.code != 0 {
+				c.as(test.callerID).fails(test.code, "CountersignDelivery", fmt.Sprint(test.participantID), "1")
+
+				// Nothing is written when the delivery is not countersigned
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+				require.EqualValues(t, models.ParcelStateDelivery, parcel.State)
+				require.Equal(t, 100, c.wallet(3).Balance)
+				var confirmation models.DeliveryConfirmation
+				require.True(t, c.entity(EntityDeliveryConfirmation, &confirmation, "1"))
+				require.Zero(t, confirmation.CountersignedBy)
+				return
+			}
+
+			var confirmation models.DeliveryConfirmation
+			c.as(test.callerID).ok(&confirmation, "CountersignDelivery", fmt.Sprint(test.participantID), "1")
+			require.Equal(t, test.participantID, confirmation.CountersignedBy)
+			require.Equal(t, 10, confirmation.RewardPaid)
+
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, models.ParcelStateDelivered, parcel.State)
+			require.Equal(t, 110, c.wallet(3).Balance)
+
+			// The last parcel of the auction settles it
+			require.True(t, c.entity(EntityAuction, &auction, "A1"))
+			require.Equal(t, models.AuctionSettled, auction.State)
+
+			c.fails(http.StatusBadRequest, "CountersignDelivery", fmt.Sprint(test.participantID), "1")
+		})
+	}
+}
+
+func TestRecordFailedDeliveryAttempt(t *testing.T) {
+	tests := []struct {
+		name       string
+		callerID   int
+		courierID  string
+		reasonCode models.FailedAttemptReason
+		code       int
+	}{
+		{"by the assigned courier", 3, "3", models.FailedAttemptRecipientAbsent, 0},
+		{"unknown reason", 3, "3", "LOST", http.StatusBadRequest},
+		{"by another courier", 2, "2", models.FailedAttemptRefused, http.StatusForbidden},
+		{"signed by another courier", 2, "3", models.FailedAttemptRefused, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newDeliveryContract(t).as(test.callerID)
+
+			var parcel models.Parcel
+			if test.code != 0 {
+				c.fails(test.code, "RecordFailedDeliveryAttempt", test.courierID, "1", string(test.reasonCode))
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+				require.EqualValues(t, models.ParcelStateDelivery, parcel.State)
+				return
+			}
+
+			var attempt models.DeliveryAttempt
+			c.ok(&attempt, "RecordFailedDeliveryAttempt", test.courierID, "1", string(test.reasonCode), "Nobody at home")
+			require.Equal(t, 1, attempt.Attempt)
+			require.Equal(t, "A1", attempt.AuctionID)
+			require.Equal(t, test.reasonCode, attempt.ReasonCode)
+
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, models.ParcelStateFailedAttempt, parcel.State)
+			require.Equal(t, 1, parcel.DeliveryAttempts)
+
+			var attempts []models.DeliveryAttempt
+			c.ok(&attempts, "GetParcelDeliveryAttempts", "1")
+			require.Len(t, attempts, 1)
+		})
+	}
+}
+
+func TestResolveFailedAttempt(t *testing.T) {
+	tests := []struct {
+		name          string
+		before        func(c *testContract)
+		participantID int
+		action        string
+		code          int
+		state         models.Status
+		courierReward int
+	}{
+		{"re-queued by the logistic operator", nil, testOperatorID, FailedAttemptActionRequeue, 0, models.ParcelStatePending, 0},
+		{"returned by the platform", nil, PlatformWalletId, FailedAttemptActionReturn, 0, models.ParcelStateReturnedToSender, 5},
+		{"by another participant", nil, 2, FailedAttemptActionReturn, http.StatusForbidden, models.ParcelStateFailedAttempt, 0},
+		{"unknown action", nil, testOperatorID, "RETRY", http.StatusBadRequest, models.ParcelStateFailedAttempt, 0},
+		{"re-queued after the maximum of attempts", func(c *testContract) {
+			c.ok(nil, "SetPlatformConfig", "0", `{"max_delivery_attempts": 1}`)
+		}, testOperatorID, FailedAttemptActionRequeue, http.StatusBadRequest, models.ParcelStateFailedAttempt, 0},
+		{"the platform can not pay the return", func(c *testContract) {
+			treasury := c.wallet(PlatformWalletId)
+			c.ok(nil, "BurnBitcircles", fmt.Sprint(PlatformWalletId), fmt.Sprint(treasury.UsableBalance-1))
+		}, testOperatorID, FailedAttemptActionReturn, http.StatusConflict, models.ParcelStateFailedAttempt, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newDeliveryContract(t)
+			c.as(3).ok(nil, "ConfirmPickup", "3", "1", testProofHash("pickup"))
+			c.ok(nil, "RecordFailedDeliveryAttempt", "3", "1", string(models.FailedAttemptRecipientAbsent))
+			c.as(PlatformWalletId)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			args := []string{"ResolveFailedAttempt", fmt.Sprint(test.participantID), "1", test.action}
+			if test.code != 0 {
+				c.as(test.participantID).fails(test.code, args...)
+			} else {
+				c.as(test.participantID).ok(nil, args...)
+			}
+			c.as(PlatformWalletId)
+
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, test.state, parcel.State)
+			require.Equal(t, 100+test.courierReward, c.wallet(3).Balance)
+			if test.code != 0 {
+				return
+			}
+
+			// A re-queued parcel leaves its auction and is picked up again
+			var confirmation models.DeliveryConfirmation
+			requeued := test.action == FailedAttemptActionRequeue
+			require.Equal(t, !requeued, c.entity(EntityDeliveryConfirmation, &confirmation, "1"))
+			if requeued {
+				require.Empty(t, parcel.AuctionId)
+				require.Zero(t, parcel.AssignedCourierId)
+			}
+
+			var auction models.Auction
+			require.True(t, c.entity(EntityAuction, &auction, "A1"))
+			require.Equal(t, models.AuctionSettled, auction.State)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** DELIVERY PREFERENCES (end customer options behind NotifiedCeOption)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["SetDeliveryPreferences", "11", "{\"parcel_id\":12,\"time_windows\":[{\"from\":\"2022-05-10T09:00:00Z\",\"to\":\"2022-05-10T13:00:00Z\"}],\"safe_place\":\"Back door\"}"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetDeliveryPreferences", "4", "12"]}'
+*/
+
+func validateDeliveryPreference(preference *models.DeliveryPreference) error {
+	preference.SafePlace = strings.TrimSpace(preference.SafePlace)
+	preference.AlternativePickupPoint = strings.TrimSpace(preference.AlternativePickupPoint)
+
+	if preference.ParcelID == 0 {
+		return fmt.Errorf("Parcel ID most be Higher than 0")
+	}
+
+	if len(preference.TimeWindows) == 0 && preference.SafePlace == "" && preference.AlternativePickupPoint == "" && !preference.HoldAtDepot {
+		return fmt.Errorf("At least one time window, a safe place, an alternative pickup point or hold at depot is required")
+	}
+
+	if preference.HoldAtDepot && (preference.SafePlace != "" || preference.AlternativePickupPoint != "") {
+		return fmt.Errorf("A parcel held at the depot can not have a safe place or an alternative pickup point")
+	}
+
+	if preference.SafePlace != "" && preference.AlternativePickupPoint != "" {
+		return fmt.Errorf("Choose either a safe place or an alternative pickup point")
+	}
+
+	for _, window := range preference.TimeWindows {
+		if !window.To.After(window.From) {
+			return fmt.Errorf("The end of a time window most be after its start")
+		}
+	}
+
+	// Windows in order, and not overlapping
+	sort.SliceStable(preference.TimeWindows, func(i, j int) bool {
+		return preference.TimeWindows[i].From.Before(preference.TimeWindows[j].From)
+	})
+	for i := 1; i < len(preference.TimeWindows); i++ {
+		if preference.TimeWindows[i].From.Before(preference.TimeWindows[i-1].To) {
+			return fmt.Errorf("The time windows can not overlap")
+		}
+	}
+
+	return nil
+}
+
+func (s *AuctionSmartContract) readDeliveryPreference(stub shim.ChaincodeStubInterface, parcelID int) (models.DeliveryPreference, bool, error) {
+	var preference models.DeliveryPreference
+
+	preferenceKey, err := s.CreateCompositeKey(stub, EntityDeliveryPreference, []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return preference, false, err
+	}
+
+	preferenceJSON, err := stub.GetState(preferenceKey)
+	if err != nil {
+		return preference, false, err
+	}
+	if preferenceJSON == nil {
+		return preference, false, nil
+	}
+
+	err = json.Unmarshal(preferenceJSON, &preference)
+	return preference, true, err
+}
+
+// Registers (or replaces) the delivery preferences of the end customer while the
+// parcel is Pending or on Auction, and sets NotifiedCeOption on the parcel
+func (s *AuctionSmartContract) SetDeliveryPreferences(stub shim.ChaincodeStubInterface, endCustomerID int, preference models.DeliveryPreference) pb.Response {
+	fmt.Println("SetDeliveryPreferences Invoke")
+	err := checkCaller(stub, endCustomerID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	err = validateDeliveryPreference(&preference)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, preference.ParcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if parcel.EndCustomerId != endCustomerID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("Only the end customer of the parcel ", parcel.ID, " can register its delivery preferences")))
+	}
+
+	if parcel.State != models.State(models.ParcelStatePending) && parcel.State != models.State(models.ParcelStateAuction) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The parcel with id %d is not on 'Pending' or 'Auction' state.", parcel.ID)))
+	}
+
+	preference.EndCustomerID = endCustomerID
+	preference.UpdatedAt, err = getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	preferenceKey, err := s.CreateCompositeKey(stub, EntityDeliveryPreference, []string{fmt.Sprint(parcel.ID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataPreference, err := json.Marshal(preference)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, preferenceKey, dataPreference)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if !parcel.NotifiedCeOption {
+		parcel.NotifiedCeOption = true
+		_, err = s.putParcel(stub, parcelKey, &parcel, endCustomerID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+	}
+
+	return shim.Success(dataPreference)
+}
+
+// The delivery preferences of a parcel are returned to its end customer, and to
+// the courier once the parcel is assigned to them
+func (s *AuctionSmartContract) GetDeliveryPreferences(stub shim.ChaincodeStubInterface, participantID int, parcelID int) pb.Response {
+	err := checkCaller(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, _, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if parcel.EndCustomerId != participantID {
+		if parcel.State == models.State(models.ParcelStatePending) || parcel.State == models.State(models.ParcelStateAuction) {
+			return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprintf("The delivery preferences of the parcel with id %d are only visible to its end customer until it is awarded", parcelID)))
+		}
+		assignedCourierID, _, err := s.getParcelAssignment(stub, parcel)
+		if err != nil || assignedCourierID != participantID {
+			return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprintf("The delivery preferences of the parcel with id %d are only visible to its end customer and assigned courier", parcelID)))
+		}
+	}
+
+	preference, exists, err := s.readDeliveryPreference(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The parcel ", parcelID, " has no delivery preferences")))
+	}
+
+	preferenceJSON, err := json.Marshal(preference)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(preferenceJSON)
+}
+
+// ** -----------------------------------------------------
+// ** DELIVERY PREFERENCES (end customer options behind NotifiedCeOption)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,133 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testTimeWindow(from time.Duration, to time.Duration) models.DeliveryTimeWindow {
+	return models.DeliveryTimeWindow{From: testStartTime.Add(from), To: testStartTime.Add(to)}
+}
+
+func TestValidateDeliveryPreference(t *testing.T) {
+	tests := []struct {
+		name       string
+		preference models.DeliveryPreference
+		valid      bool
+	}{
+		{"safe place", models.DeliveryPreference{ParcelID: 1, SafePlace: " Back door "}, true},
+		{"hold at depot", models.DeliveryPreference{ParcelID: 1, HoldAtDepot: true}, true},
+		{"time windows", models.DeliveryPreference{ParcelID: 1, TimeWindows: []models.DeliveryTimeWindow{
+			testTimeWindow(30*time.Hour, 32*time.Hour), testTimeWindow(26*time.Hour, 28*time.Hour),
+		}}, true},
+		{"without parcel", models.DeliveryPreference{SafePlace: "Back door"}, false},
+		{"without any preference", models.DeliveryPreference{ParcelID: 1, SafePlace: "  "}, false},
+		{"held at depot with a safe place", models.DeliveryPreference{ParcelID: 1, HoldAtDepot: true, SafePlace: "Back door"}, false},
+		{"safe place and pickup point", models.DeliveryPreference{ParcelID: 1, SafePlace: "Back door", AlternativePickupPoint: "Locker 4"}, false},
+		{"window ending at its start", models.DeliveryPreference{ParcelID: 1, TimeWindows: []models.DeliveryTimeWindow{
+			testTimeWindow(26*time.Hour, 26*time.Hour),
+		}}, false},
+		{"overlapping windows", models.DeliveryPreference{ParcelID: 1, TimeWindows: []models.DeliveryTimeWindow{
+			testTimeWindow(27*time.Hour, 29*time.Hour), testTimeWindow(26*time.Hour, 28*time.Hour),
+		}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preference := test.preference
+			require.Equal(t, test.valid, validateDeliveryPreference(&preference) == nil)
+		})
+	}
+}
+
+func TestSetDeliveryPreferences(t *testing.T) {
+	tests := []struct {
+		name          string
+		before        func(c *testContract)
+		callerID      int
+		endCustomerID int
+		code          int
+	}{
+		{"pending parcel", nil, 11, 11, 0},
+		{"parcel on auction", func(c *testContract) { c.auction("A1", []int{1}, nil) }, 11, 11, 0},
+		{"by another end customer", nil, 12, 12, http.StatusForbidden},
+		{"signed by another end customer", nil, 12, 11, http.StatusForbidden},
+		{"awarded parcel", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+			c.award("b1", "A1", "80.00", "0", "3")
+		}, 11, 11, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{3: 100}).parcel(1, nil)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			preference := toJSON(t, models.DeliveryPreference{ParcelID: 1, SafePlace: "Back door"})
+			c.as(test.callerID)
+			var parcel models.Parcel
+			if test.code != 0 {
+				c.fails(test.code, "SetDeliveryPreferences", fmt.Sprint(test.endCustomerID), preference)
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+				require.False(t, parcel.NotifiedCeOption)
+				return
+			}
+
+			var stored models.DeliveryPreference
+			c.ok(&stored, "SetDeliveryPreferences", fmt.Sprint(test.endCustomerID), preference)
+			require.Equal(t, test.endCustomerID, stored.EndCustomerID)
+			require.True(t, stored.UpdatedAt.Equal(c.ledger.now))
+
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.True(t, parcel.NotifiedCeOption)
+		})
+	}
+}
+
+func TestGetDeliveryPreferences(t *testing.T) {
+	tests := []struct {
+		name          string
+		awarded       bool
+		callerID      int
+		participantID int
+		code          int
+	}{
+		{"end customer", false, 11, 11, 0},
+		{"courier before the award", false, 3, 3, http.StatusForbidden},
+		{"end customer after the award", true, 11, 11, 0},
+		{"winning courier", true, 3, 3, 0},
+		{"losing courier", true, 2, 2, http.StatusForbidden},
+		{"losing courier as the winning courier", true, 2, 3, http.StatusForbidden},
+		{"logistic operator", true, testOperatorID, testOperatorID, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+			c.auction("A1", []int{1}, nil)
+			c.as(11).ok(nil, "SetDeliveryPreferences", "11", toJSON(t, models.DeliveryPreference{ParcelID: 1, HoldAtDepot: true}))
+			c.as(PlatformWalletId)
+			if test.awarded {
+				c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "0", "2")
+				c.award("b2", "A1", "80.00", "0", "3")
+			}
+			c.as(test.callerID)
+
+			if test.code != 0 {
+				c.fails(test.code, "GetDeliveryPreferences", fmt.Sprint(test.participantID), "1")
+				return
+			}
+
+			var preference models.DeliveryPreference
+			c.ok(&preference, "GetDeliveryPreferences", fmt.Sprint(test.participantID), "1")
+			require.True(t, preference.HoldAtDepot)
+		})
+	}
+}
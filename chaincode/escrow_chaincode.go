@@ -0,0 +1,216 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** ESCROW (Bitcircles reserved by the winning bid of an auction)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetWalletEscrows", "2"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetWalletEscrows", "2", "CAPTURED"]}'
+*/
+
+// Reservations made before escrows were recorded are not counted on Escrowed
+func releaseEscrowed(wallet *models.Wallet, bitcircleAmount int) {
+	wallet.Escrowed = wallet.Escrowed - bitcircleAmount
+	if wallet.Escrowed < 0 {
+		wallet.Escrowed = 0
+	}
+}
+
+func (s *AuctionSmartContract) readEscrow(stub shim.ChaincodeStubInterface, bid models.Bid) (models.Escrow, string, bool, error) {
+	var escrow models.Escrow
+
+	escrowKey, err := s.CreateCompositeKey(stub, EntityEscrow, []string{fmt.Sprint(bid.CourierID), bid.AuctionID, bid.ID})
+	if err != nil {
+		return escrow, escrowKey, false, err
+	}
+
+	escrowJSON, err := stub.GetState(escrowKey)
+	if err != nil {
+		return escrow, escrowKey, false, err
+	}
+	if escrowJSON == nil {
+		return escrow, escrowKey, false, nil
+	}
+
+	err = json.Unmarshal(escrowJSON, &escrow)
+	return escrow, escrowKey, true, err
+}
+
+func (s *AuctionSmartContract) putEscrow(stub shim.ChaincodeStubInterface, escrowKey string, escrow models.Escrow) error {
+	dataEscrow, err := json.Marshal(escrow)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, escrowKey, dataEscrow)
+	return err
+}
+
+// Records the escrow of the bid, the Bitcircles are moved by the caller
+func (s *AuctionSmartContract) recordOpenEscrow(stub shim.ChaincodeStubInterface, bid models.Bid) error {
+	_, escrowKey, _, err := s.readEscrow(stub, bid)
+	if err != nil {
+		return err
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	return s.putEscrow(stub, escrowKey, models.Escrow{
+		ParticipantID:   bid.CourierID,
+		AuctionID:       bid.AuctionID,
+		BidID:           bid.ID,
+		BitcircleAmount: bid.BitcircleAmount,
+		MoneyAmount:     bid.MoneyAmount,
+		Currency:        bid.Currency,
+		State:           models.EscrowOpen,
+		OpenedAt:        txTime,
+	})
+}
+
+// Closes the open escrow of the bid. Bids that reserved their Bitcircles before
+// escrows were recorded have none.
+func (s *AuctionSmartContract) recordClosedEscrow(stub shim.ChaincodeStubInterface, bid models.Bid, state models.EscrowState) error {
+	escrow, escrowKey, exists, err := s.readEscrow(stub, bid)
+	if err != nil || !exists {
+		return err
+	}
+	if escrow.State != models.EscrowOpen {
+		return fmt.Errorf("The escrow of the bid %s on the auction %s is already %s", bid.ID, bid.AuctionID, escrow.State)
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	escrow.State = state
+	escrow.ClosedAt = &txTime
+	return s.putEscrow(stub, escrowKey, escrow)
+}
+
+// Reserves the Bitcircles of the new winning bid
+func (s *AuctionSmartContract) openEscrow(stub shim.ChaincodeStubInterface, bid models.Bid) error {
+	description := fmt.Sprint("Bid ", bid.ID, " on auction ", bid.AuctionID, " escrow.")
+	err := s.postBitcircles(stub, models.LedgerReasonBidEscrow, availableAccount(bid.CourierID), escrowAccount(bid.CourierID), bid.BitcircleAmount, LedgerReferenceBid, ledgerReferenceID(bid.AuctionID, bid.ID), description)
+	if err != nil {
+		return err
+	}
+
+	return s.recordOpenEscrow(stub, bid)
+}
+
+// Gives the Bitcircles of an outbid or cancelled bid back to the usable balance
+func (s *AuctionSmartContract) releaseEscrow(stub shim.ChaincodeStubInterface, bid models.Bid) error {
+	err := s.recordClosedEscrow(stub, bid, models.EscrowReleased)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprint("Bid ", bid.ID, " on auction ", bid.AuctionID, " escrow release.")
+	return s.postBitcircles(stub, models.LedgerReasonEscrowRelease, escrowAccount(bid.CourierID), availableAccount(bid.CourierID), bid.BitcircleAmount, LedgerReferenceBid, ledgerReferenceID(bid.AuctionID, bid.ID), description)
+}
+
+// Pays the reserved Bitcircles of the winning bid to the receiver
+func (s *AuctionSmartContract) captureEscrow(stub shim.ChaincodeStubInterface, bid models.Bid, receiverParticipantID int, description string) error {
+	err := s.recordClosedEscrow(stub, bid, models.EscrowCaptured)
+	if err != nil {
+		return err
+	}
+
+	return s.postBitcircles(stub, models.LedgerReasonAuctionPayment, escrowAccount(bid.CourierID), availableAccount(receiverParticipantID), bid.BitcircleAmount, LedgerReferenceAuction, bid.AuctionID, description)
+}
+
+func getParticipantEscrows(stub shim.ChaincodeStubInterface, participantID int, state models.EscrowState) ([]models.Escrow, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityEscrow), []string{fmt.Sprint(participantID)})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	escrows := []models.Escrow{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var escrow models.Escrow
+		err = json.Unmarshal(response.Value, &escrow)
+		if err != nil {
+			return nil, err
+		}
+
+		if state == "" || escrow.State == state {
+			escrows = append(escrows, escrow)
+		}
+	}
+
+	return escrows, nil
+}
+
+// Escrows of the wallet in the given state, the open ones by default, with the
+// balances they are derived from
+func (s *AuctionSmartContract) GetWalletEscrows(stub shim.ChaincodeStubInterface, participantID int, state models.EscrowState) pb.Response {
+	wallet, _, err := s.GetWallet(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if state == "" {
+		state = models.EscrowOpen
+	}
+	if state != models.EscrowOpen && state != models.EscrowReleased && state != models.EscrowCaptured {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Invalid escrow state ", state, ", most be OPEN, RELEASED or CAPTURED")))
+	}
+
+	escrows, err := getParticipantEscrows(stub, participantID, state)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var response struct {
+		ParticipantID int             `json:"participant_id"`
+		Balance       int             `json:"balance"`
+		UsableBalance int             `json:"usable_balance"`
+		Escrowed      int             `json:"escrowed"`
+		State         string          `json:"state"`
+		Total         int             `json:"total"`
+		Escrows       []models.Escrow `json:"escrows"`
+	}
+	response.ParticipantID = participantID
+	response.Balance = wallet.Balance
+	response.UsableBalance = wallet.UsableBalance
+	response.Escrowed = wallet.Escrowed
+	response.State = string(state)
+	response.Escrows = escrows
+	for _, escrow := range escrows {
+		response.Total = response.Total + escrow.BitcircleAmount
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** ESCROW (Bitcircles reserved by the winning bid of an auction)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,100 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type walletEscrows struct {
+	Balance       int             `json:"balance"`
+	UsableBalance int             `json:"usable_balance"`
+	Escrowed      int             `json:"escrowed"`
+	State         string          `json:"state"`
+	Total         int             `json:"total"`
+	Escrows       []models.Escrow `json:"escrows"`
+}
+
+func TestWalletEscrows(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "2")
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "A1", "80.00", "4", "3")
+
+	tests := []struct {
+		name          string
+		closed        bool
+		participantID string
+		state         string
+		bidIDs        []string
+		balance       int
+		escrowed      int
+	}{
+		{"outbid courier", false, "2", "", nil, 100, 0},
+		{"released escrow of the outbid courier", false, "2", "RELEASED", []string{"b1"}, 100, 0},
+		{"winning courier", false, "3", "OPEN", []string{"b2"}, 100, 4},
+		{"nothing captured before the close", false, "3", "CAPTURED", nil, 100, 4},
+		{"captured by the close", true, "3", "CAPTURED", []string{"b2"}, 96, 0},
+		{"nothing open after the close", true, "3", "", nil, 96, 0},
+	}
+
+	closed := false
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.closed && !closed {
+				c.after(6*time.Hour).ok(nil, "CloseExpiredAuctions", "A1")
+				closed = true
+			}
+
+			var response walletEscrows
+			args := []string{"GetWalletEscrows", test.participantID}
+			if test.state != "" {
+				args = append(args, test.state)
+			}
+			c.ok(&response, args...)
+			require.Equal(t, test.balance, response.Balance)
+			require.Equal(t, test.escrowed, response.Escrowed)
+			require.Equal(t, test.balance-test.escrowed, response.UsableBalance)
+
+			var bidIDs []string
+			total := 0
+			for _, escrow := range response.Escrows {
+				bidIDs = append(bidIDs, escrow.BidID)
+				total = total + escrow.BitcircleAmount
+				if escrow.State == models.EscrowOpen {
+					require.Nil(t, escrow.ClosedAt)
+				} else {
+					require.NotNil(t, escrow.ClosedAt)
+				}
+			}
+			require.Equal(t, test.bidIDs, bidIDs)
+			require.Equal(t, total, response.Total)
+		})
+	}
+
+	c.fails(http.StatusBadRequest, "GetWalletEscrows", "3", "PENDING")
+	c.fails(http.StatusNotFound, "GetWalletEscrows", "4")
+}
+
+func TestEscrowBeforeRecords(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "2")
+
+	// A reservation made before escrows were recorded has no escrow to close
+	escrowKey, err := c.stub.CreateCompositeKey(string(EntityEscrow), []string{"2", "A1", "b1"})
+	require.NoError(t, err)
+	c.stub.MockTransactionStart("legacy")
+	require.NoError(t, c.stub.DelState(escrowKey))
+	c.stub.MockTransactionEnd("legacy")
+
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "A1", "80.00", "4", "3")
+	var response walletEscrows
+	c.ok(&response, "GetWalletEscrows", "2", "RELEASED")
+	require.Empty(t, response.Escrows)
+	require.Equal(t, 100, response.UsableBalance)
+	require.Zero(t, response.Escrowed)
+}
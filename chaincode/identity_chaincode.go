@@ -0,0 +1,63 @@
+package micolec
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// ** -----------------------------------------------------
+// ** IDENTITY (participant that signed the transaction)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+The participant ids given as arguments are only trusted when they are the id of
+the caller. Enroll each identity with its participant id as an attribute:
+
+fabric-ca-client register --id.name courier4 --id.attrs 'micolec.participant_id=4:ecert'
+fabric-ca-client register --id.name platform --id.attrs 'micolec.participant_id=0:ecert'
+*/
+
+// Attribute of the enrollment certificate with the participant id of the identity
+const ParticipantIDAttribute = "micolec.participant_id"
+
+func getCallerParticipantID(stub shim.ChaincodeStubInterface) (int, error) {
+	value, found, err := cid.GetAttributeValue(stub, ParticipantIDAttribute)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to read the identity of the caller: %v", err)
+	}
+	if !found {
+		return 0, fmt.Errorf("The identity of the caller has no %s attribute", ParticipantIDAttribute)
+	}
+
+	participantID, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s attribute %s on the identity of the caller", ParticipantIDAttribute, value)
+	}
+	return participantID, nil
+}
+
+// The participant given as argument must be the one that signed the transaction
+func checkCaller(stub shim.ChaincodeStubInterface, participantID int) error {
+	callerID, err := getCallerParticipantID(stub)
+	if err != nil {
+		return err
+	}
+	if callerID != participantID {
+		return fmt.Errorf("The caller is not the participant %d", participantID)
+	}
+	return nil
+}
+
+// Only the platform (participant 0) administers, and only when it is the caller
+func isPlatformCaller(stub shim.ChaincodeStubInterface, participantID int) bool {
+	return participantID == PlatformWalletId && checkCaller(stub, participantID) == nil
+}
+
+// ** -----------------------------------------------------
+// ** IDENTITY (participant that signed the transaction)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,286 @@
+package micolec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** LEDGER (double-entry Bitcircle movements)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["ReconcileWallet", "2"]}'
+*/
+
+// References of the ledger entries to their business objects
+const (
+	LedgerReferenceAuction   = "AUCTION"
+	LedgerReferenceBid       = "BID"
+	LedgerReferenceParcel    = "PARCEL"
+	LedgerReferenceParcelLeg = "PARCEL_LEG"
+	LedgerReferenceSlaBreach = "SLA_BREACH"
+	LedgerReferenceWallet    = "WALLET"
+	LedgerReferenceSupply    = "BITCIRCLE_SUPPLY"
+	LedgerReferenceRewardLot = "REWARD_LOT"
+)
+
+type ledgerAccount struct {
+	ParticipantID int
+	Account       models.LedgerAccount
+}
+
+func availableAccount(participantID int) ledgerAccount {
+	return ledgerAccount{ParticipantID: participantID, Account: models.LedgerAvailable}
+}
+
+func escrowAccount(participantID int) ledgerAccount {
+	return ledgerAccount{ParticipantID: participantID, Account: models.LedgerEscrow}
+}
+
+var supplyAccount = ledgerAccount{ParticipantID: SupplyParticipantId, Account: models.LedgerSupply}
+
+func ledgerReferenceID(ids ...interface{}) string {
+	referenceID := ""
+	for i, id := range ids {
+		if i > 0 {
+			referenceID = referenceID + "/"
+		}
+		referenceID = referenceID + fmt.Sprint(id)
+	}
+	return referenceID
+}
+
+// Wallet as updated by the current transaction
+type bookWallet struct {
+	wallet models.Wallet
+	key    string
+}
+
+// The wallets moved by a transaction are read once and kept on its scope, so
+// every movement sees the ones before it
+func (s *AuctionSmartContract) getBookWallet(stub shim.ChaincodeStubInterface, participantID int) (*bookWallet, error) {
+	var book *bookWallet
+	withTxScope(stub, func(scope *txScope) {
+		book = scope.wallets[participantID]
+	})
+	if book != nil {
+		return book, nil
+	}
+
+	wallet, walletKey, err := s.GetWallet(stub, participantID)
+	if err != nil {
+		return nil, err
+	}
+
+	book = &bookWallet{wallet: wallet, key: walletKey}
+	withTxScope(stub, func(scope *txScope) {
+		scope.wallets[participantID] = book
+	})
+	return book, nil
+}
+
+// Adds a wallet created by the current transaction to its book
+func (s *AuctionSmartContract) addBookWallet(stub shim.ChaincodeStubInterface, wallet models.Wallet, walletKey string) {
+	withTxScope(stub, func(scope *txScope) {
+		scope.wallets[wallet.ParticipantId] = &bookWallet{wallet: wallet, key: walletKey}
+	})
+}
+
+func (s *AuctionSmartContract) putBookWallet(stub shim.ChaincodeStubInterface, book *bookWallet) error {
+	dataWallet, err := json.Marshal(book.wallet)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, book.key, dataWallet)
+	return err
+}
+
+// Applies a line to the wallet. Reservations made before escrows were recorded
+// are not counted on Escrowed, see releaseEscrowed.
+func applyLedgerLine(wallet *models.Wallet, line models.LedgerLine) error {
+	amount := line.Credit - line.Debit
+	switch line.Account {
+	case models.LedgerAvailable:
+		if wallet.UsableBalance+amount < 0 {
+			return errors.New("Insufficient balance on your wallet")
+		}
+		wallet.UsableBalance = wallet.UsableBalance + amount
+	case models.LedgerEscrow:
+		if amount < 0 {
+			releaseEscrowed(wallet, -amount)
+		} else {
+			wallet.Escrowed = wallet.Escrowed + amount
+		}
+	}
+	wallet.Balance = wallet.Balance + amount
+	return nil
+}
+
+// Moves Bitcircles from one account to another, as a balanced ledger entry
+// that references its business object
+func (s *AuctionSmartContract) postBitcircles(stub shim.ChaincodeStubInterface, reason models.LedgerReason, from ledgerAccount, to ledgerAccount, bitcircleAmount int, referenceType string, referenceID string, description string) error {
+	if bitcircleAmount == 0 {
+		return nil
+	}
+	if bitcircleAmount < 0 {
+		return fmt.Errorf("Bitcircle amount most be Higher than 0")
+	}
+
+	// Payments and bids of the participant are bound by the freezes and holds of
+	// the wallet, the platform charges are not
+	if from.Account == models.LedgerAvailable && (reason == models.LedgerReasonTransfer || reason == models.LedgerReasonBidEscrow || reason == models.LedgerReasonAuctionPayment) {
+		err := s.VerifyWalletAmount(stub, from.ParticipantID, bitcircleAmount)
+		if err != nil {
+			return err
+		}
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	transaction := models.BitcircleTransaction{
+		SenderParticipantId:   from.ParticipantID,
+		ReceiverParticipantId: to.ParticipantID,
+		BitcircleAmount:       bitcircleAmount,
+		Description:           description,
+		Date:                  txTime,
+		IsReward:              reason == models.LedgerReasonDeliveryReward,
+		Reason:                reason,
+		ReferenceType:         referenceType,
+		ReferenceID:           referenceID,
+		Lines: []models.LedgerLine{
+			{ParticipantID: from.ParticipantID, Account: from.Account, Debit: bitcircleAmount},
+			{ParticipantID: to.ParticipantID, Account: to.Account, Credit: bitcircleAmount},
+		},
+	}
+
+	for _, line := range transaction.Lines {
+		if line.Account == models.LedgerSupply {
+			continue
+		}
+
+		book, err := s.getBookWallet(stub, line.ParticipantID)
+		if err != nil {
+			return err
+		}
+
+		err = applyLedgerLine(&book.wallet, line)
+		if err != nil {
+			return err
+		}
+		book.wallet.LastMovement = txTime
+
+		err = s.putBookWallet(stub, book)
+		if err != nil {
+			return err
+		}
+	}
+
+	transaction, err = s.putBitcircleTransaction(stub, transaction)
+	if err != nil {
+		return err
+	}
+
+	// Rewards are credited as lots, the Bitcircles leaving a wallet consume its
+	// oldest lots first. Expired lots are taken back by the sweep itself.
+	if reason == models.LedgerReasonDeliveryReward && to.Account == models.LedgerAvailable {
+		err = s.creditRewardLot(stub, to.ParticipantID, transaction)
+		if err != nil {
+			return err
+		}
+	}
+	if from.Account != models.LedgerSupply && from.ParticipantID != to.ParticipantID && reason != models.LedgerReasonRewardExpiry {
+		return s.consumeRewardLots(stub, from.ParticipantID, bitcircleAmount)
+	}
+
+	return nil
+}
+
+// Lines of a transaction, those recorded before the ledger move AVAILABLE Bitcircles
+func getLedgerLines(transaction models.BitcircleTransaction) []models.LedgerLine {
+	if len(transaction.Lines) > 0 {
+		return transaction.Lines
+	}
+	return []models.LedgerLine{
+		{ParticipantID: transaction.SenderParticipantId, Account: models.LedgerAvailable, Debit: transaction.BitcircleAmount},
+		{ParticipantID: transaction.ReceiverParticipantId, Account: models.LedgerAvailable, Credit: transaction.BitcircleAmount},
+	}
+}
+
+// Rebuilds the balances of the wallet from its ledger entries and compares them
+// with the stored wallet
+func (s *AuctionSmartContract) ReconcileWallet(stub shim.ChaincodeStubInterface, participantID int) pb.Response {
+	wallet, _, err := s.GetWallet(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	iterator, err := stub.GetStateByRange(walletStatementBound(participantID, nil, false), walletStatementBound(participantID, nil, true))
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	transactions, _, err := s.readWalletTransactions(stub, iterator)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var response struct {
+		ParticipantID int           `json:"participant_id"`
+		Wallet        models.Wallet `json:"wallet"`
+		FromEntries   models.Wallet `json:"from_entries"`
+		Entries       int           `json:"entries"`
+		Consistent    bool          `json:"consistent"`
+	}
+	response.ParticipantID = participantID
+	response.Wallet = wallet
+	response.FromEntries.ParticipantId = participantID
+	response.Entries = len(transactions)
+
+	for _, transaction := range transactions {
+		for _, line := range getLedgerLines(transaction) {
+			if line.ParticipantID != participantID {
+				continue
+			}
+
+			amount := line.Credit - line.Debit
+			response.FromEntries.Balance = response.FromEntries.Balance + amount
+			switch line.Account {
+			case models.LedgerAvailable:
+				response.FromEntries.UsableBalance = response.FromEntries.UsableBalance + amount
+			case models.LedgerEscrow:
+				response.FromEntries.Escrowed = response.FromEntries.Escrowed + amount
+			}
+		}
+		if transaction.Date.After(response.FromEntries.LastMovement) {
+			response.FromEntries.LastMovement = transaction.Date
+		}
+	}
+	response.Consistent = response.FromEntries.Balance == wallet.Balance &&
+		response.FromEntries.UsableBalance == wallet.UsableBalance &&
+		response.FromEntries.Escrowed == wallet.Escrowed
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** LEDGER (double-entry Bitcircle movements)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,168 @@
+package micolec
+
+import (
+	"encoding/json"
+	"micolec/chaincode/models"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyLedgerLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		wallet models.Wallet
+		line   models.LedgerLine
+		after  models.Wallet
+		valid  bool
+	}{
+		{"credit available", models.Wallet{Balance: 10, UsableBalance: 10},
+			models.LedgerLine{Account: models.LedgerAvailable, Credit: 5},
+			models.Wallet{Balance: 15, UsableBalance: 15}, true},
+		{"debit available", models.Wallet{Balance: 10, UsableBalance: 10},
+			models.LedgerLine{Account: models.LedgerAvailable, Debit: 10},
+			models.Wallet{Balance: 0, UsableBalance: 0}, true},
+		{"debit more than available", models.Wallet{Balance: 10, UsableBalance: 4, Escrowed: 6},
+			models.LedgerLine{Account: models.LedgerAvailable, Debit: 5},
+			models.Wallet{Balance: 10, UsableBalance: 4, Escrowed: 6}, false},
+		{"credit escrow", models.Wallet{Balance: 5, UsableBalance: 0},
+			models.LedgerLine{Account: models.LedgerEscrow, Credit: 5},
+			models.Wallet{Balance: 10, UsableBalance: 0, Escrowed: 5}, true},
+		{"debit escrow", models.Wallet{Balance: 10, UsableBalance: 5, Escrowed: 5},
+			models.LedgerLine{Account: models.LedgerEscrow, Debit: 5},
+			models.Wallet{Balance: 5, UsableBalance: 5, Escrowed: 0}, true},
+		{"debit a reservation made before the escrows", models.Wallet{Balance: 10, UsableBalance: 5, Escrowed: 2},
+			models.LedgerLine{Account: models.LedgerEscrow, Debit: 5},
+			models.Wallet{Balance: 5, UsableBalance: 5, Escrowed: 0}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wallet := test.wallet
+			err := applyLedgerLine(&wallet, test.line)
+			require.Equal(t, test.valid, err == nil)
+			require.Equal(t, test.after, wallet)
+		})
+	}
+}
+
+func TestGetLedgerLines(t *testing.T) {
+	// Transactions recorded before the ledger move available Bitcircles
+	legacy := models.BitcircleTransaction{SenderParticipantId: 2, ReceiverParticipantId: 3, BitcircleAmount: 7}
+	require.Equal(t, []models.LedgerLine{
+		{ParticipantID: 2, Account: models.LedgerAvailable, Debit: 7},
+		{ParticipantID: 3, Account: models.LedgerAvailable, Credit: 7},
+	}, getLedgerLines(legacy))
+
+	lines := []models.LedgerLine{
+		{ParticipantID: 2, Account: models.LedgerAvailable, Debit: 7},
+		{ParticipantID: 2, Account: models.LedgerEscrow, Credit: 7},
+	}
+	require.Equal(t, lines, getLedgerLines(models.BitcircleTransaction{SenderParticipantId: 2, ReceiverParticipantId: 2, BitcircleAmount: 7, Lines: lines}))
+}
+
+// Grants, transfers, an outbid and a winning bid, the award of the auction and
+// changes of the supply
+func newLedgerContract(t *testing.T) *testContract {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+	c.ok(nil, "TransferBitcircles", "2", "3", "30", "false", "Shared delivery")
+	c.ok(nil, "TransferBitcircles", "3", "2", "10", "true", "Thanks")
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "2")
+	c.award("b2", "A1", "80.00", "4", "3")
+	c.ok(nil, "MintBitcircles", "0", "500")
+	c.ok(nil, "BurnBitcircles", "0", "200")
+	return c
+}
+
+func TestLedgerEntriesBalance(t *testing.T) {
+	c := newLedgerContract(t)
+
+	transactionPrefix, err := c.stub.CreateCompositeKey(string(EntityBitcircleTransaction), []string{})
+	require.NoError(t, err)
+
+	reasons := map[models.LedgerReason]int{}
+	for key, value := range c.stub.State {
+		if !strings.HasPrefix(key, transactionPrefix) {
+			continue
+		}
+
+		var transaction models.BitcircleTransaction
+		require.NoError(t, json.Unmarshal(value, &transaction))
+		reasons[transaction.Reason] = reasons[transaction.Reason] + 1
+
+		debit, credit := 0, 0
+		for _, line := range transaction.Lines {
+			debit = debit + line.Debit
+			credit = credit + line.Credit
+		}
+		require.Len(t, transaction.Lines, 2, transaction.ID)
+		require.Equal(t, transaction.BitcircleAmount, debit, transaction.ID)
+		require.Equal(t, debit, credit, transaction.ID)
+		require.NotEmpty(t, transaction.ReferenceType, transaction.ID)
+
+		// Only delivery rewards are rewards, whatever the transfer says
+		require.Equal(t, transaction.Reason == models.LedgerReasonDeliveryReward, transaction.IsReward, transaction.ID)
+	}
+
+	require.Equal(t, map[models.LedgerReason]int{
+		models.LedgerReasonMint:           2,
+		models.LedgerReasonBurn:           1,
+		models.LedgerReasonGrant:          2,
+		models.LedgerReasonTransfer:       2,
+		models.LedgerReasonBidEscrow:      2,
+		models.LedgerReasonEscrowRelease:  1,
+		models.LedgerReasonAuctionPayment: 1,
+	}, reasons)
+}
+
+func TestReconcileWallet(t *testing.T) {
+	c := newLedgerContract(t)
+
+	tests := []struct {
+		participantID string
+		balance       int
+		usableBalance int
+	}{
+		{"0", 1304, 1304},
+		{"2", 80, 80},
+		{"3", 116, 116},
+	}
+
+	type reconciliation struct {
+		Wallet      models.Wallet `json:"wallet"`
+		FromEntries models.Wallet `json:"from_entries"`
+		Entries     int           `json:"entries"`
+		Consistent  bool          `json:"consistent"`
+	}
+
+	for _, test := range tests {
+		t.Run(test.participantID, func(t *testing.T) {
+			var response reconciliation
+			c.ok(&response, "ReconcileWallet", test.participantID)
+			require.True(t, response.Consistent)
+			require.Equal(t, test.balance, response.FromEntries.Balance)
+			require.Equal(t, test.usableBalance, response.FromEntries.UsableBalance)
+			require.Zero(t, response.FromEntries.Escrowed)
+		})
+	}
+
+	// A balance changed outside of the ledger no longer reconciles
+	key, err := c.stub.CreateCompositeKey(string(EntityWallet), []string{"3"})
+	require.NoError(t, err)
+	var wallet models.Wallet
+	require.NoError(t, json.Unmarshal(c.stub.State[key], &wallet))
+	wallet.Balance = wallet.Balance + 50
+	wallet.UsableBalance = wallet.UsableBalance + 50
+	c.stub.State[key] = []byte(toJSON(t, wallet))
+
+	var response reconciliation
+	c.ok(&response, "ReconcileWallet", "3")
+	require.False(t, response.Consistent)
+	require.Equal(t, 166, response.Wallet.Balance)
+	require.Equal(t, 116, response.FromEntries.Balance)
+
+	c.fails(http.StatusNotFound, "ReconcileWallet", "4")
+}
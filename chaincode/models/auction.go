@@ -1,20 +1,83 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type AuctionState string
 
+// SCHEDULED -> created with a start date in the future
+// OPEN -> accepting bids
+// CLOSED -> bidding ended with bids, waiting for the winner to be awarded
+// CLOSED NO BIDS -> bidding ended without bids, the parcels went back to Pending
+// AWARDED -> the winner was charged and the parcels are in Delivery
+// SETTLED -> every parcel of the auction reached the end of its delivery
+// CANCELLED -> cancelled before being awarded
+const (
+	AuctionScheduled    AuctionState = "SCHEDULED"
+	AuctionOpen         AuctionState = "OPEN"
+	AuctionClosedBids   AuctionState = "CLOSED"
+	AuctionClosedNoBids AuctionState = "CLOSED NO BIDS"
+	AuctionAwarded      AuctionState = "AWARDED"
+	AuctionSettled      AuctionState = "SETTLED"
+	AuctionCancelled    AuctionState = "CANCELLED"
+)
+
+type AuctionType string
+
+// STANDARD -> couriers bid down until EndDate
+// CLOCK -> reverse dutch, the price rises on a schedule and the first courier to accept wins
 const (
-	AuctionClosedNoBids Status = "CLOSED NO BIDS"
-	AuctionClosedBids   Status = "CLOSED"
-	AuctionOpen         Status = "OPEN"
+	AuctionTypeStandard AuctionType = "STANDARD"
+	AuctionTypeClock    AuctionType = "CLOCK"
 )
 
 type Auction struct {
 	ID                        string       `json:"id"`
 	StartDate                 time.Time    `json:"start_date"`
 	EndDate                   time.Time    `json:"end_date"`
-	MaximumAcceptedLicitation float32      `json:"maximum_accepted_licitation,omitempty"`
+	MaximumAcceptedLicitation int64        `json:"maximum_accepted_licitation,omitempty"`
+	Currency                  string       `json:"currency"`
 	State                     AuctionState `json:"state"`
 	ParticipantId             int          `json:"participant_id"`
+	Type                      AuctionType  `json:"type,omitempty"`
+	ClockStartPrice           int64        `json:"clock_start_price,omitempty"`
+	ClockStepAmount           int64        `json:"clock_step_amount,omitempty"`
+	ClockStepMinutes          int          `json:"clock_step_minutes,omitempty"`
+	InviteOnly                bool         `json:"invite_only,omitempty"`
+	TransitionCount           int          `json:"transition_count,omitempty"`
+}
+
+// Amounts are minor units of the currency, auctions sent or stored without a
+// currency have them as euro decimals
+func (a *Auction) UnmarshalJSON(data []byte) error {
+	type auctionAlias Auction
+	aux := struct {
+		*auctionAlias
+		MaximumAcceptedLicitation json.RawMessage `json:"maximum_accepted_licitation"`
+		ClockStartPrice           json.RawMessage `json:"clock_start_price"`
+		ClockStepAmount           json.RawMessage `json:"clock_step_amount"`
+	}{auctionAlias: (*auctionAlias)(a)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalMoneyAmounts(&a.Currency,
+		[]json.RawMessage{aux.MaximumAcceptedLicitation, aux.ClockStartPrice, aux.ClockStepAmount},
+		[]*int64{&a.MaximumAcceptedLicitation, &a.ClockStartPrice, &a.ClockStepAmount})
+}
+
+// Every change of an auction state, in order
+type AuctionTransition struct {
+	AuctionID string       `json:"auction_id"`
+	Sequence  int          `json:"sequence"`
+	From      AuctionState `json:"from"`
+	To        AuctionState `json:"to"`
+	ActorID   int          `json:"actor_id"`
+	Date      time.Time    `json:"date"`
+	Reason    string       `json:"reason"`
+	TxID      string       `json:"tx_id"`
 }
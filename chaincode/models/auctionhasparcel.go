@@ -1,6 +1,8 @@
 package models
 
+// Leg is set when a single leg of a relay parcel is auctioned
 type AuctionHasParcel struct {
 	AuctionID string `json:"auction_id"`
 	ParcelID  int    `json:"parcel_id"`
+	Leg       int    `json:"leg,omitempty"`
 }
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Status string
 
@@ -13,9 +16,28 @@ type Bid struct {
 	ID              string    `json:"id"`
 	Date            time.Time `json:"date"`
 	BitcircleAmount int       `json:"bitcircle_amount,omitempty"`
-	MoneyAmount     float32   `json:"money_amount"`
+	MoneyAmount     int64     `json:"money_amount"`
+	Currency        string    `json:"currency"`
 	Status          Status    `json:"status"`
 	Winner          bool      `json:"winner"`
 	AuctionID       string    `json:"auction_id"`
 	CourierID       int       `json:"courier_id"`
+	IsProxyBid      bool      `json:"is_proxy_bid"`
+}
+
+// The money amount is in minor units of the currency, bids sent or stored
+// without a currency have it as a euro decimal
+func (b *Bid) UnmarshalJSON(data []byte) error {
+	type bidAlias Bid
+	aux := struct {
+		*bidAlias
+		MoneyAmount json.RawMessage `json:"money_amount"`
+	}{bidAlias: (*bidAlias)(b)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalMoneyAmounts(&b.Currency, []json.RawMessage{aux.MoneyAmount}, []*int64{&b.MoneyAmount})
 }
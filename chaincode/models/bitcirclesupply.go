@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// Bitcircles created and destroyed by the platform on the treasury wallet
+type BitcircleSupply struct {
+	Minted    int       `json:"minted"`
+	Burned    int       `json:"burned"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,13 +1,43 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
 
+// Built from the Fabric transaction id. Transactions recorded before were
+// numbered, those numeric ids are read as strings.
+type BitcircleTransactionID string
+
+func (id *BitcircleTransactionID) UnmarshalJSON(data []byte) error {
+	var number int
+	if err := json.Unmarshal(data, &number); err == nil {
+		*id = BitcircleTransactionID(strconv.Itoa(number))
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	*id = BitcircleTransactionID(text)
+	return nil
+}
+
+// A balanced ledger entry, its lines debit and credit the same amount. Sender and
+// receiver are the debited and credited participants. Transactions recorded
+// before the ledger have no lines and move AVAILABLE Bitcircles.
 type BitcircleTransaction struct {
-	ID                    int       `json:"id"`
-	SenderParticipantId   int       `json:"sender_participant_id"`
-	ReceiverParticipantId int       `json:"receiver_participant_id"`
-	BitcircleAmount       int       `json:"bitcircle_amount"`
-	Description           string    `json:"description"`
-	Date                  time.Time `json:"date"`
-	IsReward              bool      `json:"is_reward"`
+	ID                    BitcircleTransactionID `json:"id"`
+	SenderParticipantId   int                    `json:"sender_participant_id"`
+	ReceiverParticipantId int                    `json:"receiver_participant_id"`
+	BitcircleAmount       int                    `json:"bitcircle_amount"`
+	Description           string                 `json:"description"`
+	Date                  time.Time              `json:"date"`
+	IsReward              bool                   `json:"is_reward"`
+	Reason                LedgerReason           `json:"reason,omitempty"`
+	ReferenceType         string                 `json:"reference_type,omitempty"`
+	ReferenceID           string                 `json:"reference_id,omitempty"`
+	Lines                 []LedgerLine           `json:"lines,omitempty"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Courier allowed to see and bid on an invite-only auction
+type AuctionInvite struct {
+	AuctionID string    `json:"auction_id"`
+	CourierID int       `json:"courier_id"`
+	InvitedBy int       `json:"invited_by"`
+	Date      time.Time `json:"date"`
+}
+
+// Courier blocked from every auction of a logistic operator
+type DeniedCourier struct {
+	LogisticOperatorID int       `json:"logistic_operator_id"`
+	CourierID          int       `json:"courier_id"`
+	Reason             string    `json:"reason"`
+	Date               time.Time `json:"date"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Proof of the delivery of a parcel by the assigned courier. The proof hashes are
+// hex SHA-256 digests of the signature or photo taken at pickup and delivery.
+type DeliveryConfirmation struct {
+	ParcelID          int       `json:"parcel_id"`
+	AuctionID         string    `json:"auction_id"`
+	CourierID         int       `json:"courier_id"`
+	PickedUpAt        time.Time `json:"picked_up_at"`
+	PickupProofHash   string    `json:"pickup_proof_hash"`
+	DeliveredAt       time.Time `json:"delivered_at"`
+	DeliveryProofHash string    `json:"delivery_proof_hash,omitempty"`
+	CountersignedBy   int       `json:"countersigned_by,omitempty"`
+	CountersignedAt   time.Time `json:"countersigned_at"`
+	HoursLate         int       `json:"hours_late,omitempty"`
+	SlaPenalty        int       `json:"sla_penalty,omitempty"`
+	RewardPaid        int       `json:"reward_paid"`
+}
+
+// A delivery confirmed after the RequiredDeliveryDate of the parcel
+type SlaBreach struct {
+	ParcelID             int       `json:"parcel_id"`
+	AuctionID            string    `json:"auction_id"`
+	CourierID            int       `json:"courier_id"`
+	LogisticOperatorID   int       `json:"logistic_operator_id"`
+	RequiredDeliveryDate time.Time `json:"required_delivery_date"`
+	DeliveredAt          time.Time `json:"delivered_at"`
+	HoursLate            int       `json:"hours_late"`
+	Penalty              int       `json:"penalty"`
+	TxID                 string    `json:"tx_id"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Delivery preferences registered by the end customer of a parcel. Only the end
+// customer and the courier the parcel is assigned to can read them.
+type DeliveryPreference struct {
+	ParcelID               int                  `json:"parcel_id"`
+	EndCustomerID          int                  `json:"end_customer_id"`
+	TimeWindows            []DeliveryTimeWindow `json:"time_windows"`
+	SafePlace              string               `json:"safe_place,omitempty"`
+	AlternativePickupPoint string               `json:"alternative_pickup_point,omitempty"`
+	HoldAtDepot            bool                 `json:"hold_at_depot"`
+	UpdatedAt              time.Time            `json:"updated_at"`
+}
+
+// Period the end customer is available to receive the parcel
+type DeliveryTimeWindow struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
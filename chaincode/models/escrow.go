@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type EscrowState string
+
+// OPEN -> RELEASED (back to the usable balance) or CAPTURED (paid to the auction)
+const (
+	EscrowOpen     EscrowState = "OPEN"
+	EscrowReleased EscrowState = "RELEASED"
+	EscrowCaptured EscrowState = "CAPTURED"
+)
+
+// Bitcircles of a wallet held by the winning bid of an auction
+type Escrow struct {
+	ParticipantID   int         `json:"participant_id"`
+	AuctionID       string      `json:"auction_id"`
+	BidID           string      `json:"bid_id"`
+	BitcircleAmount int         `json:"bitcircle_amount"`
+	MoneyAmount     int64       `json:"money_amount"`
+	Currency        string      `json:"currency"`
+	State           EscrowState `json:"state"`
+	OpenedAt        time.Time   `json:"opened_at"`
+	ClosedAt        *time.Time  `json:"closed_at,omitempty"`
+}
+
+// The money amount is in minor units of the currency, escrows stored without a
+// currency have it as a euro decimal
+func (e *Escrow) UnmarshalJSON(data []byte) error {
+	type escrowAlias Escrow
+	aux := struct {
+		*escrowAlias
+		MoneyAmount json.RawMessage `json:"money_amount"`
+	}{escrowAlias: (*escrowAlias)(e)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalMoneyAmounts(&e.Currency, []json.RawMessage{aux.MoneyAmount}, []*int64{&e.MoneyAmount})
+}
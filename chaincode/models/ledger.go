@@ -0,0 +1,34 @@
+package models
+
+// Every wallet has its AVAILABLE Bitcircles (the usable balance) and the ones on
+// ESCROW for bids. SUPPLY is where minted Bitcircles come from and burned ones go.
+type LedgerAccount string
+
+const (
+	LedgerAvailable LedgerAccount = "AVAILABLE"
+	LedgerEscrow    LedgerAccount = "ESCROW"
+	LedgerSupply    LedgerAccount = "SUPPLY"
+)
+
+type LedgerReason string
+
+const (
+	LedgerReasonBidEscrow      LedgerReason = "BID_ESCROW"
+	LedgerReasonEscrowRelease  LedgerReason = "ESCROW_RELEASE"
+	LedgerReasonAuctionPayment LedgerReason = "AUCTION_PAYMENT"
+	LedgerReasonDeliveryReward LedgerReason = "DELIVERY_REWARD"
+	LedgerReasonMint           LedgerReason = "MINT"
+	LedgerReasonBurn           LedgerReason = "BURN"
+	LedgerReasonFee            LedgerReason = "FEE"
+	LedgerReasonGrant          LedgerReason = "GRANT"
+	LedgerReasonTransfer       LedgerReason = "TRANSFER"
+	LedgerReasonRewardExpiry   LedgerReason = "REWARD_EXPIRY"
+)
+
+// The Bitcircles leave the debited account and arrive at the credited one
+type LedgerLine struct {
+	ParticipantID int           `json:"participant_id"`
+	Account       LedgerAccount `json:"account"`
+	Debit         int           `json:"debit,omitempty"`
+	Credit        int           `json:"credit,omitempty"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Money amounts are integer minor units (cents for EUR) of an ISO 4217 currency.
+// Records stored before had float32 amounts in euros and no currency.
+const DefaultCurrency = "EUR"
+
+// Digits of the minor unit of the accepted currencies
+var currencyMinorDigits = map[string]int{
+	"EUR": 2,
+	"USD": 2,
+	"GBP": 2,
+	"CHF": 2,
+	"BRL": 2,
+	"JPY": 0,
+}
+
+func IsCurrency(currency string) bool {
+	_, ok := currencyMinorDigits[currency]
+	return ok
+}
+
+// Reads a decimal string ("94.5", "94,50", "-3") exactly in minor units of the
+// currency. Amounts with more decimals than the currency has are rejected.
+func ParseMoney(amount string, currency string) (int64, error) {
+	digits, ok := currencyMinorDigits[currency]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported currency %s", currency)
+	}
+
+	text := strings.Replace(strings.TrimSpace(amount), ",", ".", 1)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	units, decimals := text, ""
+	if dot := strings.Index(text, "."); dot >= 0 {
+		units, decimals = text[:dot], text[dot+1:]
+	}
+	if units == "" || strings.Trim(units, "0123456789") != "" || strings.Trim(decimals, "0123456789") != "" {
+		return 0, fmt.Errorf("Invalid money amount %s", amount)
+	}
+	if len(decimals) > digits {
+		return 0, fmt.Errorf("Invalid money amount %s, %s has %d decimals", amount, currency, digits)
+	}
+	if len(units)+digits > 15 {
+		return 0, fmt.Errorf("Invalid money amount %s, too large", amount)
+	}
+
+	var minorUnits int64
+	for _, digit := range units + decimals + strings.Repeat("0", digits-len(decimals)) {
+		minorUnits = minorUnits*10 + int64(digit-'0')
+	}
+	if negative {
+		minorUnits = -minorUnits
+	}
+
+	return minorUnits, nil
+}
+
+// Writes minor units as a decimal amount with its currency: "94.50 EUR"
+func FormatMoney(amount int64, currency string) string {
+	digits := currencyMinorDigits[currency]
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	text := fmt.Sprintf("%0*d", digits+1, amount)
+	if digits == 0 {
+		return fmt.Sprint(sign, text, " ", currency)
+	}
+	return fmt.Sprint(sign, text[:len(text)-digits], ".", text[len(text)-digits:], " ", currency)
+}
+
+// A legacy float amount in major units, rounded to the minor unit
+func parseLegacyMoney(rawAmount json.RawMessage, currency string) (int64, error) {
+	amount, ok := new(big.Rat).SetString(string(rawAmount))
+	if !ok {
+		return 0, fmt.Errorf("Invalid money amount %s", rawAmount)
+	}
+
+	scale := new(big.Rat).SetFloat64(math.Pow10(currencyMinorDigits[currency]))
+	minorUnits, _ := amount.Mul(amount, scale).Float64()
+	return int64(math.Round(minorUnits)), nil
+}
+
+// Reads the amounts of a record in minor units. Records without a currency were
+// stored before it, their amounts are read as euro decimals.
+func unmarshalMoneyAmounts(currency *string, rawAmounts []json.RawMessage, amounts []*int64) error {
+	legacy := *currency == ""
+	if legacy {
+		*currency = DefaultCurrency
+	}
+
+	for i, rawAmount := range rawAmounts {
+		*amounts[i] = 0
+		if len(rawAmount) == 0 || string(rawAmount) == "null" {
+			continue
+		}
+
+		var err error
+		if legacy {
+			*amounts[i], err = parseLegacyMoney(rawAmount, *currency)
+		} else {
+			err = json.Unmarshal(rawAmount, amounts[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A record stored before the amounts had a currency
+func IsLegacyMoney(record []byte) (bool, error) {
+	var rawRecord struct {
+		Currency string `json:"currency"`
+	}
+	err := json.Unmarshal(record, &rawRecord)
+	return rawRecord.Currency == "", err
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount     string
+		currency   string
+		minorUnits int64
+		valid      bool
+	}{
+		{"94.50", "EUR", 9450, true},
+		{"94.5", "EUR", 9450, true},
+		{"94,50", "EUR", 9450, true},
+		{" 94 ", "EUR", 9400, true},
+		{"0.01", "EUR", 1, true},
+		{"-3", "EUR", -300, true},
+		{"0.1", "EUR", 10, true},
+		{"1234", "JPY", 1234, true},
+		{"94.505", "EUR", 0, false},
+		{"12.5", "JPY", 0, false},
+		{"", "EUR", 0, false},
+		{".50", "EUR", 0, false},
+		{"9a.50", "EUR", 0, false},
+		{"94.5.0", "EUR", 0, false},
+		{"1e3", "EUR", 0, false},
+		{"10000000000000", "EUR", 0, false},
+		{"94.50", "XXX", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.amount+" "+test.currency, func(t *testing.T) {
+			minorUnits, err := ParseMoney(test.amount, test.currency)
+			require.Equal(t, test.valid, err == nil, err)
+			require.Equal(t, test.minorUnits, minorUnits)
+		})
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		minorUnits int64
+		currency   string
+		formatted  string
+	}{
+		{9450, "EUR", "94.50 EUR"},
+		{5, "EUR", "0.05 EUR"},
+		{0, "EUR", "0.00 EUR"},
+		{-300, "USD", "-3.00 USD"},
+		{1234, "JPY", "1234 JPY"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.formatted, func(t *testing.T) {
+			require.Equal(t, test.formatted, FormatMoney(test.minorUnits, test.currency))
+			minorUnits, err := ParseMoney(test.formatted[:len(test.formatted)-4], test.currency)
+			require.NoError(t, err)
+			require.Equal(t, test.minorUnits, minorUnits)
+		})
+	}
+}
+
+func TestUnmarshalMoneyAmounts(t *testing.T) {
+	tests := []struct {
+		name        string
+		record      string
+		moneyAmount int64
+		currency    string
+		legacy      bool
+	}{
+		{"minor units", `{"money_amount": 9450, "currency": "EUR"}`, 9450, "EUR", false},
+		{"minor units of another currency", `{"money_amount": 1234, "currency": "JPY"}`, 1234, "JPY", false},
+		{"legacy euros", `{"money_amount": 94.5}`, 9450, "EUR", true},
+		{"legacy float32 euros", `{"money_amount": 94.55000305175781}`, 9455, "EUR", true},
+		{"legacy without amount", `{}`, 0, "EUR", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var bid Bid
+			require.NoError(t, bid.UnmarshalJSON([]byte(test.record)))
+			require.Equal(t, test.moneyAmount, bid.MoneyAmount)
+			require.Equal(t, test.currency, bid.Currency)
+
+			legacy, err := IsLegacyMoney([]byte(test.record))
+			require.NoError(t, err)
+			require.Equal(t, test.legacy, legacy)
+		})
+	}
+
+	// Minor units are integers once the record has a currency
+	var bid Bid
+	require.Error(t, bid.UnmarshalJSON([]byte(`{"money_amount": 94.5, "currency": "EUR"}`)))
+}
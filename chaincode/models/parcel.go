@@ -1,16 +1,35 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type State string
 
-//state (Pending/Auction/Delivery/Delivered)
+//state (Pending/Auction/Delivery/Delivered/FailedAttempt/ReturnedToSender/Cancelled)
+
+const (
+	ParcelStatePending          Status = "Pending"
+	ParcelStateAuction          Status = "Auction"
+	ParcelStateDelivery         Status = "Delivery"
+	ParcelStateDelivered        Status = "Delivered"
+	ParcelStateFailedAttempt    Status = "FailedAttempt"
+	ParcelStateReturnedToSender Status = "ReturnedToSender"
+	ParcelStateCancelled        Status = "Cancelled"
+)
+
+type FailedAttemptReason string
 
 const (
-	ParcelStatePending   Status = "Pending"
-	ParcelStateAuction   Status = "Auction"
-	ParcelStateDelivery  Status = "Delivery"
-	ParcelStateDelivered Status = "Delivered"
+	FailedAttemptRecipientAbsent FailedAttemptReason = "RECIPIENT_ABSENT"
+	FailedAttemptAddressNotFound FailedAttemptReason = "ADDRESS_NOT_FOUND"
+	FailedAttemptRefused         FailedAttemptReason = "REFUSED"
+	FailedAttemptAccessDenied    FailedAttemptReason = "ACCESS_DENIED"
+	FailedAttemptDamaged         FailedAttemptReason = "DAMAGED"
+	FailedAttemptOther           FailedAttemptReason = "OTHER"
 )
 
 type Parcel struct {
@@ -22,8 +41,184 @@ type Parcel struct {
 	DeliveryPostalArea   string    `json:"delivery_postal_area"`
 	NotifiedCeOption     bool      `json:"notified_ce_option"`
 	BitcircleReward      int       `json:"bitcircle_reward"`
-	Weight               string    `json:"weight"`
+	Weight               float64   `json:"weight"`
+	WeightUnit           string    `json:"weight_unit,omitempty"`
+	Length               float64   `json:"length,omitempty"`
+	Width                float64   `json:"width,omitempty"`
+	Height               float64   `json:"height,omitempty"`
+	DimensionUnit        string    `json:"dimension_unit,omitempty"`
+	VolumetricWeight     float64   `json:"volumetric_weight,omitempty"`
+	ChargeableWeight     float64   `json:"chargeable_weight,omitempty"`
 	Volumes              int       `json:"volume"`
 	LogisticOperatorId   int       `json:"logistic_operator_id"`
 	EndCustomerId        int       `json:"end_customer_id"`
+	AssignedCourierId    int       `json:"assigned_courier_id,omitempty"`
+	AssignedAuctionId    string    `json:"assigned_auction_id,omitempty"`
+	AuctionId            string    `json:"auction_id,omitempty"`
+	UpdatedBy            int       `json:"updated_by"`
+	DeliveryAttempts     int       `json:"delivery_attempts,omitempty"`
+	RelayLegs            int       `json:"relay_legs,omitempty"`
+	CurrentLeg           int       `json:"current_leg,omitempty"`
+}
+
+const (
+	WeightUnitKg    = "kg"
+	WeightUnitGram  = "g"
+	WeightUnitPound = "lb"
+
+	DimensionUnitCm    = "cm"
+	DimensionUnitMm    = "mm"
+	DimensionUnitMeter = "m"
+	DimensionUnitInch  = "in"
+)
+
+// cm3 per kg used to turn the parcel volume into a volumetric weight
+const VolumetricDivisor = 5000
+
+var weightUnitsInKg = map[string]float64{
+	WeightUnitKg:    1,
+	WeightUnitGram:  0.001,
+	WeightUnitPound: 0.45359237,
+}
+
+var dimensionUnitsInCm = map[string]float64{
+	DimensionUnitCm:    1,
+	DimensionUnitMm:    0.1,
+	DimensionUnitMeter: 100,
+	DimensionUnitInch:  2.54,
+}
+
+func IsWeightUnit(unit string) bool {
+	_, ok := weightUnitsInKg[unit]
+	return ok
+}
+
+func IsDimensionUnit(unit string) bool {
+	_, ok := dimensionUnitsInCm[unit]
+	return ok
+}
+
+// Fills the default units and computes the volumetric and chargeable weights, in kg.
+// The chargeable weight is the highest of the real and the volumetric weight.
+func (p *Parcel) ComputeWeights() {
+	if p.WeightUnit == "" {
+		p.WeightUnit = WeightUnitKg
+	}
+	if p.DimensionUnit == "" {
+		p.DimensionUnit = DimensionUnitCm
+	}
+
+	weightInKg := p.Weight * weightUnitsInKg[p.WeightUnit]
+	toCm := dimensionUnitsInCm[p.DimensionUnit]
+	p.VolumetricWeight = RoundWeight(p.Length * toCm * p.Width * toCm * p.Height * toCm / VolumetricDivisor)
+
+	p.ChargeableWeight = RoundWeight(weightInKg)
+	if p.VolumetricWeight > p.ChargeableWeight {
+		p.ChargeableWeight = p.VolumetricWeight
+	}
+}
+
+// Weights are kept to the gram
+func RoundWeight(weight float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(weight, 'f', 3, 64), 64)
+	return rounded
+}
+
+// Parcels stored before the weight was numeric have it as a string ("10", "2,5 kg",
+// "500 g"). Those are read as numbers in the unit they were written in, weights
+// that cannot be read are left at 0.
+func (p *Parcel) UnmarshalJSON(data []byte) error {
+	type parcelAlias Parcel
+	aux := struct {
+		*parcelAlias
+		Weight json.RawMessage `json:"weight"`
+	}{parcelAlias: (*parcelAlias)(p)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	p.Weight = 0
+	if IsLegacyWeight(aux.Weight) {
+		var weight string
+		err = json.Unmarshal(aux.Weight, &weight)
+		if err != nil {
+			return err
+		}
+		var unit string
+		p.Weight, unit = parseLegacyWeight(weight)
+		if unit != "" {
+			p.WeightUnit = unit
+		}
+	} else if len(aux.Weight) > 0 && string(aux.Weight) != "null" {
+		err = json.Unmarshal(aux.Weight, &p.Weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A weight stored as a JSON string
+func IsLegacyWeight(rawWeight json.RawMessage) bool {
+	return len(rawWeight) > 0 && rawWeight[0] == '"'
+}
+
+// The weight and its unit, empty when the string has none. Kilograms are
+// matched before grams, which they end with.
+func parseLegacyWeight(weight string) (float64, string) {
+	weight = strings.ToLower(strings.TrimSpace(weight))
+	unit := ""
+	for _, weightUnit := range []string{WeightUnitKg, WeightUnitPound, WeightUnitGram} {
+		if strings.HasSuffix(weight, weightUnit) {
+			unit = weightUnit
+			weight = strings.TrimSpace(strings.TrimSuffix(weight, weightUnit))
+			break
+		}
+	}
+	weight = strings.Replace(weight, ",", ".", 1)
+
+	parsed, err := strconv.ParseFloat(weight, 64)
+	if err != nil {
+		return 0, ""
+	}
+	return parsed, unit
+}
+
+// A delivery attempt that failed, recorded by the assigned courier
+type DeliveryAttempt struct {
+	ParcelID   int                 `json:"parcel_id"`
+	Attempt    int                 `json:"attempt"`
+	AuctionID  string              `json:"auction_id"`
+	CourierID  int                 `json:"courier_id"`
+	ReasonCode FailedAttemptReason `json:"reason_code"`
+	Notes      string              `json:"notes,omitempty"`
+	Date       time.Time           `json:"date"`
+}
+
+// One change of a parcel, read from the ledger history of its key
+type ParcelTimelineEntry struct {
+	TxID      string    `json:"tx_id"`
+	Timestamp time.Time `json:"timestamp"`
+	State     State     `json:"state,omitempty"`
+	ActorID   int       `json:"actor_id"`
+	AuctionID string    `json:"auction_id,omitempty"`
+	IsDeleted bool      `json:"is_deleted"`
+}
+
+// Field level diff of an UpdateParcel call
+type ParcelAmendment struct {
+	ParcelID int                 `json:"parcel_id"`
+	ActorID  int                 `json:"actor_id"`
+	Date     time.Time           `json:"date"`
+	TxID     string              `json:"tx_id"`
+	Changes  []ParcelFieldChange `json:"changes"`
+}
+
+type ParcelFieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"`
+	To    json.RawMessage `json:"to"`
 }
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeWeights(t *testing.T) {
+	tests := []struct {
+		name             string
+		parcel           Parcel
+		volumetricWeight float64
+		chargeableWeight float64
+	}{
+		{"without dimensions", Parcel{Weight: 2}, 0, 2},
+		{"real weight above the volumetric weight", Parcel{Weight: 12, Length: 50, Width: 40, Height: 30}, 12, 12},
+		{"volumetric weight above the real weight", Parcel{Weight: 2, Length: 50, Width: 40, Height: 30}, 12, 12},
+		{"grams", Parcel{Weight: 1500, WeightUnit: WeightUnitGram}, 0, 1.5},
+		{"pounds", Parcel{Weight: 10, WeightUnit: WeightUnitPound}, 0, 4.536},
+		{"meters", Parcel{Weight: 1, Length: 0.5, Width: 0.4, Height: 0.3, DimensionUnit: DimensionUnitMeter}, 12, 12},
+		{"inches", Parcel{Weight: 1, Length: 10, Width: 10, Height: 10, DimensionUnit: DimensionUnitInch}, 3.277, 3.277},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parcel := test.parcel
+			parcel.ComputeWeights()
+			require.Equal(t, test.volumetricWeight, parcel.VolumetricWeight)
+			require.Equal(t, test.chargeableWeight, parcel.ChargeableWeight)
+			require.NotEmpty(t, parcel.WeightUnit)
+			require.NotEmpty(t, parcel.DimensionUnit)
+		})
+	}
+}
+
+func TestParcelUnmarshalWeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		weight string
+		parsed float64
+		unit   string
+		legacy bool
+	}{
+		{"number", `2.5`, 2.5, "", false},
+		{"missing", `null`, 0, "", false},
+		{"string", `"10"`, 10, "", true},
+		{"string with comma and unit", `"2,5 kg"`, 2.5, WeightUnitKg, true},
+		{"string in grams", `"500 g"`, 500, WeightUnitGram, true},
+		{"string in pounds", `"2lb"`, 2, WeightUnitPound, true},
+		{"string that is not a weight", `"heavy"`, 0, "", true},
+		{"string with an unknown unit", `"3 oz"`, 0, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parcel Parcel
+			require.NoError(t, parcel.UnmarshalJSON([]byte(`{"id": 1, "weight": `+test.weight+`}`)))
+			require.Equal(t, 1, parcel.ID)
+			require.Equal(t, test.parsed, parcel.Weight)
+			require.Equal(t, test.unit, parcel.WeightUnit)
+			require.Equal(t, test.legacy, IsLegacyWeight([]byte(test.weight)))
+		})
+	}
+}
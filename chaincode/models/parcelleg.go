@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// A leg of a relay parcel, between two postal areas. Every leg is auctioned on
+// its own and the couriers of consecutive legs hand the parcel over at the hub.
+type ParcelLeg struct {
+	ParcelID        int       `json:"parcel_id"`
+	Leg             int       `json:"leg"`
+	FromPostalArea  string    `json:"from_postal_area"`
+	ToPostalArea    string    `json:"to_postal_area"`
+	BitcircleReward int       `json:"bitcircle_reward"`
+	State           State     `json:"state"`
+	AuctionID       string    `json:"auction_id,omitempty"`
+	CourierID       int       `json:"courier_id,omitempty"`
+	PickedUpAt      time.Time `json:"picked_up_at"`
+	DroppedOffAt    time.Time `json:"dropped_off_at"`
+	RewardPaid      int       `json:"reward_paid,omitempty"`
+}
+
+// Handover of a relay parcel at the end of FromLeg. It is complete once both
+// couriers signed it with the same proof hash.
+type ParcelHandover struct {
+	ParcelID      int       `json:"parcel_id"`
+	FromLeg       int       `json:"from_leg"`
+	HubPostalArea string    `json:"hub_postal_area"`
+	FromCourierID int       `json:"from_courier_id"`
+	ToCourierID   int       `json:"to_courier_id"`
+	ProofHash     string    `json:"proof_hash"`
+	FromSignedAt  time.Time `json:"from_signed_at"`
+	ToSignedAt    time.Time `json:"to_signed_at"`
+	Completed     bool      `json:"completed"`
+}
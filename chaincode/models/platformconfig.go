@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Platform wide settings, changed by the platform (participant 0)
+type PlatformConfig struct {
+	MaxDeliveryAttempts int              `json:"max_delivery_attempts"`
+	ReturnRewardPercent int              `json:"return_reward_percent"`
+	SlaPenaltyTiers     []SlaPenaltyTier `json:"sla_penalty_tiers"`
+	// Days before the reward Bitcircles expire, 0 keeps them forever
+	RewardExpiryDays int `json:"reward_expiry_days"`
+	// Rewards expiring within these days are shown as expiring soon
+	RewardExpiringSoonDays int `json:"reward_expiring_soon_days"`
+	// Part of the awarded money amount kept by the platform, and the days the
+	// logistic operator has to pay it
+	SettlementFeePercent int       `json:"settlement_fee_percent"`
+	SettlementDueDays    int       `json:"settlement_due_days"`
+	UpdatedBy            int       `json:"updated_by"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// Bitcircles deducted from the delivery reward for every hour late, starting at
+// FromHoursLate (0 is the first hour late)
+type SlaPenaltyTier struct {
+	FromHoursLate  int `json:"from_hours_late"`
+	PenaltyPerHour int `json:"penalty_per_hour"`
+}
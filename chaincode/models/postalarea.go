@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// A postal area of the registry. Codes are stored normalised (upper case,
+// without spaces) and adjacency is kept symmetric.
+type PostalArea struct {
+	Code         string    `json:"code"`
+	Name         string    `json:"name"`
+	Municipality string    `json:"municipality"`
+	Region       string    `json:"region"`
+	Adjacent     []string  `json:"adjacent"`
+	UpdatedBy    int       `json:"updated_by"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type ProxyBidState string
+
+const (
+	ProxyBidActive    ProxyBidState = "ACTIVE"
+	ProxyBidExhausted ProxyBidState = "EXHAUSTED"
+	ProxyBidCancelled ProxyBidState = "CANCELLED"
+)
+
+// Automatic bidding limits of a courier on one auction. Only returned to the courier that owns it.
+type ProxyBid struct {
+	AuctionID          string        `json:"auction_id"`
+	CourierID          int           `json:"courier_id"`
+	FloorAmount        int64         `json:"floor_amount"`
+	Currency           string        `json:"currency"`
+	MaxBitcircleAmount int           `json:"max_bitcircle_amount"`
+	State              ProxyBidState `json:"state"`
+	CreatedAt          time.Time     `json:"created_at"`
+	UpdatedAt          time.Time     `json:"updated_at"`
+}
+
+// The floor is in minor units of the currency, proxies stored without a currency
+// have it as a euro decimal
+func (p *ProxyBid) UnmarshalJSON(data []byte) error {
+	type proxyBidAlias ProxyBid
+	aux := struct {
+		*proxyBidAlias
+		FloorAmount json.RawMessage `json:"floor_amount"`
+	}{proxyBidAlias: (*proxyBidAlias)(p)}
+
+	err := json.Unmarshal(data, &aux)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalMoneyAmounts(&p.Currency, []json.RawMessage{aux.FloorAmount}, []*int64{&p.FloorAmount})
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type RewardLotState string
+
+// OPEN -> SPENT (consumed by payments) or EXPIRED (swept back to the treasury)
+const (
+	RewardLotOpen    RewardLotState = "OPEN"
+	RewardLotSpent   RewardLotState = "SPENT"
+	RewardLotExpired RewardLotState = "EXPIRED"
+)
+
+// Reward Bitcircles credited to a wallet by one transaction. Payments consume the
+// oldest lots first, what remains when the lot expires goes back to the treasury.
+type RewardLot struct {
+	ParticipantID int                    `json:"participant_id"`
+	TransactionID BitcircleTransactionID `json:"transaction_id"`
+	Amount        int                    `json:"amount"`
+	Remaining     int                    `json:"remaining"`
+	Expired       int                    `json:"expired"`
+	State         RewardLotState         `json:"state"`
+	CreditedAt    time.Time              `json:"credited_at"`
+	ExpiresAt     time.Time              `json:"expires_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type SettlementState string
+
+// PENDING -> PAID (by the logistic operator, off-chain) or DISPUTED, a disputed
+// settlement can still be paid
+const (
+	SettlementPending  SettlementState = "PENDING"
+	SettlementPaid     SettlementState = "PAID"
+	SettlementDisputed SettlementState = "DISPUTED"
+)
+
+// Money the logistic operator owes for an awarded auction. The platform keeps
+// the fee, the courier is owed the rest. Amounts are minor units of the currency.
+type Settlement struct {
+	AuctionID          string          `json:"auction_id"`
+	BidID              string          `json:"bid_id"`
+	LogisticOperatorID int             `json:"logistic_operator_id"`
+	CourierID          int             `json:"courier_id"`
+	MoneyAmount        int64           `json:"money_amount"`
+	PlatformFee        int64           `json:"platform_fee"`
+	CourierAmount      int64           `json:"courier_amount"`
+	Currency           string          `json:"currency"`
+	State              SettlementState `json:"state"`
+	AwardedAt          time.Time       `json:"awarded_at"`
+	DueDate            time.Time       `json:"due_date"`
+	PaymentReference   string          `json:"payment_reference,omitempty"`
+	PaidAt             *time.Time      `json:"paid_at,omitempty"`
+	DisputedBy         int             `json:"disputed_by,omitempty"`
+	DisputeReason      string          `json:"dispute_reason,omitempty"`
+	DisputedAt         *time.Time      `json:"disputed_at,omitempty"`
+}
@@ -2,9 +2,11 @@ package models
 
 import "time"
 
+// UsableBalance is the Balance minus the Escrowed Bitcircles of open escrows
 type Wallet struct {
 	ParticipantId int       `json:"participant_id"`
 	Balance       int       `json:"balance"`
 	UsableBalance int       `json:"usable_balance"`
+	Escrowed      int       `json:"escrowed"`
 	LastMovement  time.Time `json:"last_movement"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Wallet blocked by the platform, the participant can not move its Bitcircles
+// until it is unfrozen
+type WalletFreeze struct {
+	ParticipantID int       `json:"participant_id"`
+	Reason        string    `json:"reason"`
+	FrozenBy      int       `json:"frozen_by"`
+	Date          time.Time `json:"date"`
+}
+
+type WalletHoldState string
+
+// ACTIVE -> RELEASED, an active hold past its expiry no longer counts
+const (
+	WalletHoldActive   WalletHoldState = "ACTIVE"
+	WalletHoldReleased WalletHoldState = "RELEASED"
+)
+
+// Bitcircles of a wallet the participant can not spend, kept for a dispute
+type WalletHold struct {
+	ID              string          `json:"id"`
+	ParticipantID   int             `json:"participant_id"`
+	BitcircleAmount int             `json:"bitcircle_amount"`
+	Reason          string          `json:"reason"`
+	State           WalletHoldState `json:"state"`
+	PlacedBy        int             `json:"placed_by"`
+	PlacedAt        time.Time       `json:"placed_at"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"`
+	ReleasedAt      *time.Time      `json:"released_at,omitempty"`
+}
@@ -0,0 +1,115 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** MONEY (amounts in minor units of a currency)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryAuctionStart", "[{\"auction_id\":\"A8\",\"parcel_id\":13}]", "{\"id\":\"A8\",\"start_date\":\"2022-05-09T10:00:00Z\",\"end_date\":\"2022-05-10T10:00:00Z\",\"maximum_accepted_licitation\":10050,\"currency\":\"EUR\",\"participant_id\":2}"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["MigrateMoneyAmounts", "0"]}'
+*/
+
+// Rewrites the records of the entity stored without a currency, their amounts
+// are read as euro decimals by the models
+func (s *AuctionSmartContract) migrateMoneyRecords(stub shim.ChaincodeStubInterface, entity Entity, newRecord func() interface{}) (int, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(entity), []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer iterator.Close()
+
+	migrated := 0
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return migrated, err
+		}
+
+		legacy, err := models.IsLegacyMoney(response.Value)
+		if err != nil {
+			return migrated, err
+		}
+		if !legacy {
+			continue
+		}
+
+		record := newRecord()
+		err = json.Unmarshal(response.Value, record)
+		if err != nil {
+			return migrated, fmt.Errorf("%s %s: %w", entity, response.Key, err)
+		}
+
+		dataRecord, err := json.Marshal(record)
+		if err != nil {
+			return migrated, err
+		}
+
+		_, err = s.UpsertEntityRecord(stub, response.Key, dataRecord)
+		if err != nil {
+			return migrated, err
+		}
+		migrated = migrated + 1
+	}
+
+	return migrated, nil
+}
+
+// Stores the float amounts of the auctions, bids, proxy bids and escrows saved
+// before the currency as minor units of euro
+func (s *AuctionSmartContract) MigrateMoneyAmounts(stub shim.ChaincodeStubInterface, participantID int) pb.Response {
+	fmt.Println("MigrateMoneyAmounts Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can migrate the money amounts"))
+	}
+
+	var response struct {
+		Auctions  int `json:"auctions"`
+		Bids      int `json:"bids"`
+		ProxyBids int `json:"proxy_bids"`
+		Escrows   int `json:"escrows"`
+	}
+
+	var err error
+	response.Auctions, err = s.migrateMoneyRecords(stub, EntityAuction, func() interface{} { return &models.Auction{} })
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response.Bids, err = s.migrateMoneyRecords(stub, EntityBid, func() interface{} { return &models.Bid{} })
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response.ProxyBids, err = s.migrateMoneyRecords(stub, EntityProxyBid, func() interface{} { return &models.ProxyBid{} })
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response.Escrows, err = s.migrateMoneyRecords(stub, EntityEscrow, func() interface{} { return &models.Escrow{} })
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** MONEY (amounts in minor units of a currency)
+// ** -> END
+// ** -----------------------------------------------------
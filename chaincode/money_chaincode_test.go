@@ -0,0 +1,92 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBidMoneyAmount(t *testing.T) {
+	tests := []struct {
+		amount      string
+		moneyAmount int64
+		code        int
+	}{
+		{"80.00", 8000, 0},
+		{"80,5", 8050, 0},
+		{"80", 8000, 0},
+		{"80.005", 0, http.StatusBadRequest},
+		{"eighty", 0, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.amount, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{3: 100}).parcel(1, nil)
+			c.auction("A1", []int{1}, nil)
+
+			var bid models.Bid
+			if test.code != 0 {
+				c.fails(test.code, "ParcelDeliveryBidingRequest", "b1", "A1", test.amount, "0", "3")
+				require.False(t, c.entity(EntityBid, &bid, "b1", "A1"))
+				return
+			}
+
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", test.amount, "0", "3")
+			require.True(t, c.entity(EntityBid, &bid, "b1", "A1"))
+			require.Equal(t, test.moneyAmount, bid.MoneyAmount)
+			require.Equal(t, "EUR", bid.Currency)
+		})
+	}
+}
+
+func TestMigrateMoneyAmounts(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{3: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "80.00", "0", "3")
+
+	// Auction A2 and its bid as they were stored before the currency
+	c.stub.MockTransactionStart("legacy")
+	for _, record := range []struct {
+		entity Entity
+		ids    []string
+		value  string
+	}{
+		{EntityAuction, []string{"A2"}, `{"id": "A2", "state": "Open", "maximum_accepted_licitation": 100.5, "participant_id": 9}`},
+		{EntityBid, []string{"b2", "A2"}, `{"id": "b2", "auction_id": "A2", "money_amount": 90.25, "status": "LowerBid", "courier_id": 3}`},
+	} {
+		key, err := c.stub.CreateCompositeKey(string(record.entity), record.ids)
+		require.NoError(t, err)
+		require.NoError(t, c.stub.PutState(key, []byte(record.value)))
+	}
+	c.stub.MockTransactionEnd("legacy")
+
+	c.as(3).fails(http.StatusForbidden, "MigrateMoneyAmounts", "3")
+
+	var response struct {
+		Auctions  int `json:"auctions"`
+		Bids      int `json:"bids"`
+		ProxyBids int `json:"proxy_bids"`
+		Escrows   int `json:"escrows"`
+	}
+	c.as(PlatformWalletId).ok(&response, "MigrateMoneyAmounts", "0")
+	require.Equal(t, 1, response.Auctions)
+	require.Equal(t, 1, response.Bids)
+	require.Zero(t, response.ProxyBids)
+	require.Zero(t, response.Escrows)
+
+	var auction models.Auction
+	require.True(t, c.entity(EntityAuction, &auction, "A2"))
+	require.Equal(t, int64(10050), auction.MaximumAcceptedLicitation)
+	require.Equal(t, "EUR", auction.Currency)
+	var bid models.Bid
+	require.True(t, c.entity(EntityBid, &bid, "b2", "A2"))
+	require.Equal(t, int64(9025), bid.MoneyAmount)
+
+	// The records already in minor units are left as they are
+	require.True(t, c.entity(EntityAuction, &auction, "A1"))
+	require.Equal(t, int64(10000), auction.MaximumAcceptedLicitation)
+	c.ok(&response, "MigrateMoneyAmounts", "0")
+	require.Zero(t, response.Auctions+response.Bids)
+}
@@ -1,15 +1,18 @@
 package micolec
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"micolec/chaincode/models"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
 
@@ -48,11 +51,28 @@ func validateParcelDeliveryParcelAdded(parcel *models.Parcel) error {
 			append(errorMessages, "BitcircleReward Higher Equal 0. Current value: "+strconv.Itoa(parcel.BitcircleReward))
 	}
 
-	// TODO -> Validar com o Valentim e sugerir alteração para Int ou float
-	// if !(parcel.Weight > 0) {
-	// 	errorMessages =
-	// 		append(errorMessages, "Weight Higher than 0")
-	// }
+	if !(parcel.Weight > 0) {
+		errorMessages =
+			append(errorMessages, "Weight Higher than 0")
+	}
+
+	if parcel.WeightUnit != "" && !models.IsWeightUnit(parcel.WeightUnit) {
+		errorMessages =
+			append(errorMessages, "Invalid WeightUnit, most be 'kg', 'g' or 'lb'")
+	}
+
+	// Dimensions are optional, but all of them are given when one is
+	if parcel.Length != 0 || parcel.Width != 0 || parcel.Height != 0 {
+		if !(parcel.Length > 0 && parcel.Width > 0 && parcel.Height > 0) {
+			errorMessages =
+				append(errorMessages, "Length, Width and Height Higher than 0")
+		}
+	}
+
+	if parcel.DimensionUnit != "" && !models.IsDimensionUnit(parcel.DimensionUnit) {
+		errorMessages =
+			append(errorMessages, "Invalid DimensionUnit, most be 'cm', 'mm', 'm' or 'in'")
+	}
 
 	if !(parcel.Volumes > 0) {
 		errorMessages =
@@ -157,7 +177,7 @@ func (s *AuctionSmartContract) ReadParcelsByState(stub shim.ChaincodeStubInterfa
 }
 
 /* Command to create a Parcel
-CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryParcelAdded","{\"id\":2,\"state\":\"Pending\",\"added_to_platform\":\"2023-05-11T00:00:00Z\",\"required_delivery_date\":\"2023-05-15T12:00:00Z\",\"pickup_postal_area\":\"Area1\",\"delivery_postal_area\":\"Area2\",\"notified_ce_option\":false,\"bitcircle_reward\":100,\"weight\":10,\"length\":20,\"width\":15,\"height\":30,\"volume\":900}"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryParcelAdded","{\"id\":2,\"state\":\"Pending\",\"added_to_platform\":\"2023-05-11T00:00:00Z\",\"required_delivery_date\":\"2023-05-15T12:00:00Z\",\"pickup_postal_area\":\"Area1\",\"delivery_postal_area\":\"Area2\",\"notified_ce_option\":false,\"bitcircle_reward\":100,\"weight\":10,\"weight_unit\":\"kg\",\"length\":20,\"width\":15,\"height\":30,\"dimension_unit\":\"cm\",\"volume\":900}"]}'
 */
 
 func (s *AuctionSmartContract) ParcelDeliveryParcelAdded(stub shim.ChaincodeStubInterface, parcel models.Parcel) pb.Response {
@@ -181,17 +201,513 @@ func (s *AuctionSmartContract) ParcelDeliveryParcelAdded(stub shim.ChaincodeStub
 		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
 	}
 
+	if err := validateParcelPostalAreas(stub, &parcel); err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
 	// Insert Parcel on the blockchain
-	jsonData, err := json.Marshal(parcel)
+	resetNewParcel(&parcel)
+	jsonData, err := s.putParcel(stub, parcelKey, &parcel, parcel.LogisticOperatorId)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(jsonData)
+}
+
+// Fields set by the contract over the life of the parcel start empty
+func resetNewParcel(parcel *models.Parcel) {
+	parcel.AssignedCourierId = 0
+	parcel.AssignedAuctionId = ""
+	parcel.AuctionId = ""
+	// Set by the contract once the end customer registers delivery preferences
+	parcel.NotifiedCeOption = false
+	parcel.RelayLegs = 0
+	parcel.CurrentLeg = 0
+	parcel.DeliveryAttempts = 0
+}
+
+// Largest batch accepted by ParcelDeliveryParcelsAdded
+const MaxParcelsPerBatch = 500
+
+const (
+	ParcelBatchCreated   = "created"
+	ParcelBatchValid     = "valid"
+	ParcelBatchDuplicate = "duplicate"
+	ParcelBatchInvalid   = "invalid"
+)
+
+type parcelBatchResult struct {
+	Index  int      `json:"index"`
+	ID     int      `json:"id"`
+	Status string   `json:"status"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryParcelsAdded", "[{\"id\":12,\"state\":\"Pending\",\"added_to_platform\":\"2023-05-11T00:00:00Z\",\"required_delivery_date\":\"2023-05-15T12:00:00Z\",\"pickup_postal_area\":\"Area1\",\"delivery_postal_area\":\"Area2\",\"bitcircle_reward\":100,\"weight\":10,\"volume\":1,\"logistic_operator_id\":2,\"end_customer_id\":11},{\"id\":13,\"state\":\"Pending\",\"added_to_platform\":\"2023-05-11T00:00:00Z\",\"required_delivery_date\":\"2023-05-16T12:00:00Z\",\"pickup_postal_area\":\"Area1\",\"delivery_postal_area\":\"Area3\",\"bitcircle_reward\":80,\"weight\":2.5,\"volume\":1,\"logistic_operator_id\":2,\"end_customer_id\":12}]", "true"]}'
+*/
+
+// Registers a batch of parcels with the same validation as ParcelDeliveryParcelAdded.
+// Every parcel is checked before anything is written and gets its own result:
+// created, duplicate (on the ledger or earlier in the batch) or invalid with the
+// field errors. In all-or-nothing mode one rejected parcel rejects the batch and
+// the valid parcels are reported as valid, not created.
+func (s *AuctionSmartContract) ParcelDeliveryParcelsAdded(stub shim.ChaincodeStubInterface, parcels []models.Parcel, allOrNothing bool) pb.Response {
+	fmt.Println("ParcelDeliveryParcelsAdded Invoke")
+	if len(parcels) == 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "No parcels on the batch"))
+	}
+	if len(parcels) > MaxParcelsPerBatch {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The batch has ", len(parcels), " parcels, the maximum is ", MaxParcelsPerBatch)))
+	}
+
+	postalAreas, err := getPostalAreas(stub)
 	if err != nil {
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
-	_, err = s.UpsertEntityRecord(stub, parcelKey, jsonData)
+
+	var response struct {
+		Committed  bool                `json:"committed"`
+		Created    int                 `json:"created"`
+		Duplicates int                 `json:"duplicates"`
+		Invalid    int                 `json:"invalid"`
+		Results    []parcelBatchResult `json:"results"`
+	}
+
+	// First pass: validate every parcel, nothing is written yet
+	parcelKeys := make([]string, len(parcels))
+	seen := map[int]bool{}
+	for i := range parcels {
+		parcel := &parcels[i]
+		result := parcelBatchResult{Index: i, ID: parcel.ID, Status: ParcelBatchValid}
+
+		if err := validateParcelDeliveryParcelAdded(parcel); err != nil {
+			result.Status = ParcelBatchInvalid
+			result.Errors = strings.Split(err.Error(), "\n")
+		} else if err := checkParcelPostalAreas(parcel, postalAreas); err != nil {
+			result.Status = ParcelBatchInvalid
+			result.Errors = strings.Split(err.Error(), "\n")
+		} else if seen[parcel.ID] {
+			result.Status = ParcelBatchDuplicate
+			result.Errors = []string{fmt.Sprint("The parcel with id ", parcel.ID, " is more than once on the batch")}
+		} else {
+			parcelKeys[i], err = s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(parcel.ID)})
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			parcelRecordExists, err := s.EntityRecordExists(stub, parcelKeys[i])
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+			if parcelRecordExists {
+				result.Status = ParcelBatchDuplicate
+				result.Errors = []string{"Record Already Exists"}
+			}
+		}
+		if parcel.ID > 0 {
+			seen[parcel.ID] = true
+		}
+
+		switch result.Status {
+		case ParcelBatchDuplicate:
+			response.Duplicates = response.Duplicates + 1
+		case ParcelBatchInvalid:
+			response.Invalid = response.Invalid + 1
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	// Second pass: write the valid parcels
+	response.Committed = !allOrNothing || (response.Duplicates == 0 && response.Invalid == 0)
+	if response.Committed {
+		for i := range parcels {
+			if response.Results[i].Status != ParcelBatchValid {
+				continue
+			}
+
+			resetNewParcel(&parcels[i])
+			_, err = s.putParcel(stub, parcelKeys[i], &parcels[i], parcels[i].LogisticOperatorId)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			response.Results[i].Status = ParcelBatchCreated
+			response.Created = response.Created + 1
+		}
+	}
+
+	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
 
-	return shim.Success(jsonData)
+	return shim.Success(responseJSON)
+}
+
+func (s *AuctionSmartContract) readParcel(stub shim.ChaincodeStubInterface, parcelID int) (models.Parcel, string, error) {
+	var parcel models.Parcel
+
+	parcelKey, err := s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return parcel, parcelKey, err
+	}
+
+	parcelJSON, err := s.ReadEntity(stub, parcelKey)
+	if err != nil {
+		return parcel, parcelKey, err
+	}
+
+	err = json.Unmarshal(parcelJSON, &parcel)
+	return parcel, parcelKey, err
+}
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["UpdateParcel", "2", "{\"id\":2,\"required_delivery_date\":\"2023-05-16T12:00:00Z\",\"pickup_postal_area\":\"Area1\",\"delivery_postal_area\":\"Area3\",\"bitcircle_reward\":100,\"weight\":10,\"weight_unit\":\"kg\",\"volume\":900}"]}'
+*/
+
+// Field level differences between two versions of a parcel, by JSON field name
+func diffParcels(before models.Parcel, after models.Parcel) ([]models.ParcelFieldChange, error) {
+	var beforeFields, afterFields map[string]json.RawMessage
+
+	beforeJSON, err := json.Marshal(before)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(beforeJSON, &beforeFields)
+	if err != nil {
+		return nil, err
+	}
+
+	afterJSON, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(afterJSON, &afterFields)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for field := range beforeFields {
+		fields = append(fields, field)
+	}
+	for field := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	// Map order is random, the diff has to be the same on every peer
+	sort.Strings(fields)
+
+	changes := []models.ParcelFieldChange{}
+	for _, field := range fields {
+		from, to := beforeFields[field], afterFields[field]
+		if bytes.Equal(from, to) {
+			continue
+		}
+		if from == nil {
+			from = json.RawMessage("null")
+		}
+		if to == nil {
+			to = json.RawMessage("null")
+		}
+		changes = append(changes, models.ParcelFieldChange{Field: field, From: from, To: to})
+	}
+
+	return changes, nil
+}
+
+// The owning logistic operator amends a parcel while it is Pending. The state,
+// ownership and delivery tracking fields are kept from the stored parcel.
+func (s *AuctionSmartContract) UpdateParcel(stub shim.ChaincodeStubInterface, logisticOperatorID int, update models.Parcel) pb.Response {
+	fmt.Println("UpdateParcel Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, update.ID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if parcel.LogisticOperatorId != logisticOperatorID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("The parcel with id ", parcel.ID, " do not belong to current user")))
+	}
+
+	if parcel.State != models.State(models.ParcelStatePending) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", parcel.ID, " is not on 'Pending' state.")))
+	}
+
+	update.State = parcel.State
+	update.AddedToPlatform = parcel.AddedToPlatform
+	update.LogisticOperatorId = parcel.LogisticOperatorId
+	update.AssignedCourierId = parcel.AssignedCourierId
+	update.AssignedAuctionId = parcel.AssignedAuctionId
+	update.AuctionId = parcel.AuctionId
+	update.UpdatedBy = parcel.UpdatedBy
+	update.DeliveryAttempts = parcel.DeliveryAttempts
+	update.NotifiedCeOption = parcel.NotifiedCeOption
+	update.RelayLegs = parcel.RelayLegs
+	update.CurrentLeg = parcel.CurrentLeg
+
+	if err := validateParcelDeliveryParcelAdded(&update); err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	if err := validateParcelPostalAreas(stub, &update); err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	// The legs of a relay parcel go from the pickup to the delivery area and share its reward
+	if parcel.RelayLegs != 0 && (update.PickupPostalArea != normalizePostalArea(parcel.PickupPostalArea) ||
+		update.DeliveryPostalArea != normalizePostalArea(parcel.DeliveryPostalArea) || update.BitcircleReward != parcel.BitcircleReward) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The route of the parcel with id ", parcel.ID, " is split in legs, join it with SplitParcelRoute before changing its postal areas or reward")))
+	}
+
+	update.ComputeWeights()
+	changes, err := diffParcels(parcel, update)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if len(changes) == 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Nothing to update on the parcel"))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	amendment := models.ParcelAmendment{
+		ParcelID: parcel.ID,
+		ActorID:  logisticOperatorID,
+		Date:     currentTime,
+		TxID:     stub.GetTxID(),
+		Changes:  changes,
+	}
+
+	amendmentKey, err := s.CreateCompositeKey(stub, EntityParcelAmendment, []string{fmt.Sprint(parcel.ID), amendment.TxID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataAmendment, err := json.Marshal(amendment)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, amendmentKey, dataAmendment)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataParcel, err := s.putParcel(stub, parcelKey, &update, logisticOperatorID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(dataParcel)
+}
+
+func (s *AuctionSmartContract) GetParcelAmendments(stub shim.ChaincodeStubInterface, parcelID int) pb.Response {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityParcelAmendment), []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var amendments []models.ParcelAmendment
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var amendment models.ParcelAmendment
+		err = json.Unmarshal(response.Value, &amendment)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		amendments = append(amendments, amendment)
+	}
+
+	// Keys are ordered by tx ID, the amendments are listed by date
+	sort.SliceStable(amendments, func(i, j int) bool {
+		return amendments[i].Date.Before(amendments[j].Date)
+	})
+
+	amendmentsJSON, err := json.Marshal(amendments)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(amendmentsJSON)
+}
+
+// Rewrites the parcels stored with a string weight with the numeric weight and
+// the computed weights. Parcels whose weight cannot be read are reported and left as they are.
+func (s *AuctionSmartContract) MigrateParcelWeights(stub shim.ChaincodeStubInterface, participantID int) pb.Response {
+	fmt.Println("MigrateParcelWeights Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can migrate the parcels"))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityParcel), []string{})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var response struct {
+		Migrated []int `json:"migrated"`
+		Failed   []int `json:"failed"`
+	}
+	response.Migrated = []int{}
+	response.Failed = []int{}
+
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var rawParcel struct {
+			Weight json.RawMessage `json:"weight"`
+		}
+		err = json.Unmarshal(record.Value, &rawParcel)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		if !models.IsLegacyWeight(rawParcel.Weight) {
+			continue
+		}
+
+		var parcel models.Parcel
+		err = json.Unmarshal(record.Value, &parcel)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		if !(parcel.Weight > 0) {
+			response.Failed = append(response.Failed, parcel.ID)
+			continue
+		}
+
+		_, err = s.putParcel(stub, record.Key, &parcel, participantID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		response.Migrated = append(response.Migrated, parcel.ID)
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// Every parcel write goes through here so the ledger history of the parcel
+// records who made each change
+func (s *AuctionSmartContract) putParcel(stub shim.ChaincodeStubInterface, parcelKey string, parcel *models.Parcel, actorID int) ([]byte, error) {
+	parcel.UpdatedBy = actorID
+	parcel.ComputeWeights()
+
+	dataParcel, err := json.Marshal(parcel)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, parcelKey, dataParcel)
+	if err != nil {
+		return nil, err
+	}
+
+	return dataParcel, nil
+}
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetParcelTimeline", "2"]}'
+*/
+
+// Every state change of the parcel, oldest first. Deletions (DeleteAllParcels)
+// are kept in the timeline flagged as deleted.
+func (s *AuctionSmartContract) GetParcelTimeline(stub shim.ChaincodeStubInterface, parcelID int) pb.Response {
+	parcelKey, err := s.CreateCompositeKey(stub, EntityParcel, []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	iterator, err := stub.GetHistoryForKey(parcelKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	// The history comes newest first, it is put oldest first before only the
+	// changes of state are kept
+	var modifications []*queryresult.KeyModification
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		modifications = append(modifications, modification)
+	}
+	for i, j := 0, len(modifications)-1; i < j; i, j = i+1, j-1 {
+		modifications[i], modifications[j] = modifications[j], modifications[i]
+	}
+	sort.SliceStable(modifications, func(i, j int) bool {
+		return modifications[i].Timestamp.AsTime().Before(modifications[j].Timestamp.AsTime())
+	})
+
+	var timeline []models.ParcelTimelineEntry
+	var lastState models.State
+	for _, modification := range modifications {
+		entry := models.ParcelTimelineEntry{
+			TxID:      modification.TxId,
+			IsDeleted: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = modification.Timestamp.AsTime().UTC()
+		}
+
+		if !modification.IsDelete {
+			var parcel models.Parcel
+			err = json.Unmarshal(modification.Value, &parcel)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			// Only the changes of state are part of the timeline
+			if len(timeline) > 0 && !timeline[len(timeline)-1].IsDeleted && parcel.State == lastState {
+				continue
+			}
+
+			entry.State = parcel.State
+			entry.ActorID = parcel.UpdatedBy
+			entry.AuctionID = parcel.AuctionId
+			lastState = parcel.State
+		}
+
+		timeline = append(timeline, entry)
+	}
+
+	if len(timeline) == 0 {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("Parcel with id ", parcelID, " doesn't exist")))
+	}
+
+	timelineJSON, err := json.Marshal(timeline)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(timelineJSON)
 }
 
 func (s *AuctionSmartContract) DeleteAllParcels(stub shim.ChaincodeStubInterface) pb.Response {
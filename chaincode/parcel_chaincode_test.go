@@ -0,0 +1,314 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetParcelTimeline(t *testing.T) {
+	c := newDeliveryContract(t)
+	c.as(3).ok(nil, "ConfirmPickup", "3", "1", testProofHash("pickup"))
+	c.ok(nil, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+	c.as(11).ok(nil, "CountersignDelivery", "11", "1")
+	c.as(PlatformWalletId).ok(nil, "DeleteAllParcels")
+	c.parcel(1, nil)
+
+	var timeline []models.ParcelTimelineEntry
+	c.ok(&timeline, "GetParcelTimeline", "1")
+
+	tests := []struct {
+		state     models.Status
+		auctionID string
+		deleted   bool
+	}{
+		{models.ParcelStatePending, "", false},
+		{models.ParcelStateAuction, "A1", false},
+		{models.ParcelStateDelivery, "A1", false},
+		{models.ParcelStateDelivered, "A1", false},
+		{"", "", true},
+		{models.ParcelStatePending, "", false},
+	}
+
+	// Oldest first, only the changes of state and the deletions
+	require.Len(t, timeline, len(tests))
+	for i, test := range tests {
+		entry := timeline[i]
+		require.EqualValues(t, test.state, entry.State)
+		require.Equal(t, test.auctionID, entry.AuctionID)
+		require.Equal(t, test.deleted, entry.IsDeleted)
+		require.NotEmpty(t, entry.TxID)
+		if i > 0 {
+			require.True(t, entry.Timestamp.After(timeline[i-1].Timestamp))
+		}
+	}
+	require.Equal(t, 11, timeline[3].ActorID)
+
+	c.fails(http.StatusNotFound, "GetParcelTimeline", "2")
+}
+
+func TestDiffParcels(t *testing.T) {
+	before := models.Parcel{ID: 1, PickupPostalArea: "1000", DeliveryPostalArea: "2000", Weight: 2, Volumes: 1}
+
+	tests := []struct {
+		name    string
+		update  func(parcel *models.Parcel)
+		changes []models.ParcelFieldChange
+	}{
+		{"no change", func(parcel *models.Parcel) {}, []models.ParcelFieldChange{}},
+		{"one field", func(parcel *models.Parcel) { parcel.DeliveryPostalArea = "3000" }, []models.ParcelFieldChange{
+			{Field: "delivery_postal_area", From: json.RawMessage(`"2000"`), To: json.RawMessage(`"3000"`)},
+		}},
+		{"sorted by field", func(parcel *models.Parcel) {
+			parcel.Weight = 3
+			parcel.BitcircleReward = 20
+		}, []models.ParcelFieldChange{
+			{Field: "bitcircle_reward", From: json.RawMessage(`0`), To: json.RawMessage(`20`)},
+			{Field: "weight", From: json.RawMessage(`2`), To: json.RawMessage(`3`)},
+		}},
+		{"field added", func(parcel *models.Parcel) { parcel.WeightUnit = models.WeightUnitGram }, []models.ParcelFieldChange{
+			{Field: "weight_unit", From: json.RawMessage(`null`), To: json.RawMessage(`"g"`)},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			after := before
+			test.update(&after)
+			changes, err := diffParcels(before, after)
+			require.NoError(t, err)
+			require.Equal(t, test.changes, changes)
+		})
+	}
+}
+
+func TestUpdateParcel(t *testing.T) {
+	tests := []struct {
+		name               string
+		before             func(c *testContract)
+		callerID           int
+		logisticOperatorID int
+		update             func(parcel *models.Parcel)
+		code               int
+	}{
+		{"by the owning operator", nil, testOperatorID, testOperatorID, func(parcel *models.Parcel) {
+			parcel.DeliveryPostalArea = "3000"
+			parcel.RequiredDeliveryDate = parcel.RequiredDeliveryDate.Add(24 * time.Hour)
+		}, 0},
+		{"by another operator", nil, 8, 8, func(parcel *models.Parcel) { parcel.DeliveryPostalArea = "3000" }, http.StatusForbidden},
+		{"signed by another operator", nil, 8, testOperatorID, func(parcel *models.Parcel) { parcel.DeliveryPostalArea = "3000" }, http.StatusForbidden},
+		{"invalid postal area", nil, testOperatorID, testOperatorID, func(parcel *models.Parcel) { parcel.DeliveryPostalArea = "30" }, http.StatusBadRequest},
+		{"nothing to update", nil, testOperatorID, testOperatorID, func(parcel *models.Parcel) {}, http.StatusBadRequest},
+		{"only the state changed", nil, testOperatorID, testOperatorID, func(parcel *models.Parcel) {
+			parcel.State = models.State(models.ParcelStateDelivered)
+		}, http.StatusBadRequest},
+		{"on auction", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+		}, testOperatorID, testOperatorID, func(parcel *models.Parcel) { parcel.DeliveryPostalArea = "3000" }, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).parcel(1, nil)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			stored := parcel
+			test.update(&parcel)
+			c.as(test.callerID)
+
+			var amendments []models.ParcelAmendment
+			if test.code != 0 {
+				c.fails(test.code, "UpdateParcel", fmt.Sprint(test.logisticOperatorID), toJSON(t, parcel))
+
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+				require.Equal(t, stored, parcel)
+				c.ok(&amendments, "GetParcelAmendments", "1")
+				require.Empty(t, amendments)
+				return
+			}
+
+			var updated models.Parcel
+			c.ok(&updated, "UpdateParcel", fmt.Sprint(test.logisticOperatorID), toJSON(t, parcel))
+			require.Equal(t, "3000", updated.DeliveryPostalArea)
+			require.Equal(t, test.logisticOperatorID, updated.UpdatedBy)
+			updatedAt := c.ledger.now
+
+			c.ok(&amendments, "GetParcelAmendments", "1")
+			require.Len(t, amendments, 1)
+			require.Equal(t, test.logisticOperatorID, amendments[0].ActorID)
+			require.True(t, amendments[0].Date.Equal(updatedAt))
+
+			var fields []string
+			for _, change := range amendments[0].Changes {
+				fields = append(fields, change.Field)
+			}
+			require.Equal(t, []string{"delivery_postal_area", "required_delivery_date"}, fields)
+		})
+	}
+}
+
+func TestValidateParcelDimensions(t *testing.T) {
+	tests := []struct {
+		name   string
+		update func(parcel *models.Parcel)
+		valid  bool
+	}{
+		{"weight only", func(parcel *models.Parcel) {}, true},
+		{"every dimension", func(parcel *models.Parcel) { parcel.Length, parcel.Width, parcel.Height = 30, 20, 10 }, true},
+		{"missing dimension", func(parcel *models.Parcel) { parcel.Length, parcel.Width = 30, 20 }, false},
+		{"negative dimension", func(parcel *models.Parcel) { parcel.Length, parcel.Width, parcel.Height = 30, -20, 10 }, false},
+		{"no weight", func(parcel *models.Parcel) { parcel.Weight = 0 }, false},
+		{"unknown weight unit", func(parcel *models.Parcel) { parcel.WeightUnit = "t" }, false},
+		{"unknown dimension unit", func(parcel *models.Parcel) { parcel.DimensionUnit = "ft" }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parcel := models.Parcel{
+				ID:                   1,
+				State:                models.State(models.ParcelStatePending),
+				AddedToPlatform:      testStartTime,
+				RequiredDeliveryDate: testStartTime.Add(48 * time.Hour),
+				PickupPostalArea:     "1000",
+				DeliveryPostalArea:   "2000",
+				Weight:               2,
+				Volumes:              1,
+				LogisticOperatorId:   testOperatorID,
+			}
+			test.update(&parcel)
+			require.Equal(t, test.valid, validateParcelDeliveryParcelAdded(&parcel) == nil)
+		})
+	}
+}
+
+func TestMigrateParcelWeights(t *testing.T) {
+	c := newTestContract(t).parcel(1, nil).parcel(2, nil).parcel(3, nil).parcel(4, nil)
+
+	// Parcels 2 to 4 as they were stored before the weight was numeric
+	for id, weight := range map[string]string{"2": "2,5 kg", "3": "heavy", "4": "500 g"} {
+		key, err := c.stub.CreateCompositeKey(string(EntityParcel), []string{id})
+		require.NoError(t, err)
+		var parcel map[string]interface{}
+		require.NoError(t, json.Unmarshal(c.stub.State[key], &parcel))
+		parcel["weight"] = weight
+		delete(parcel, "chargeable_weight")
+		delete(parcel, "weight_unit")
+		c.stub.State[key] = []byte(toJSON(t, parcel))
+	}
+
+	c.as(2).fails(http.StatusForbidden, "MigrateParcelWeights", "2")
+
+	var response struct {
+		Migrated []int `json:"migrated"`
+		Failed   []int `json:"failed"`
+	}
+	c.as(PlatformWalletId).ok(&response, "MigrateParcelWeights", "0")
+	require.Equal(t, []int{2, 4}, response.Migrated)
+	require.Equal(t, []int{3}, response.Failed)
+
+	var parcel models.Parcel
+	require.True(t, c.entity(EntityParcel, &parcel, "2"))
+	require.Equal(t, 2.5, parcel.Weight)
+	require.Equal(t, 2.5, parcel.ChargeableWeight)
+	require.Equal(t, models.WeightUnitKg, parcel.WeightUnit)
+
+	// The weight keeps the unit it was written in
+	require.True(t, c.entity(EntityParcel, &parcel, "4"))
+	require.Equal(t, 500.0, parcel.Weight)
+	require.Equal(t, 0.5, parcel.ChargeableWeight)
+	require.Equal(t, models.WeightUnitGram, parcel.WeightUnit)
+
+	// Migrated parcels are not migrated again
+	c.ok(&response, "MigrateParcelWeights", "0")
+	require.Empty(t, response.Migrated)
+	require.Equal(t, []int{3}, response.Failed)
+}
+
+// Valid parcel of the test operator for a batch
+func testBatchParcel(id int) models.Parcel {
+	return models.Parcel{
+		ID:                   id,
+		State:                models.State(models.ParcelStatePending),
+		AddedToPlatform:      testStartTime,
+		RequiredDeliveryDate: testStartTime.Add(48 * time.Hour),
+		PickupPostalArea:     "1000",
+		DeliveryPostalArea:   "2000",
+		BitcircleReward:      10,
+		Weight:               2,
+		Volumes:              1,
+		LogisticOperatorId:   testOperatorID,
+		EndCustomerId:        11,
+	}
+}
+
+func TestParcelDeliveryParcelsAdded(t *testing.T) {
+	invalid := testBatchParcel(3)
+	invalid.Weight = 0
+	batch := []models.Parcel{testBatchParcel(2), testBatchParcel(1), invalid, testBatchParcel(2), testBatchParcel(4)}
+
+	tests := []struct {
+		name         string
+		allOrNothing string
+		committed    bool
+		statuses     []string
+		created      int
+	}{
+		{"valid parcels are created", "false", true,
+			[]string{ParcelBatchCreated, ParcelBatchDuplicate, ParcelBatchInvalid, ParcelBatchDuplicate, ParcelBatchCreated}, 2},
+		{"all or nothing", "true", false,
+			[]string{ParcelBatchValid, ParcelBatchDuplicate, ParcelBatchInvalid, ParcelBatchDuplicate, ParcelBatchValid}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).parcel(1, nil)
+
+			var response struct {
+				Committed  bool                `json:"committed"`
+				Created    int                 `json:"created"`
+				Duplicates int                 `json:"duplicates"`
+				Invalid    int                 `json:"invalid"`
+				Results    []parcelBatchResult `json:"results"`
+			}
+			c.ok(&response, "ParcelDeliveryParcelsAdded", toJSON(t, batch), test.allOrNothing)
+			require.Equal(t, test.committed, response.Committed)
+			require.Equal(t, test.created, response.Created)
+			require.Equal(t, 2, response.Duplicates)
+			require.Equal(t, 1, response.Invalid)
+
+			var statuses []string
+			for i, result := range response.Results {
+				require.Equal(t, i, result.Index)
+				require.Equal(t, batch[i].ID, result.ID)
+				statuses = append(statuses, result.Status)
+			}
+			require.Equal(t, test.statuses, statuses)
+			require.Equal(t, []string{"Weight Higher than 0"}, response.Results[2].Errors)
+
+			var parcel models.Parcel
+			require.Equal(t, test.committed, c.entity(EntityParcel, &parcel, "2"))
+			require.False(t, c.entity(EntityParcel, &parcel, "3"))
+			require.Equal(t, test.committed, c.entity(EntityParcel, &parcel, "4"))
+		})
+	}
+
+	c := newTestContract(t)
+	c.fails(http.StatusBadRequest, "ParcelDeliveryParcelsAdded", "[]")
+
+	tooMany := make([]models.Parcel, MaxParcelsPerBatch+1)
+	for i := range tooMany {
+		tooMany[i] = testBatchParcel(i + 1)
+	}
+	c.fails(http.StatusBadRequest, "ParcelDeliveryParcelsAdded", toJSON(t, tooMany))
+	var parcel models.Parcel
+	require.False(t, c.entity(EntityParcel, &parcel, "1"))
+}
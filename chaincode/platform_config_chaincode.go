@@ -0,0 +1,161 @@
+package micolec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** PLATFORM CONFIG
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["SetPlatformConfig", "0", "{\"max_delivery_attempts\":3,\"return_reward_percent\":50,\"sla_penalty_tiers\":[{\"from_hours_late\":0,\"penalty_per_hour\":1},{\"from_hours_late\":24,\"penalty_per_hour\":2}],\"reward_expiry_days\":365,\"reward_expiring_soon_days\":30,\"settlement_fee_percent\":5,\"settlement_due_days\":30}"]}'
+*/
+
+// Used until the platform stores its own config
+var defaultPlatformConfig = models.PlatformConfig{
+	MaxDeliveryAttempts: 3,
+	ReturnRewardPercent: 50,
+	SlaPenaltyTiers: []models.SlaPenaltyTier{
+		{FromHoursLate: 0, PenaltyPerHour: 1},
+		{FromHoursLate: 24, PenaltyPerHour: 2},
+	},
+	RewardExpiryDays:       365,
+	RewardExpiringSoonDays: 30,
+	SettlementFeePercent:   5,
+	SettlementDueDays:      30,
+}
+
+// Copy of the default config, a config read over it keeps the default of the
+// settings it does not have
+func newPlatformConfig() models.PlatformConfig {
+	config := defaultPlatformConfig
+	config.SlaPenaltyTiers = append([]models.SlaPenaltyTier{}, defaultPlatformConfig.SlaPenaltyTiers...)
+	return config
+}
+
+func validatePlatformConfig(config models.PlatformConfig) error {
+	var errorMessages []string
+
+	if !(config.MaxDeliveryAttempts > 0) {
+		errorMessages = append(errorMessages, "MaxDeliveryAttempts Higher than 0")
+	}
+
+	if config.ReturnRewardPercent < 0 || config.ReturnRewardPercent > 100 {
+		errorMessages = append(errorMessages, "ReturnRewardPercent between 0 and 100")
+	}
+
+	if config.RewardExpiryDays < 0 || config.RewardExpiringSoonDays < 0 {
+		errorMessages = append(errorMessages, "RewardExpiryDays and RewardExpiringSoonDays Higher Equal 0")
+	}
+
+	if config.SettlementFeePercent < 0 || config.SettlementFeePercent > 100 {
+		errorMessages = append(errorMessages, "SettlementFeePercent between 0 and 100")
+	}
+
+	if config.SettlementDueDays < 0 {
+		errorMessages = append(errorMessages, "SettlementDueDays Higher Equal 0")
+	}
+
+	// Tiers are ordered by the hour they start at
+	for i, tier := range config.SlaPenaltyTiers {
+		if tier.FromHoursLate < 0 || tier.PenaltyPerHour < 0 {
+			errorMessages = append(errorMessages, fmt.Sprint("SlaPenaltyTiers[", i, "] FromHoursLate and PenaltyPerHour Higher Equal 0"))
+		}
+		if i > 0 && tier.FromHoursLate <= config.SlaPenaltyTiers[i-1].FromHoursLate {
+			errorMessages = append(errorMessages, fmt.Sprint("SlaPenaltyTiers[", i, "] most start after the previous tier"))
+		}
+	}
+
+	if len(errorMessages) > 0 {
+		return errors.New(strings.Join(errorMessages, "\n"))
+	}
+
+	return nil
+}
+
+func (s *AuctionSmartContract) getPlatformConfig(stub shim.ChaincodeStubInterface) (models.PlatformConfig, error) {
+	configKey, err := s.CreateCompositeKey(stub, EntityPlatformConfig, []string{"CURRENT"})
+	if err != nil {
+		return models.PlatformConfig{}, err
+	}
+
+	configJSON, err := stub.GetState(configKey)
+	if err != nil {
+		return models.PlatformConfig{}, err
+	}
+	if configJSON == nil {
+		return newPlatformConfig(), nil
+	}
+
+	// Settings stored before they existed keep their default
+	config := newPlatformConfig()
+	err = json.Unmarshal(configJSON, &config)
+	return config, err
+}
+
+func (s *AuctionSmartContract) GetPlatformConfig(stub shim.ChaincodeStubInterface) pb.Response {
+	config, err := s.getPlatformConfig(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(configJSON)
+}
+
+// Only the platform (participant 0) changes the config
+func (s *AuctionSmartContract) SetPlatformConfig(stub shim.ChaincodeStubInterface, participantID int, config models.PlatformConfig) pb.Response {
+	fmt.Println("SetPlatformConfig Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can change the platform config"))
+	}
+
+	err := validatePlatformConfig(config)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	config.UpdatedBy = participantID
+	config.UpdatedAt = currentTime
+
+	configKey, err := s.CreateCompositeKey(stub, EntityPlatformConfig, []string{"CURRENT"})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataConfig, err := json.Marshal(config)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, configKey, dataConfig)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataConfig)
+}
+
+// ** -----------------------------------------------------
+// ** PLATFORM CONFIG
+// ** -> END
+// ** -----------------------------------------------------
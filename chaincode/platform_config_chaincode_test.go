@@ -0,0 +1,82 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPlatformConfig(t *testing.T) {
+	config := newPlatformConfig()
+	require.Equal(t, defaultPlatformConfig, config)
+
+	// The tiers of the copy are not those of the default config
+	config.SlaPenaltyTiers[0].PenaltyPerHour = 5
+	require.Equal(t, 1, defaultPlatformConfig.SlaPenaltyTiers[0].PenaltyPerHour)
+}
+
+func TestValidatePlatformConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(config *models.PlatformConfig)
+		valid  bool
+	}{
+		{"default config", func(config *models.PlatformConfig) {}, true},
+		{"rewards never expire", func(config *models.PlatformConfig) { config.RewardExpiryDays = 0 }, true},
+		{"no delivery attempts", func(config *models.PlatformConfig) { config.MaxDeliveryAttempts = 0 }, false},
+		{"return reward above 100%", func(config *models.PlatformConfig) { config.ReturnRewardPercent = 101 }, false},
+		{"negative reward expiry", func(config *models.PlatformConfig) { config.RewardExpiryDays = -1 }, false},
+		{"settlement fee above 100%", func(config *models.PlatformConfig) { config.SettlementFeePercent = 101 }, false},
+		{"negative settlement due days", func(config *models.PlatformConfig) { config.SettlementDueDays = -1 }, false},
+		{"negative penalty", func(config *models.PlatformConfig) { config.SlaPenaltyTiers[1].PenaltyPerHour = -1 }, false},
+		{"tiers out of order", func(config *models.PlatformConfig) { config.SlaPenaltyTiers[1].FromHoursLate = 0 }, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := newPlatformConfig()
+			test.change(&config)
+			require.Equal(t, test.valid, validatePlatformConfig(config) == nil)
+		})
+	}
+}
+
+func TestPlatformConfigDefaults(t *testing.T) {
+	c := newTestContract(t)
+
+	var config models.PlatformConfig
+	c.ok(&config, "GetPlatformConfig")
+	require.Equal(t, defaultPlatformConfig, config)
+
+	// The config read while none is stored does not share the default tiers
+	config, err := new(AuctionSmartContract).getPlatformConfig(c.stub)
+	require.NoError(t, err)
+	config.SlaPenaltyTiers[0].PenaltyPerHour = 5
+	require.Equal(t, 1, defaultPlatformConfig.SlaPenaltyTiers[0].PenaltyPerHour)
+
+	c.as(2).fails(http.StatusForbidden, "SetPlatformConfig", "2", `{"max_delivery_attempts": 5}`)
+	c.as(PlatformWalletId).fails(http.StatusBadRequest, "SetPlatformConfig", "0", `{"settlement_fee_percent": 200}`)
+
+	// The settings left out keep their default
+	c.ok(nil, "SetPlatformConfig", "0", `{"max_delivery_attempts": 5}`)
+	c.ok(&config, "GetPlatformConfig")
+	require.Equal(t, 5, config.MaxDeliveryAttempts)
+	require.Equal(t, defaultPlatformConfig.SettlementFeePercent, config.SettlementFeePercent)
+	require.Equal(t, defaultPlatformConfig.SlaPenaltyTiers, config.SlaPenaltyTiers)
+	require.Equal(t, PlatformWalletId, config.UpdatedBy)
+
+	// A config stored before the settlement settings existed keeps their default
+	key, err := c.stub.CreateCompositeKey(string(EntityPlatformConfig), []string{"CURRENT"})
+	require.NoError(t, err)
+	c.stub.State[key] = []byte(`{"max_delivery_attempts": 2, "return_reward_percent": 40, "sla_penalty_tiers": [{"from_hours_late": 0, "penalty_per_hour": 3}]}`)
+
+	c.ok(&config, "GetPlatformConfig")
+	require.Equal(t, 2, config.MaxDeliveryAttempts)
+	require.Equal(t, []models.SlaPenaltyTier{{FromHoursLate: 0, PenaltyPerHour: 3}}, config.SlaPenaltyTiers)
+	require.Equal(t, defaultPlatformConfig.SettlementFeePercent, config.SettlementFeePercent)
+	require.Equal(t, defaultPlatformConfig.SettlementDueDays, config.SettlementDueDays)
+	require.Equal(t, defaultPlatformConfig.RewardExpiryDays, config.RewardExpiryDays)
+	require.Len(t, defaultPlatformConfig.SlaPenaltyTiers, 2)
+}
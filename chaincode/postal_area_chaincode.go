@@ -0,0 +1,344 @@
+package micolec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** POSTAL AREAS (registry and route queries)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["RegisterPostalAreas", "0", "[{\"code\":\"1000\",\"name\":\"Lisboa Centro\",\"municipality\":\"Lisboa\",\"region\":\"Lisboa\",\"adjacent\":[\"1100\"]},{\"code\":\"1100\",\"name\":\"Lisboa Baixa\",\"municipality\":\"Lisboa\",\"region\":\"Lisboa\"}]"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["ReadParcelsByRoute", "1000", "4000", "true"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["ReadParcelsByRegion", "Lisboa", ""]}'
+*/
+
+// Postal area codes are compared upper case and without spaces
+func normalizePostalArea(code string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(code), ""))
+}
+
+// Every registered postal area by code
+func getPostalAreas(stub shim.ChaincodeStubInterface) (map[string]models.PostalArea, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityPostalArea), []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	postalAreas := map[string]models.PostalArea{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var postalArea models.PostalArea
+		err = json.Unmarshal(response.Value, &postalArea)
+		if err != nil {
+			return nil, err
+		}
+
+		postalAreas[postalArea.Code] = postalArea
+	}
+
+	return postalAreas, nil
+}
+
+// Normalises the postal areas of the parcel and, once the registry has areas,
+// checks both of them are registered
+func validateParcelPostalAreas(stub shim.ChaincodeStubInterface, parcel *models.Parcel) error {
+	postalAreas, err := getPostalAreas(stub)
+	if err != nil {
+		return err
+	}
+
+	return checkParcelPostalAreas(parcel, postalAreas)
+}
+
+// Same as validateParcelPostalAreas with the registry already read, for batches
+func checkParcelPostalAreas(parcel *models.Parcel, postalAreas map[string]models.PostalArea) error {
+	parcel.PickupPostalArea = normalizePostalArea(parcel.PickupPostalArea)
+	parcel.DeliveryPostalArea = normalizePostalArea(parcel.DeliveryPostalArea)
+
+	if len(postalAreas) == 0 {
+		return nil
+	}
+
+	var errorMessages []string
+	if _, ok := postalAreas[parcel.PickupPostalArea]; !ok {
+		errorMessages = append(errorMessages, fmt.Sprint("PickupPostalArea '", parcel.PickupPostalArea, "' is not a registered postal area"))
+	}
+	if _, ok := postalAreas[parcel.DeliveryPostalArea]; !ok {
+		errorMessages = append(errorMessages, fmt.Sprint("DeliveryPostalArea '", parcel.DeliveryPostalArea, "' is not a registered postal area"))
+	}
+
+	if len(errorMessages) > 0 {
+		return errors.New(strings.Join(errorMessages, "\n"))
+	}
+
+	return nil
+}
+
+func containsPostalArea(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates or replaces postal areas. Only the platform (participant 0) manages the
+// registry. Adjacent areas must be registered, and the adjacency is added on both sides.
+func (s *AuctionSmartContract) RegisterPostalAreas(stub shim.ChaincodeStubInterface, participantID int, newPostalAreas []models.PostalArea) pb.Response {
+	fmt.Println("RegisterPostalAreas Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can manage the postal areas"))
+	}
+
+	if len(newPostalAreas) == 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "No postal area to register"))
+	}
+
+	postalAreas, err := getPostalAreas(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// The registry is updated in memory first, the ledger does not return the writes of this transaction
+	changed := map[string]bool{}
+	var errorMessages []string
+	for _, postalArea := range newPostalAreas {
+		postalArea.Code = normalizePostalArea(postalArea.Code)
+		postalArea.Name = strings.TrimSpace(postalArea.Name)
+		postalArea.Municipality = strings.TrimSpace(postalArea.Municipality)
+		postalArea.Region = strings.TrimSpace(postalArea.Region)
+
+		if !(len(postalArea.Code) >= 3) {
+			errorMessages = append(errorMessages, fmt.Sprint("Postal area '", postalArea.Code, "' Code Min. Length 3"))
+		}
+		if postalArea.Municipality == "" || postalArea.Region == "" {
+			errorMessages = append(errorMessages, fmt.Sprint("Postal area '", postalArea.Code, "' needs a Municipality and a Region"))
+		}
+
+		adjacent := []string{}
+		for _, code := range postalArea.Adjacent {
+			code = normalizePostalArea(code)
+			if code != postalArea.Code && !containsPostalArea(adjacent, code) {
+				adjacent = append(adjacent, code)
+			}
+		}
+		postalArea.Adjacent = adjacent
+		postalArea.UpdatedBy = participantID
+		postalArea.UpdatedAt = currentTime
+
+		postalAreas[postalArea.Code] = postalArea
+		changed[postalArea.Code] = true
+	}
+
+	var changedCodes []string
+	for code := range changed {
+		changedCodes = append(changedCodes, code)
+	}
+	sort.Strings(changedCodes)
+
+	for _, code := range changedCodes {
+		for _, adjacentCode := range postalAreas[code].Adjacent {
+			adjacentArea, ok := postalAreas[adjacentCode]
+			if !ok {
+				errorMessages = append(errorMessages, fmt.Sprint("Postal area '", code, "' is adjacent to the unknown postal area '", adjacentCode, "'"))
+				continue
+			}
+			if !containsPostalArea(adjacentArea.Adjacent, code) {
+				adjacentArea.Adjacent = append(adjacentArea.Adjacent, code)
+				adjacentArea.UpdatedBy = participantID
+				adjacentArea.UpdatedAt = currentTime
+				postalAreas[adjacentCode] = adjacentArea
+				changed[adjacentCode] = true
+			}
+		}
+	}
+
+	if len(errorMessages) > 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, strings.Join(errorMessages, "\n")))
+	}
+
+	changedCodes = nil
+	for code := range changed {
+		changedCodes = append(changedCodes, code)
+	}
+	sort.Strings(changedCodes)
+
+	var storedPostalAreas []models.PostalArea
+	for _, code := range changedCodes {
+		postalArea := postalAreas[code]
+		sort.Strings(postalArea.Adjacent)
+
+		postalAreaKey, err := s.CreateCompositeKey(stub, EntityPostalArea, []string{postalArea.Code})
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		dataPostalArea, err := json.Marshal(postalArea)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		_, err = s.UpsertEntityRecord(stub, postalAreaKey, dataPostalArea)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		storedPostalAreas = append(storedPostalAreas, postalArea)
+	}
+
+	storedPostalAreasJSON, err := json.Marshal(storedPostalAreas)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(storedPostalAreasJSON)
+}
+
+// Registered postal areas, all of them or the ones of a region
+func (s *AuctionSmartContract) GetPostalAreas(stub shim.ChaincodeStubInterface, region string) pb.Response {
+	postalAreas, err := getPostalAreas(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var codes []string
+	for code := range postalAreas {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	response := []models.PostalArea{}
+	for _, code := range codes {
+		if region == "" || strings.EqualFold(postalAreas[code].Region, region) {
+			response = append(response, postalAreas[code])
+		}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// Every parcel accepted by the filter
+func readParcelsWhere(stub shim.ChaincodeStubInterface, matches func(models.Parcel) bool) ([]models.Parcel, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityParcel), []string{})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	parcels := []models.Parcel{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var parcel models.Parcel
+		err = json.Unmarshal(response.Value, &parcel)
+		if err != nil {
+			return nil, err
+		}
+
+		if matches(parcel) {
+			parcels = append(parcels, parcel)
+		}
+	}
+
+	return parcels, nil
+}
+
+// Parcels going from the pickup area to the delivery area. With includeAdjacent
+// the areas next to them are accepted too.
+func (s *AuctionSmartContract) ReadParcelsByRoute(stub shim.ChaincodeStubInterface, pickupArea string, deliveryArea string, includeAdjacent bool) pb.Response {
+	fmt.Println("ReadParcelsByRoute Invoke")
+	pickupAreas := []string{normalizePostalArea(pickupArea)}
+	deliveryAreas := []string{normalizePostalArea(deliveryArea)}
+
+	if includeAdjacent {
+		postalAreas, err := getPostalAreas(stub)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		pickupAreas = append(pickupAreas, postalAreas[pickupAreas[0]].Adjacent...)
+		deliveryAreas = append(deliveryAreas, postalAreas[deliveryAreas[0]].Adjacent...)
+	}
+
+	parcels, err := readParcelsWhere(stub, func(parcel models.Parcel) bool {
+		return containsPostalArea(pickupAreas, normalizePostalArea(parcel.PickupPostalArea)) &&
+			containsPostalArea(deliveryAreas, normalizePostalArea(parcel.DeliveryPostalArea))
+	})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	parcelsJSON, err := json.Marshal(parcels)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(parcelsJSON)
+}
+
+// Parcels picked up in a region and delivered in another. An empty region
+// matches any region. Parcels with unregistered postal areas are left out.
+func (s *AuctionSmartContract) ReadParcelsByRegion(stub shim.ChaincodeStubInterface, pickupRegion string, deliveryRegion string) pb.Response {
+	fmt.Println("ReadParcelsByRegion Invoke")
+	postalAreas, err := getPostalAreas(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	inRegion := func(code string, region string) bool {
+		postalArea, ok := postalAreas[normalizePostalArea(code)]
+		if !ok {
+			return false
+		}
+		return region == "" || strings.EqualFold(postalArea.Region, region)
+	}
+
+	parcels, err := readParcelsWhere(stub, func(parcel models.Parcel) bool {
+		return inRegion(parcel.PickupPostalArea, pickupRegion) && inRegion(parcel.DeliveryPostalArea, deliveryRegion)
+	})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	parcelsJSON, err := json.Marshal(parcels)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(parcelsJSON)
+}
+
+// ** -----------------------------------------------------
+// ** POSTAL AREAS (registry and route queries)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,168 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Parcel 4 was added before the registry, from the unregistered area 9000. Parcels
+// 1 to 3 go from Lisboa (1000 next to 1100) to Porto (4000 next to 4100).
+func newPostalAreaContract(t *testing.T) *testContract {
+	c := newTestContract(t).parcel(4, map[string]interface{}{"pickup_postal_area": "9000", "delivery_postal_area": "4000"})
+	c.ok(nil, "RegisterPostalAreas", "0", toJSON(t, []models.PostalArea{
+		{Code: "1000", Name: "Lisboa Centro", Municipality: "Lisboa", Region: "Lisboa", Adjacent: []string{"1100"}},
+		{Code: "1100", Name: "Lisboa Baixa", Municipality: "Lisboa", Region: "Lisboa"},
+		{Code: "4000", Name: "Porto Centro", Municipality: "Porto", Region: "Porto", Adjacent: []string{"41 00"}},
+		{Code: "4100", Name: "Boavista", Municipality: "Porto", Region: "Porto"},
+	}))
+
+	c.parcel(1, map[string]interface{}{"pickup_postal_area": "1000", "delivery_postal_area": "4000"})
+	c.parcel(2, map[string]interface{}{"pickup_postal_area": "1100", "delivery_postal_area": "4100"})
+	c.parcel(3, map[string]interface{}{"pickup_postal_area": " 11 00", "delivery_postal_area": "4000"})
+	return c
+}
+
+func TestNormalizePostalArea(t *testing.T) {
+	tests := []struct {
+		code       string
+		normalized string
+	}{
+		{"1000", "1000"},
+		{" 1000-001 ", "1000-001"},
+		{"sw1a 1aa", "SW1A1AA"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.code, func(t *testing.T) {
+			require.Equal(t, test.normalized, normalizePostalArea(test.code))
+		})
+	}
+}
+
+func TestRegisterPostalAreas(t *testing.T) {
+	tests := []struct {
+		name          string
+		participantID int
+		postalAreas   []models.PostalArea
+		code          int
+	}{
+		{"by another participant", 2, []models.PostalArea{{Code: "2000", Municipality: "Coimbra", Region: "Centro"}}, http.StatusForbidden},
+		{"nothing to register", PlatformWalletId, []models.PostalArea{}, http.StatusBadRequest},
+		{"code too short", PlatformWalletId, []models.PostalArea{{Code: "20", Municipality: "Coimbra", Region: "Centro"}}, http.StatusBadRequest},
+		{"without region", PlatformWalletId, []models.PostalArea{{Code: "2000", Municipality: "Coimbra"}}, http.StatusBadRequest},
+		{"next to an unknown area", PlatformWalletId, []models.PostalArea{{Code: "2000", Municipality: "Coimbra", Region: "Centro", Adjacent: []string{"3000"}}}, http.StatusBadRequest},
+		{"next to a registered area", PlatformWalletId, []models.PostalArea{{Code: "2000", Municipality: "Coimbra", Region: "Centro", Adjacent: []string{"1100", "2000"}}}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newPostalAreaContract(t)
+
+			var postalAreas []models.PostalArea
+			if test.code != 0 {
+				c.as(test.participantID).fails(test.code, "RegisterPostalAreas", "0", toJSON(t, test.postalAreas))
+				c.ok(&postalAreas, "GetPostalAreas")
+				require.Len(t, postalAreas, 4)
+				return
+			}
+
+			c.as(test.participantID).ok(nil, "RegisterPostalAreas", "0", toJSON(t, test.postalAreas))
+
+			// The adjacency is kept on both sides and never to the area itself
+			var postalArea models.PostalArea
+			require.True(t, c.entity(EntityPostalArea, &postalArea, "2000"))
+			require.Equal(t, []string{"1100"}, postalArea.Adjacent)
+			require.True(t, c.entity(EntityPostalArea, &postalArea, "1100"))
+			require.Equal(t, []string{"1000", "2000"}, postalArea.Adjacent)
+
+			c.ok(&postalAreas, "GetPostalAreas", "centro")
+			require.Len(t, postalAreas, 1)
+		})
+	}
+}
+
+func TestValidateParcelPostalAreas(t *testing.T) {
+	c := newPostalAreaContract(t)
+
+	var parcel models.Parcel
+	require.True(t, c.entity(EntityParcel, &parcel, "3"))
+	require.Equal(t, "1100", parcel.PickupPostalArea)
+
+	// Once the registry has areas the parcels are checked against it
+	message := c.fails(http.StatusBadRequest, "ParcelDeliveryParcelAdded", toJSON(t, map[string]interface{}{
+		"id":                     5,
+		"state":                  "Pending",
+		"added_to_platform":      c.ledger.now,
+		"required_delivery_date": c.ledger.now.Add(48 * time.Hour),
+		"pickup_postal_area":     "1000",
+		"delivery_postal_area":   "9000",
+		"weight":                 2,
+		"volume":                 1,
+		"logistic_operator_id":   testOperatorID,
+	}))
+	require.Equal(t, "DeliveryPostalArea '9000' is not a registered postal area", message)
+	require.False(t, c.entity(EntityParcel, &parcel, "5"))
+}
+
+func TestReadParcelsByRoute(t *testing.T) {
+	tests := []struct {
+		name            string
+		pickupArea      string
+		deliveryArea    string
+		includeAdjacent string
+		parcelIDs       []int
+	}{
+		{"the route only", "1000", "4000", "false", []int{1}},
+		{"the route and its adjacent areas", "1000", "4000", "true", []int{1, 2, 3}},
+		{"codes are normalised", "11 00", "4100", "false", []int{2}},
+		{"unregistered area", "9000", "4000", "true", []int{4}},
+		{"no parcels", "4000", "1000", "true", nil},
+	}
+
+	c := newPostalAreaContract(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parcels []models.Parcel
+			c.ok(&parcels, "ReadParcelsByRoute", test.pickupArea, test.deliveryArea, test.includeAdjacent)
+
+			var parcelIDs []int
+			for _, parcel := range parcels {
+				parcelIDs = append(parcelIDs, parcel.ID)
+			}
+			require.Equal(t, test.parcelIDs, parcelIDs)
+		})
+	}
+}
+
+func TestReadParcelsByRegion(t *testing.T) {
+	tests := []struct {
+		name           string
+		pickupRegion   string
+		deliveryRegion string
+		parcelIDs      []int
+	}{
+		{"from a region to another", "Lisboa", "Porto", []int{1, 2, 3}},
+		{"any delivery region", "lisboa", "", []int{1, 2, 3}},
+		{"any region leaves out unregistered areas", "", "", []int{1, 2, 3}},
+		{"no parcels", "Porto", "", nil},
+	}
+
+	c := newPostalAreaContract(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var parcels []models.Parcel
+			c.ok(&parcels, "ReadParcelsByRegion", test.pickupRegion, test.deliveryRegion)
+
+			var parcelIDs []int
+			for _, parcel := range parcels {
+				parcelIDs = append(parcelIDs, parcel.ID)
+			}
+			require.Equal(t, test.parcelIDs, parcelIDs)
+		})
+	}
+}
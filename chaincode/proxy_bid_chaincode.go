@@ -0,0 +1,489 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** PROXY BID
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["RegisterProxyBid", "4", "1", "80.00", "15", "EUR"]}'
+*/
+
+// Smallest money undercut placed by a proxy bid, one minor unit
+const MinimumBidDecrement int64 = 1
+
+func (s *AuctionSmartContract) putProxyBid(stub shim.ChaincodeStubInterface, proxyBid models.ProxyBid) error {
+	proxyBidKey, err := s.CreateCompositeKey(stub, EntityProxyBid, []string{proxyBid.AuctionID, fmt.Sprint(proxyBid.CourierID)})
+	if err != nil {
+		return err
+	}
+
+	dataProxyBid, err := json.Marshal(proxyBid)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, proxyBidKey, dataProxyBid)
+	return err
+}
+
+func getAuctionProxyBids(stub shim.ChaincodeStubInterface, auctionID string) ([]models.ProxyBid, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityProxyBid), []string{auctionID})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	var proxyBids []models.ProxyBid
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var proxyBid models.ProxyBid
+		err = json.Unmarshal(response.Value, &proxyBid)
+		if err != nil {
+			return nil, err
+		}
+		proxyBids = append(proxyBids, proxyBid)
+	}
+
+	// Oldest proxy bids answer first
+	sort.SliceStable(proxyBids, func(i, j int) bool {
+		if proxyBids[i].CreatedAt.Equal(proxyBids[j].CreatedAt) {
+			return proxyBids[i].CourierID < proxyBids[j].CourierID
+		}
+		return proxyBids[i].CreatedAt.Before(proxyBids[j].CreatedAt)
+	})
+
+	return proxyBids, nil
+}
+
+// Best bid a proxy can reach: its floor, offering every Bitcircle it may
+func proxyLimitBid(proxyBid models.ProxyBid, usableBitcircles int) models.Bid {
+	bitcircleAmount := proxyBid.MaxBitcircleAmount
+	if bitcircleAmount > usableBitcircles {
+		bitcircleAmount = usableBitcircles
+	}
+	if bitcircleAmount < 0 {
+		bitcircleAmount = 0
+	}
+
+	return models.Bid{
+		BitcircleAmount: bitcircleAmount,
+		MoneyAmount:     proxyBid.FloorAmount,
+		Currency:        proxyBid.Currency,
+		Status:          models.BitStatusLowerBid,
+		AuctionID:       proxyBid.AuctionID,
+		CourierID:       proxyBid.CourierID,
+		IsProxyBid:      true,
+	}
+}
+
+// Minimum undercut of a bid within a limit: one minor unit less, or the same
+// money with one more Bitcircle once the floor is reached. It exists only when
+// the limit beats the bid.
+func proxyUndercut(limit models.Bid, bid models.Bid) (models.Bid, bool) {
+	undercut := limit
+	if bid.MoneyAmount-MinimumBidDecrement >= limit.MoneyAmount {
+		undercut.MoneyAmount = bid.MoneyAmount - MinimumBidDecrement
+		undercut.BitcircleAmount = 0
+		return undercut, true
+	}
+	if bid.MoneyAmount >= limit.MoneyAmount && bid.BitcircleAmount+1 <= limit.BitcircleAmount {
+		undercut.MoneyAmount = bid.MoneyAmount
+		undercut.BitcircleAmount = bid.BitcircleAmount + 1
+		return undercut, true
+	}
+	return undercut, false
+}
+
+// The automatic bids answering the winning bid (empty when the auction has no
+// bids), given the limits of the active proxies oldest first. The bidding war is
+// not replayed undercut by undercut: the best limit wins at the minimum undercut
+// of the runner-up, and every proxy outbid on the way records its last automatic
+// bid, the minimum undercut of the bid it answered, so that its floor is not
+// published. Ties go to the winning bid, then to the oldest proxy.
+func placeProxyBids(auction models.Auction, winningBid models.Bid, limits []models.Bid) []models.Bid {
+	hasWinningBid := winningBid.ID != ""
+
+	// The courier of the winning bid stands at the best of the bid and its proxy
+	var contenders []models.Bid
+	if hasWinningBid {
+		contenders = append(contenders, winningBid)
+	}
+	for _, limit := range limits {
+		if hasWinningBid && limit.CourierID == winningBid.CourierID {
+			if bidBeats(limit, contenders[0]) {
+				contenders[0] = limit
+			}
+			continue
+		}
+		contenders = append(contenders, limit)
+	}
+	if len(contenders) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(contenders, func(i, j int) bool {
+		return bidBeats(contenders[i], contenders[j])
+	})
+
+	top := contenders[0]
+	if hasWinningBid && top.CourierID == winningBid.CourierID && (len(contenders) == 1 || !bidBeats(contenders[1], winningBid)) {
+		return nil
+	}
+
+	// Alone on an auction without bids, a proxy opens at the maximum accepted
+	finalBid := top
+	if len(contenders) == 1 {
+		finalBid.MoneyAmount = auction.MaximumAcceptedLicitation
+		finalBid.BitcircleAmount = 0
+	} else if undercut, ok := proxyUndercut(top, contenders[1]); ok {
+		finalBid = undercut
+	}
+
+	var bids []models.Bid
+	position, hasPosition := winningBid, hasWinningBid
+	for i := len(contenders) - 1; i >= 1; i-- {
+		limit := contenders[i]
+		// Placed bids stand where they are, a limit tied with the winner is not reached
+		if limit.ID != "" || !bidBeats(finalBid, limit) || (hasPosition && !bidBeats(limit, position)) {
+			continue
+		}
+
+		// A proxy does not answer its own bid, and opens at the maximum accepted
+		// when there is nothing to answer
+		bid := limit
+		if !hasPosition {
+			bid.MoneyAmount = auction.MaximumAcceptedLicitation
+			bid.BitcircleAmount = 0
+		} else if position.CourierID == limit.CourierID {
+			continue
+		} else {
+			bid, _ = proxyUndercut(limit, position)
+		}
+		bids = append(bids, bid)
+		position, hasPosition = bid, true
+	}
+
+	return append(bids, finalBid)
+}
+
+// Places the automatic bids triggered by a new bid or a new proxy. The returned
+// round starts with the new bid and ends with the winning bid, and the proxies
+// whose state changed (including the new one) are returned to be stored.
+func (s *AuctionSmartContract) resolveProxyBids(stub shim.ChaincodeStubInterface, auction models.Auction, newBid *models.Bid, newProxyBid *models.ProxyBid, lowestBid models.Bid) ([]models.Bid, []models.ProxyBid, error) {
+	proxyBids, err := getAuctionProxyBids(stub, auction.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changedProxyBids := make(map[int]bool)
+	if newProxyBid != nil {
+		// A courier has one proxy per auction, registering again replaces the limits
+		replaced := false
+		for i := range proxyBids {
+			if proxyBids[i].CourierID == newProxyBid.CourierID {
+				newProxyBid.CreatedAt = proxyBids[i].CreatedAt
+				proxyBids[i] = *newProxyBid
+				changedProxyBids[i] = true
+				replaced = true
+			}
+		}
+		if !replaced {
+			proxyBids = append(proxyBids, *newProxyBid)
+			changedProxyBids[len(proxyBids)-1] = true
+		}
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var limits []models.Bid
+	var activeProxyBids []int
+	for i := range proxyBids {
+		proxyBid := &proxyBids[i]
+		if proxyBid.State != models.ProxyBidActive {
+			continue
+		}
+
+		// Couriers denied, uninvited or frozen after registering the proxy stop bidding
+		freeze, err := getWalletFreeze(stub, proxyBid.CourierID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if freeze != nil || checkCourierAuctionAccess(stub, auction, proxyBid.CourierID) != nil {
+			proxyBid.State = models.ProxyBidCancelled
+			proxyBid.UpdatedAt = currentTime
+			changedProxyBids[i] = true
+			continue
+		}
+
+		// The previous winner gets the Bitcircles reserved on the winning bid back
+		wallet, err := s.GetParticipantWallet(stub, proxyBid.CourierID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if lowestBid.ID != "" && lowestBid.CourierID == proxyBid.CourierID {
+			wallet.UsableBalance += lowestBid.BitcircleAmount
+		}
+		usableBitcircles, err := getSpendableBitcircles(stub, wallet)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		limits = append(limits, proxyLimitBid(*proxyBid, usableBitcircles))
+		activeProxyBids = append(activeProxyBids, i)
+	}
+
+	var biddingRound []models.Bid
+	winningBid := lowestBid
+	if newBid != nil {
+		biddingRound = append(biddingRound, *newBid)
+		winningBid = *newBid
+	}
+
+	for i, bid := range placeProxyBids(auction, winningBid, limits) {
+		bid.ID = fmt.Sprintf("%s-proxy-%d", stub.GetTxID(), i)
+		bid.Date = currentTime
+		biddingRound = append(biddingRound, bid)
+		winningBid = bid
+	}
+
+	// The proxies that are not winning can not undercut the winning bid anymore
+	for _, i := range activeProxyBids {
+		if winningBid.ID == "" || proxyBids[i].CourierID != winningBid.CourierID {
+			proxyBids[i].State = models.ProxyBidExhausted
+			proxyBids[i].UpdatedAt = currentTime
+			changedProxyBids[i] = true
+		}
+	}
+
+	var updatedProxyBids []models.ProxyBid
+	for i := range proxyBids {
+		if changedProxyBids[i] {
+			updatedProxyBids = append(updatedProxyBids, proxyBids[i])
+		}
+	}
+
+	return biddingRound, updatedProxyBids, nil
+}
+
+// The floor is a decimal in the currency of the auction
+func (s *AuctionSmartContract) RegisterProxyBid(stub shim.ChaincodeStubInterface, courierID int, auctionID string, floor string, currency string, maxBitcircleAmount int) pb.Response {
+	fmt.Println("RegisterProxyBid Invoke")
+	err := checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	if maxBitcircleAmount < 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The maximum bitcircle ammount most be higher or equal than 0"))
+	}
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	auctionJSON, err := s.ReadEntity(stub, auctionKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	var auction models.Auction
+	err = json.Unmarshal(auctionJSON, &auction)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if auction.Type == models.AuctionTypeClock {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Proxy bids are not accepted on clock auctions"))
+	}
+
+	if auction.State == models.AuctionScheduled {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "This auction has not started yet"))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if auction.State != models.AuctionOpen || auction.EndDate.Before(currentTime) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "This auction is already closed"))
+	}
+
+	err = checkCourierAuctionAccess(stub, auction, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	if currency != "" && currency != auction.Currency {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The auction ", auction.ID, " is in ", auction.Currency, ", bids in ", currency, " are not accepted")))
+	}
+
+	floorAmount, err := models.ParseMoney(floor, auction.Currency)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	if floorAmount < 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The floor amount most be higher or equal than 0"))
+	}
+
+	if floorAmount > auction.MaximumAcceptedLicitation {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The floor amount cannot exceed the maximum limit set for this auction"))
+	}
+
+	err = s.VerifyWalletAmount(stub, courierID, 0)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	proxyBid := models.ProxyBid{
+		AuctionID:          auctionID,
+		CourierID:          courierID,
+		FloorAmount:        floorAmount,
+		Currency:           auction.Currency,
+		MaxBitcircleAmount: maxBitcircleAmount,
+		State:              models.ProxyBidActive,
+		CreatedAt:          currentTime,
+		UpdatedAt:          currentTime,
+	}
+
+	lowestBid, lowestBidKey, err := getLowestBidForAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	biddingRound, changedProxyBids, err := s.resolveProxyBids(stub, auction, nil, &proxyBid, lowestBid)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	for _, changedProxyBid := range changedProxyBids {
+		if changedProxyBid.CourierID == courierID {
+			proxyBid = changedProxyBid
+		}
+	}
+
+	err = s.storeBiddingRound(stub, lowestBid, lowestBidKey, biddingRound, changedProxyBids)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	var response struct {
+		ProxyBid models.ProxyBid `json:"proxy_bid"`
+		Bids     []models.Bid    `json:"bids"`
+	}
+	response.ProxyBid = proxyBid
+	response.Bids = biddingRound
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(responseJSON)
+}
+
+func (s *AuctionSmartContract) CancelProxyBid(stub shim.ChaincodeStubInterface, courierID int, auctionID string) pb.Response {
+	fmt.Println("CancelProxyBid Invoke")
+	err := checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	proxyBidKey, err := s.CreateCompositeKey(stub, EntityProxyBid, []string{auctionID, fmt.Sprint(courierID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	proxyBidJSON, err := s.ReadEntity(stub, proxyBidKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	var proxyBid models.ProxyBid
+	err = json.Unmarshal(proxyBidJSON, &proxyBid)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	proxyBid.UpdatedAt, err = getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	proxyBid.State = models.ProxyBidCancelled
+
+	err = s.putProxyBid(stub, proxyBid)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataProxyBid, err := json.Marshal(proxyBid)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataProxyBid)
+}
+
+// Proxy limits are private, they are only listed for the courier that owns them
+func (s *AuctionSmartContract) GetCourierProxyBids(stub shim.ChaincodeStubInterface, courierID int) pb.Response {
+	err := checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityProxyBid), []string{})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var proxyBids []models.ProxyBid
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var proxyBid models.ProxyBid
+		err = json.Unmarshal(response.Value, &proxyBid)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		if proxyBid.CourierID == courierID {
+			proxyBids = append(proxyBids, proxyBid)
+		}
+	}
+
+	proxyBidsJSON, err := json.Marshal(proxyBids)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(proxyBidsJSON)
+}
+
+// ** -----------------------------------------------------
+// ** PROXY BID
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,200 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Courier, money and Bitcircles of a bid
+type testProxyBid struct {
+	courierID       int
+	moneyAmount     int64
+	bitcircleAmount int
+}
+
+func testProxyLimit(courierID int, floorAmount int64, maxBitcircleAmount int) models.Bid {
+	return proxyLimitBid(models.ProxyBid{AuctionID: "A1", CourierID: courierID, FloorAmount: floorAmount, Currency: "EUR", MaxBitcircleAmount: maxBitcircleAmount}, maxBitcircleAmount)
+}
+
+func testPlacedBid(courierID int, moneyAmount int64, bitcircleAmount int) models.Bid {
+	return models.Bid{ID: "placed", AuctionID: "A1", CourierID: courierID, MoneyAmount: moneyAmount, BitcircleAmount: bitcircleAmount, Currency: "EUR"}
+}
+
+func TestProxyLimitBid(t *testing.T) {
+	proxyBid := models.ProxyBid{CourierID: 3, FloorAmount: 2000, MaxBitcircleAmount: 5}
+
+	tests := []struct {
+		name   string
+		usable int
+		limit  int
+	}{
+		{"every Bitcircle of the proxy", 10, 5},
+		{"only the usable Bitcircles", 3, 3},
+		{"no usable Bitcircles", -2, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limit := proxyLimitBid(proxyBid, test.usable)
+			require.Equal(t, int64(2000), limit.MoneyAmount)
+			require.Equal(t, test.limit, limit.BitcircleAmount)
+			require.True(t, limit.IsProxyBid)
+		})
+	}
+}
+
+func TestProxyUndercut(t *testing.T) {
+	limit := testProxyLimit(3, 9000, 5)
+
+	tests := []struct {
+		name     string
+		bid      models.Bid
+		undercut testProxyBid
+		ok       bool
+	}{
+		{"one minor unit less", testPlacedBid(4, 9500, 3), testProxyBid{3, 9499, 0}, true},
+		{"down to the floor", testPlacedBid(4, 9001, 0), testProxyBid{3, 9000, 0}, true},
+		{"one more Bitcircle at the floor", testPlacedBid(4, 9000, 2), testProxyBid{3, 9000, 3}, true},
+		{"no Bitcircle left at the floor", testPlacedBid(4, 9000, 5), testProxyBid{}, false},
+		{"below the floor", testPlacedBid(4, 8999, 0), testProxyBid{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			undercut, ok := proxyUndercut(limit, test.bid)
+			require.Equal(t, test.ok, ok)
+			if ok {
+				require.Equal(t, test.undercut, testProxyBid{undercut.CourierID, undercut.MoneyAmount, undercut.BitcircleAmount})
+			}
+		})
+	}
+}
+
+func TestPlaceProxyBids(t *testing.T) {
+	auction := models.Auction{ID: "A1", MaximumAcceptedLicitation: 50000, Currency: "EUR"}
+
+	tests := []struct {
+		name       string
+		winningBid models.Bid
+		limits     []models.Bid
+		bids       []testProxyBid
+	}{
+		{
+			"alone on an auction without bids opens at the maximum",
+			models.Bid{},
+			[]models.Bid{testProxyLimit(3, 20000, 5)},
+			[]testProxyBid{{3, 50000, 0}},
+		},
+		{
+			"the best floor wins at the minimum undercut of the runner-up",
+			models.Bid{},
+			[]models.Bid{testProxyLimit(3, 20000, 5), testProxyLimit(4, 10000, 2)},
+			[]testProxyBid{{3, 50000, 0}, {4, 19999, 0}},
+		},
+		{
+			"the spread does not bound the bidding war",
+			testPlacedBid(2, 50000, 0),
+			[]models.Bid{testProxyLimit(3, 100, 0), testProxyLimit(4, 200, 0)},
+			[]testProxyBid{{4, 49999, 0}, {3, 199, 0}},
+		},
+		{
+			"a proxy answers a placed bid",
+			testPlacedBid(2, 30000, 0),
+			[]models.Bid{testProxyLimit(3, 20000, 5)},
+			[]testProxyBid{{3, 29999, 0}},
+		},
+		{
+			"a placed bid below every floor stands",
+			testPlacedBid(2, 15000, 0),
+			[]models.Bid{testProxyLimit(3, 20000, 5)},
+			nil,
+		},
+		{
+			"the same floor is won with more Bitcircles",
+			models.Bid{},
+			[]models.Bid{testProxyLimit(4, 10000, 2), testProxyLimit(2, 10000, 9)},
+			[]testProxyBid{{4, 50000, 0}, {2, 10000, 3}},
+		},
+		{
+			"a tie goes to the oldest proxy",
+			models.Bid{},
+			[]models.Bid{testProxyLimit(3, 10000, 2), testProxyLimit(4, 10000, 2)},
+			[]testProxyBid{{3, 10000, 2}},
+		},
+		{
+			"the proxy of the winning courier defends its bid",
+			testPlacedBid(3, 30000, 0),
+			[]models.Bid{testProxyLimit(3, 20000, 0), testProxyLimit(4, 25000, 0)},
+			[]testProxyBid{{4, 29999, 0}, {3, 24999, 0}},
+		},
+		{
+			"the winning bid holds against a weaker proxy",
+			testPlacedBid(3, 30000, 0),
+			[]models.Bid{testProxyLimit(4, 35000, 0)},
+			nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var bids []testProxyBid
+			for _, bid := range placeProxyBids(auction, test.winningBid, test.limits) {
+				bids = append(bids, testProxyBid{bid.CourierID, bid.MoneyAmount, bid.BitcircleAmount})
+			}
+			require.Equal(t, test.bids, bids)
+		})
+	}
+}
+
+func TestRegisterProxyBid(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100, 4: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, map[string]interface{}{"maximum_accepted_licitation": 500000})
+
+	var registered struct {
+		ProxyBid models.ProxyBid `json:"proxy_bid"`
+		Bids     []models.Bid    `json:"bids"`
+	}
+	c.as(3).ok(&registered, "RegisterProxyBid", "3", "A1", "200.00", "5")
+	require.Equal(t, models.ProxyBidActive, registered.ProxyBid.State)
+	require.Len(t, registered.Bids, 1)
+	require.Equal(t, int64(500000), registered.Bids[0].MoneyAmount)
+	openingBidID := registered.Bids[0].ID
+
+	// Only a courier registers its own proxy
+	c.as(4).fails(http.StatusForbidden, "RegisterProxyBid", "3", "A1", "100.00", "2")
+
+	// The outbid proxy does not publish its floor: its opening bid stands outbid
+	c.ok(&registered, "RegisterProxyBid", "4", "A1", "100.00", "2")
+	require.Len(t, registered.Bids, 1)
+	require.Equal(t, models.BitStatusLowerBid, registered.Bids[0].Status)
+	require.Equal(t, 4, registered.Bids[0].CourierID)
+	require.Equal(t, int64(19999), registered.Bids[0].MoneyAmount)
+	var openingBid models.Bid
+	require.True(t, c.entity(EntityBid, &openingBid, openingBidID, "A1"))
+	require.Equal(t, models.BitStatusOutBidded, openingBid.Status)
+
+	// A manual bid above the automatic one is rejected
+	c.fails(http.StatusBadRequest, "ParcelDeliveryBidingRequest", "b1", "A1", "250.00", "0", "3")
+
+	// Below both floors the proxies are exhausted
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "A1", "50.00", "0", "3")
+
+	var proxyBids []models.ProxyBid
+	c.as(4).ok(&proxyBids, "GetCourierProxyBids", "4")
+	require.Len(t, proxyBids, 1)
+	require.Equal(t, models.ProxyBidExhausted, proxyBids[0].State)
+
+	// Only a courier cancels its own proxy
+	c.as(4).fails(http.StatusForbidden, "CancelProxyBid", "3", "A1")
+	c.as(3).ok(nil, "CancelProxyBid", "3", "A1")
+	c.ok(&proxyBids, "GetCourierProxyBids", "3")
+	require.Len(t, proxyBids, 1)
+	require.Equal(t, models.ProxyBidCancelled, proxyBids[0].State)
+
+	// Proxy limits are only shown to their courier
+	c.as(4).fails(http.StatusForbidden, "GetCourierProxyBids", "3")
+	c.anonymous().fails(http.StatusForbidden, "GetCourierProxyBids", "4")
+}
@@ -0,0 +1,562 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** RELAY DELIVERIES (route legs, handovers and chain of custody)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["SplitParcelRoute", "2", "12", "[{\"to_postal_area\":\"3000\",\"bitcircle_reward\":4},{\"to_postal_area\":\"4000\",\"bitcircle_reward\":6}]"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ParcelDeliveryAuctionStart", "[{\"auction_id\":\"A7\",\"parcel_id\":12,\"leg\":1}]", "{\"id\":\"A7\",\"start_date\":\"2022-05-09T10:00:00Z\",\"end_date\":\"2022-05-10T10:00:00Z\",\"maximum_accepted_licitation\":100,\"participant_id\":2}"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["SignHandover", "4", "12", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetParcelChainOfCustody", "12"]}'
+*/
+
+func (s *AuctionSmartContract) readParcelLeg(stub shim.ChaincodeStubInterface, parcelID int, legNumber int) (models.ParcelLeg, string, error) {
+	var leg models.ParcelLeg
+
+	legKey, err := s.CreateCompositeKey(stub, EntityParcelLeg, []string{fmt.Sprint(parcelID), fmt.Sprintf("%03d", legNumber)})
+	if err != nil {
+		return leg, "", err
+	}
+
+	legJSON, err := s.ReadEntity(stub, legKey)
+	if err != nil {
+		return leg, legKey, fmt.Errorf("The parcel with id %d has no leg %d", parcelID, legNumber)
+	}
+
+	err = json.Unmarshal(legJSON, &leg)
+	return leg, legKey, err
+}
+
+func (s *AuctionSmartContract) putParcelLeg(stub shim.ChaincodeStubInterface, legKey string, leg models.ParcelLeg) ([]byte, error) {
+	dataLeg, err := json.Marshal(leg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, legKey, dataLeg)
+	return dataLeg, err
+}
+
+// Legs of a relay parcel, in route order
+func getParcelLegs(stub shim.ChaincodeStubInterface, parcelID int) ([]models.ParcelLeg, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityParcelLeg), []string{fmt.Sprint(parcelID)})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	legs := []models.ParcelLeg{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var leg models.ParcelLeg
+		err = json.Unmarshal(response.Value, &leg)
+		if err != nil {
+			return nil, err
+		}
+
+		legs = append(legs, leg)
+	}
+
+	return legs, nil
+}
+
+// Reward of the courier currently carrying the parcel: the whole reward, or the
+// reward of the current leg on relay parcels
+func (s *AuctionSmartContract) getCourierReward(stub shim.ChaincodeStubInterface, parcel models.Parcel) (int, error) {
+	if parcel.RelayLegs == 0 {
+		return parcel.BitcircleReward, nil
+	}
+
+	leg, _, err := s.readParcelLeg(stub, parcel.ID, parcel.CurrentLeg)
+	if err != nil {
+		return 0, err
+	}
+	return leg.BitcircleReward, nil
+}
+
+// Puts a leg of the parcel on auction. The parcel itself follows its current leg.
+func (s *AuctionSmartContract) startParcelLegAuction(stub shim.ChaincodeStubInterface, parcel *models.Parcel, legNumber int, auctionID string) error {
+	if parcel.RelayLegs == 0 {
+		return fmt.Errorf("The route of the parcel with id %d is not split in legs", parcel.ID)
+	}
+	if legNumber == 0 {
+		return fmt.Errorf("The route of the parcel with id %d is split in legs, each leg is auctioned on its own", parcel.ID)
+	}
+
+	leg, legKey, err := s.readParcelLeg(stub, parcel.ID, legNumber)
+	if err != nil {
+		return err
+	}
+
+	if leg.State != models.State(models.ParcelStatePending) {
+		return fmt.Errorf("The leg %d of the parcel with id %d is not on 'Pending' state.", legNumber, parcel.ID)
+	}
+
+	leg.State = models.State(models.ParcelStateAuction)
+	leg.AuctionID = auctionID
+	_, err = s.putParcelLeg(stub, legKey, leg)
+	if err != nil {
+		return err
+	}
+
+	if leg.Leg == parcel.CurrentLeg {
+		parcel.State = models.State(models.ParcelStateAuction)
+		parcel.AuctionId = auctionID
+	}
+
+	return nil
+}
+
+// Moves the leg to the state of its auction. Returns whether it is the current
+// leg of the parcel, in that case the parcel follows it.
+func (s *AuctionSmartContract) updateParcelLegState(stub shim.ChaincodeStubInterface, parcel models.Parcel, legNumber int, auctionID string, state models.Status, courierID int) (bool, error) {
+	leg, legKey, err := s.readParcelLeg(stub, parcel.ID, legNumber)
+	if err != nil {
+		return false, err
+	}
+
+	leg.State = models.State(state)
+	leg.CourierID = courierID
+	leg.AuctionID = auctionID
+	if state == models.ParcelStatePending {
+		leg.AuctionID = ""
+	}
+
+	_, err = s.putParcelLeg(stub, legKey, leg)
+	if err != nil {
+		return false, err
+	}
+
+	return leg.Leg == parcel.CurrentLeg, nil
+}
+
+// Same as parcelIsDoneForAuction, for a leg auctioned on its own
+func parcelLegIsDoneForAuction(leg models.ParcelLeg, auctionID string) bool {
+	switch leg.State {
+	case models.State(models.ParcelStateDelivered), models.State(models.ParcelStateReturnedToSender),
+		models.State(models.ParcelStatePending), models.State(models.ParcelStateAuction):
+		return true
+	}
+	return leg.AuctionID != "" && leg.AuctionID != auctionID
+}
+
+// The logistic operator splits the route of a Pending parcel through hubs. Each
+// leg ends at the ToPostalArea of the request, the last one at the delivery area,
+// and the leg rewards add up to the parcel reward. An empty list joins the route
+// back into a single leg.
+func (s *AuctionSmartContract) SplitParcelRoute(stub shim.ChaincodeStubInterface, logisticOperatorID int, parcelID int, legs []models.ParcelLeg) pb.Response {
+	fmt.Println("SplitParcelRoute Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if parcel.LogisticOperatorId != logisticOperatorID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("The parcel with id ", parcel.ID, " do not belong to current user")))
+	}
+
+	if parcel.State != models.State(models.ParcelStatePending) || parcel.AuctionId != "" {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", parcel.ID, " is not on 'Pending' state.")))
+	}
+
+	currentLegs, err := getParcelLegs(stub, parcel.ID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	for _, leg := range currentLegs {
+		if leg.State != models.State(models.ParcelStatePending) {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The leg %d of the parcel with id %d is not on 'Pending' state.", leg.Leg, parcel.ID)))
+		}
+	}
+
+	if len(legs) == 1 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "A relay route needs at least 2 legs"))
+	}
+
+	postalAreas, err := getPostalAreas(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var errorMessages []string
+	from := normalizePostalArea(parcel.PickupPostalArea)
+	totalReward := 0
+	for i := range legs {
+		legs[i].ParcelID = parcel.ID
+		legs[i].Leg = i + 1
+		legs[i].FromPostalArea = from
+		legs[i].ToPostalArea = normalizePostalArea(legs[i].ToPostalArea)
+		legs[i].State = models.State(models.ParcelStatePending)
+		legs[i].AuctionID = ""
+		legs[i].CourierID = 0
+
+		if legs[i].ToPostalArea == "" || legs[i].ToPostalArea == from {
+			errorMessages = append(errorMessages, fmt.Sprint("The leg ", legs[i].Leg, " most end on a different postal area than it starts"))
+		} else if _, ok := postalAreas[legs[i].ToPostalArea]; len(postalAreas) > 0 && !ok {
+			errorMessages = append(errorMessages, fmt.Sprint("The leg ", legs[i].Leg, " ends on '", legs[i].ToPostalArea, "' which is not a registered postal area"))
+		}
+		if legs[i].BitcircleReward < 0 {
+			errorMessages = append(errorMessages, fmt.Sprint("The reward of the leg ", legs[i].Leg, " most be Higher or equal than 0"))
+		}
+
+		totalReward = totalReward + legs[i].BitcircleReward
+		from = legs[i].ToPostalArea
+	}
+
+	if len(legs) > 0 {
+		if from != normalizePostalArea(parcel.DeliveryPostalArea) {
+			errorMessages = append(errorMessages, fmt.Sprint("The last leg most end on the DeliveryPostalArea '", parcel.DeliveryPostalArea, "'"))
+		}
+		if totalReward != parcel.BitcircleReward {
+			errorMessages = append(errorMessages, fmt.Sprint("The rewards of the legs add up to ", totalReward, " and most add up to the parcel reward ", parcel.BitcircleReward))
+		}
+	}
+
+	if len(errorMessages) > 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, strings.Join(errorMessages, "\n")))
+	}
+
+	// The route is written leg by leg, a failure fails the transaction
+	for _, leg := range legs {
+		legKey, err := s.CreateCompositeKey(stub, EntityParcelLeg, []string{fmt.Sprint(parcel.ID), fmt.Sprintf("%03d", leg.Leg)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		_, err = s.putParcelLeg(stub, legKey, leg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	// Legs of a previous split that are not on the new route
+	for _, leg := range currentLegs {
+		if leg.Leg <= len(legs) {
+			continue
+		}
+		legKey, err := s.CreateCompositeKey(stub, EntityParcelLeg, []string{fmt.Sprint(parcel.ID), fmt.Sprintf("%03d", leg.Leg)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = stub.DelState(legKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	parcel.RelayLegs = len(legs)
+	parcel.CurrentLeg = 0
+	if len(legs) > 0 {
+		parcel.CurrentLeg = 1
+	}
+
+	_, err = s.putParcel(stub, parcelKey, &parcel, logisticOperatorID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	legsJSON, err := json.Marshal(legs)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(legsJSON)
+}
+
+// The courier of the current leg and the courier of the next one sign the
+// handover at the hub with the same proof hash, in any order. Once both signed,
+// the first courier is paid the leg reward and the parcel moves to the next leg.
+func (s *AuctionSmartContract) SignHandover(stub shim.ChaincodeStubInterface, courierID int, parcelID int, proofHash string) pb.Response {
+	fmt.Println("SignHandover Invoke")
+	proofHash = strings.ToLower(strings.TrimSpace(proofHash))
+	err := validateProofHash(proofHash)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+	}
+
+	// Each courier signs its own side of the handover
+	err = checkCaller(stub, courierID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if parcel.State != models.State(models.ParcelStateDelivery) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The parcel with id %d is not on 'Delivery' state.", parcelID)))
+	}
+	if parcel.CurrentLeg == 0 || parcel.CurrentLeg >= parcel.RelayLegs {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The parcel with id %d has no handover pending", parcelID)))
+	}
+
+	currentLeg, currentLegKey, err := s.readParcelLeg(stub, parcel.ID, parcel.CurrentLeg)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	nextLeg, nextLegKey, err := s.readParcelLeg(stub, parcel.ID, parcel.CurrentLeg+1)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if nextLeg.State != models.State(models.ParcelStateDelivery) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The leg %d of the parcel with id %d was not awarded yet", nextLeg.Leg, parcelID)))
+	}
+
+	if courierID != currentLeg.CourierID && courierID != nextLeg.CourierID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprintf("The handover of the parcel with id %d is not assigned to the courier %d", parcelID, courierID)))
+	}
+
+	confirmation, exists, err := s.readDeliveryConfirmation(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The pickup of the parcel ", parcelID, " was not confirmed yet")))
+	}
+
+	handoverKey, err := s.CreateCompositeKey(stub, EntityParcelHandover, []string{fmt.Sprint(parcel.ID), fmt.Sprintf("%03d", currentLeg.Leg)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	handover := models.ParcelHandover{
+		ParcelID:      parcel.ID,
+		FromLeg:       currentLeg.Leg,
+		HubPostalArea: currentLeg.ToPostalArea,
+		FromCourierID: currentLeg.CourierID,
+		ToCourierID:   nextLeg.CourierID,
+		ProofHash:     proofHash,
+	}
+
+	handoverJSON, err := stub.GetState(handoverKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if handoverJSON != nil {
+		err = json.Unmarshal(handoverJSON, &handover)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		if handover.ProofHash != proofHash {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, "The proof hash does not match the one signed by the other courier"))
+		}
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// A courier that won both legs signs both sides at once
+	signed := false
+	if courierID == handover.FromCourierID && handover.FromSignedAt.IsZero() {
+		handover.FromSignedAt = currentTime
+		signed = true
+	}
+	if courierID == handover.ToCourierID && handover.ToSignedAt.IsZero() {
+		handover.ToSignedAt = currentTime
+		signed = true
+	}
+	if !signed {
+		return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprint("The courier ", courierID, " already signed the handover of the parcel ", parcelID)))
+	}
+
+	if !handover.FromSignedAt.IsZero() && !handover.ToSignedAt.IsZero() {
+		handover.Completed = true
+
+		// The reward comes out of the platform wallet usable balance, as on countersign.
+		// Once it is paid a failure fails the transaction.
+		err = s.checkPlatformFunds(stub, currentLeg.BitcircleReward)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusConflict, err.Error()))
+		}
+
+		if currentLeg.BitcircleReward > 0 {
+			description := fmt.Sprint("Parcel ", parcel.ID, " leg ", currentLeg.Leg, " delivery reward.")
+			err = s.postBitcircles(stub, models.LedgerReasonDeliveryReward, availableAccount(PlatformWalletId), availableAccount(currentLeg.CourierID), currentLeg.BitcircleReward, LedgerReferenceParcelLeg, ledgerReferenceID(parcel.ID, currentLeg.Leg), description)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+		}
+
+		currentLeg.State = models.State(models.ParcelStateDelivered)
+		currentLeg.DroppedOffAt = currentTime
+		currentLeg.RewardPaid = currentLeg.BitcircleReward
+		_, err = s.putParcelLeg(stub, currentLegKey, currentLeg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		nextLeg.PickedUpAt = currentTime
+		_, err = s.putParcelLeg(stub, nextLegKey, nextLeg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		parcel.CurrentLeg = nextLeg.Leg
+		parcel.AssignedCourierId = nextLeg.CourierID
+		parcel.AssignedAuctionId = nextLeg.AuctionID
+		parcel.AuctionId = nextLeg.AuctionID
+		_, err = s.putParcel(stub, parcelKey, &parcel, courierID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		confirmation.CourierID = nextLeg.CourierID
+		confirmation.AuctionID = nextLeg.AuctionID
+		_, err = s.putDeliveryConfirmation(stub, confirmation)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = s.settleAuctionIfFinished(stub, currentLeg.AuctionID, parcel.ID, courierID)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	dataHandover, err := json.Marshal(handover)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	_, err = s.UpsertEntityRecord(stub, handoverKey, dataHandover)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(dataHandover)
+}
+
+type custodyEntry struct {
+	Leg            int        `json:"leg,omitempty"`
+	CourierID      int        `json:"courier_id"`
+	FromPostalArea string     `json:"from_postal_area"`
+	ToPostalArea   string     `json:"to_postal_area"`
+	Since          time.Time  `json:"since"`
+	Until          *time.Time `json:"until,omitempty"`
+}
+
+// Couriers that held the parcel, in order, with its legs and handovers
+func (s *AuctionSmartContract) GetParcelChainOfCustody(stub shim.ChaincodeStubInterface, parcelID int) pb.Response {
+	parcel, _, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	var response struct {
+		ParcelID  int                     `json:"parcel_id"`
+		State     models.State            `json:"state"`
+		Legs      []models.ParcelLeg      `json:"legs"`
+		Handovers []models.ParcelHandover `json:"handovers"`
+		Custody   []custodyEntry          `json:"custody"`
+	}
+	response.ParcelID = parcel.ID
+	response.State = parcel.State
+	response.Handovers = []models.ParcelHandover{}
+	response.Custody = []custodyEntry{}
+
+	response.Legs, err = getParcelLegs(stub, parcel.ID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityParcelHandover), []string{fmt.Sprint(parcel.ID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var handover models.ParcelHandover
+		err = json.Unmarshal(result.Value, &handover)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		response.Handovers = append(response.Handovers, handover)
+	}
+
+	confirmation, exists, err := s.readDeliveryConfirmation(stub, parcel.ID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if parcel.RelayLegs == 0 {
+		if exists {
+			entry := custodyEntry{
+				CourierID:      confirmation.CourierID,
+				FromPostalArea: parcel.PickupPostalArea,
+				ToPostalArea:   parcel.DeliveryPostalArea,
+				Since:          confirmation.PickedUpAt,
+			}
+			if !confirmation.DeliveredAt.IsZero() {
+				entry.Until = &confirmation.DeliveredAt
+			}
+			response.Custody = append(response.Custody, entry)
+		}
+	} else {
+		for _, leg := range response.Legs {
+			if leg.PickedUpAt.IsZero() {
+				break
+			}
+			entry := custodyEntry{
+				Leg:            leg.Leg,
+				CourierID:      leg.CourierID,
+				FromPostalArea: leg.FromPostalArea,
+				ToPostalArea:   leg.ToPostalArea,
+				Since:          leg.PickedUpAt,
+			}
+			if !leg.DroppedOffAt.IsZero() {
+				droppedOffAt := leg.DroppedOffAt
+				entry.Until = &droppedOffAt
+			}
+			response.Custody = append(response.Custody, entry)
+		}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** RELAY DELIVERIES (route legs, handovers and chain of custody)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,206 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Parcel 1 split at the hub 1500: leg 1 (reward 4) awarded to courier 2 on
+// auction L1, leg 2 (reward 6) awarded to courier 3 on auction L2
+func newRelayContract(t *testing.T) *testContract {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	c.as(testOperatorID).ok(nil, "SplitParcelRoute", fmt.Sprint(testOperatorID), "1", toJSON(t, []models.ParcelLeg{
+		{ToPostalArea: "1500", BitcircleReward: 4},
+		{ToPostalArea: "2000", BitcircleReward: 6},
+	}))
+	c.as(PlatformWalletId)
+
+	for leg, auctionID := range []string{"L1", "L2"} {
+		c.ok(nil, "ParcelDeliveryAuctionStart", toJSON(t, []models.AuctionHasParcel{{AuctionID: auctionID, ParcelID: 1, Leg: leg + 1}}), toJSON(t, map[string]interface{}{
+			"id":                          auctionID,
+			"start_date":                  c.ledger.now.Add(-time.Hour),
+			"end_date":                    c.ledger.now.Add(5 * time.Hour),
+			"maximum_accepted_licitation": 10000,
+			"currency":                    "EUR",
+			"participant_id":              testOperatorID,
+		}))
+	}
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "L1", "50.00", "0", "2")
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "L2", "60.00", "0", "3")
+
+	c.after(6 * time.Hour)
+	c.ok(nil, "CloseExpiredAuctions", "L1")
+	c.ok(nil, "CloseExpiredAuctions", "L2")
+	return c
+}
+
+func TestSplitParcelRoute(t *testing.T) {
+	tests := []struct {
+		name               string
+		callerID           int
+		logisticOperatorID int
+		legs               []models.ParcelLeg
+		code               int
+	}{
+		{"through a hub", testOperatorID, testOperatorID, []models.ParcelLeg{{ToPostalArea: "15 00", BitcircleReward: 4}, {ToPostalArea: "2000", BitcircleReward: 6}}, 0},
+		{"by another operator", 8, 8, []models.ParcelLeg{{ToPostalArea: "1500", BitcircleReward: 4}, {ToPostalArea: "2000", BitcircleReward: 6}}, http.StatusForbidden},
+		{"signed by another operator", 8, testOperatorID, []models.ParcelLeg{{ToPostalArea: "1500", BitcircleReward: 4}, {ToPostalArea: "2000", BitcircleReward: 6}}, http.StatusForbidden},
+		{"a single leg", testOperatorID, testOperatorID, []models.ParcelLeg{{ToPostalArea: "2000", BitcircleReward: 10}}, http.StatusBadRequest},
+		{"rewards not adding up", testOperatorID, testOperatorID, []models.ParcelLeg{{ToPostalArea: "1500", BitcircleReward: 4}, {ToPostalArea: "2000", BitcircleReward: 4}}, http.StatusBadRequest},
+		{"not ending at the delivery area", testOperatorID, testOperatorID, []models.ParcelLeg{{ToPostalArea: "1500", BitcircleReward: 4}, {ToPostalArea: "3000", BitcircleReward: 6}}, http.StatusBadRequest},
+		{"a leg going nowhere", testOperatorID, testOperatorID, []models.ParcelLeg{{ToPostalArea: "1000", BitcircleReward: 4}, {ToPostalArea: "2000", BitcircleReward: 6}}, http.StatusBadRequest},
+		{"negative reward", testOperatorID, testOperatorID, []models.ParcelLeg{{ToPostalArea: "1500", BitcircleReward: 12}, {ToPostalArea: "2000", BitcircleReward: -2}}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).parcel(1, nil).as(test.callerID)
+
+			var parcel models.Parcel
+			if test.code != 0 {
+				c.fails(test.code, "SplitParcelRoute", fmt.Sprint(test.logisticOperatorID), "1", toJSON(t, test.legs))
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+				require.Zero(t, parcel.RelayLegs)
+				return
+			}
+
+			var legs []models.ParcelLeg
+			c.ok(&legs, "SplitParcelRoute", fmt.Sprint(test.logisticOperatorID), "1", toJSON(t, test.legs))
+			require.Len(t, legs, 2)
+			require.Equal(t, "1000", legs[0].FromPostalArea)
+			require.Equal(t, "1500", legs[0].ToPostalArea)
+			require.Equal(t, "1500", legs[1].FromPostalArea)
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.Equal(t, 2, parcel.RelayLegs)
+			require.Equal(t, 1, parcel.CurrentLeg)
+
+			// An empty route joins the legs back
+			c.ok(nil, "SplitParcelRoute", fmt.Sprint(test.logisticOperatorID), "1", "[]")
+			var joined models.Parcel
+			require.True(t, c.entity(EntityParcel, &joined, "1"))
+			require.Zero(t, joined.RelayLegs)
+			var leg models.ParcelLeg
+			require.False(t, c.entity(EntityParcelLeg, &leg, "1", "001"))
+		})
+	}
+}
+
+func TestRelayDelivery(t *testing.T) {
+	c := newRelayContract(t)
+
+	// The first courier hands the parcel over at the hub instead of delivering it
+	c.as(2).ok(nil, "ConfirmPickup", "2", "1", testProofHash("pickup"))
+	c.fails(http.StatusBadRequest, "ConfirmDelivery", "2", "1", testProofHash("delivery"))
+
+	var handover models.ParcelHandover
+	c.as(3).ok(&handover, "SignHandover", "3", "1", testProofHash("hub"))
+	require.False(t, handover.Completed)
+	require.Equal(t, 100, c.wallet(2).Balance)
+
+	c.as(2).ok(&handover, "SignHandover", "2", "1", testProofHash("hub"))
+	require.True(t, handover.Completed)
+	require.Equal(t, "1500", handover.HubPostalArea)
+	require.Equal(t, 104, c.wallet(2).Balance)
+
+	var parcel models.Parcel
+	require.True(t, c.entity(EntityParcel, &parcel, "1"))
+	require.Equal(t, 2, parcel.CurrentLeg)
+	require.Equal(t, 3, parcel.AssignedCourierId)
+	require.EqualValues(t, models.ParcelStateDelivery, parcel.State)
+
+	var auction models.Auction
+	require.True(t, c.entity(EntityAuction, &auction, "L1"))
+	require.Equal(t, models.AuctionSettled, auction.State)
+
+	// The second courier delivers the parcel and is paid the last leg
+	c.as(3).ok(nil, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+	var confirmation models.DeliveryConfirmation
+	c.as(11).ok(&confirmation, "CountersignDelivery", "11", "1")
+	require.Equal(t, 6, confirmation.RewardPaid)
+	require.Equal(t, 106, c.wallet(3).Balance)
+	require.True(t, c.entity(EntityAuction, &auction, "L2"))
+	require.Equal(t, models.AuctionSettled, auction.State)
+
+	var custody struct {
+		State     models.State            `json:"state"`
+		Handovers []models.ParcelHandover `json:"handovers"`
+		Custody   []custodyEntry          `json:"custody"`
+	}
+	c.ok(&custody, "GetParcelChainOfCustody", "1")
+	require.EqualValues(t, models.ParcelStateDelivered, custody.State)
+	require.Len(t, custody.Handovers, 1)
+
+	tests := []struct {
+		courierID      int
+		fromPostalArea string
+		toPostalArea   string
+	}{
+		{2, "1000", "1500"},
+		{3, "1500", "2000"},
+	}
+
+	require.Len(t, custody.Custody, len(tests))
+	for i, test := range tests {
+		entry := custody.Custody[i]
+		require.Equal(t, test.courierID, entry.CourierID)
+		require.Equal(t, test.fromPostalArea, entry.FromPostalArea)
+		require.Equal(t, test.toPostalArea, entry.ToPostalArea)
+		require.NotNil(t, entry.Until)
+	}
+	require.Equal(t, *custody.Custody[0].Until, custody.Custody[1].Since)
+}
+
+func TestSignHandover(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    func(c *testContract)
+		callerID  int
+		courierID string
+		proofHash string
+		code      int
+	}{
+		{"before the pickup", nil, 2, "2", testProofHash("hub"), http.StatusBadRequest},
+		{"by a courier of neither leg", func(c *testContract) {
+			c.as(2).ok(nil, "ConfirmPickup", "2", "1", testProofHash("pickup"))
+		}, 4, "4", testProofHash("hub"), http.StatusForbidden},
+		{"by the first courier for the second", func(c *testContract) {
+			c.as(2).ok(nil, "ConfirmPickup", "2", "1", testProofHash("pickup"))
+			c.ok(nil, "SignHandover", "2", "1", testProofHash("hub"))
+		}, 2, "3", testProofHash("hub"), http.StatusForbidden},
+		{"with another proof", func(c *testContract) {
+			c.as(2).ok(nil, "ConfirmPickup", "2", "1", testProofHash("pickup"))
+			c.ok(nil, "SignHandover", "2", "1", testProofHash("hub"))
+		}, 3, "3", testProofHash("other hub"), http.StatusBadRequest},
+		{"signed twice", func(c *testContract) {
+			c.as(2).ok(nil, "ConfirmPickup", "2", "1", testProofHash("pickup"))
+			c.ok(nil, "SignHandover", "2", "1", testProofHash("hub"))
+		}, 2, "2", testProofHash("hub"), http.StatusConflict},
+		{"the platform can not pay the leg", func(c *testContract) {
+			c.as(2).ok(nil, "ConfirmPickup", "2", "1", testProofHash("pickup"))
+			c.ok(nil, "SignHandover", "2", "1", testProofHash("hub"))
+			treasury := c.wallet(PlatformWalletId)
+			c.as(PlatformWalletId).ok(nil, "BurnBitcircles", fmt.Sprint(PlatformWalletId), fmt.Sprint(treasury.UsableBalance-3))
+		}, 3, "3", testProofHash("hub"), http.StatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newRelayContract(t)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			c.as(test.callerID).fails(test.code, "SignHandover", test.courierID, "1", test.proofHash)
+
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.Equal(t, 1, parcel.CurrentLeg)
+			require.Equal(t, 100, c.wallet(2).Balance)
+		})
+	}
+}
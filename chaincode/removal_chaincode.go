@@ -0,0 +1,492 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** REMOVAL (parcel cancellation and single record deletion)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["CancelParcel", "2", "12"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["DeleteParcel", "0", "12", "true"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["DeleteAuction", "0", "A7", "false"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["DeleteBid", "0", "B3", "A7"]}'
+*/
+
+// Deletes every record of the entity under the partial key
+func deleteByPartialKey(stub shim.ChaincodeStubInterface, entity Entity, attributes []string) (int, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(entity), attributes)
+	if err != nil {
+		return 0, err
+	}
+	defer iterator.Close()
+
+	deleted := 0
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return deleted, err
+		}
+
+		err = stub.DelState(response.Key)
+		if err != nil {
+			return deleted, err
+		}
+		deleted = deleted + 1
+	}
+
+	return deleted, nil
+}
+
+func (s *AuctionSmartContract) readAuction(stub shim.ChaincodeStubInterface, auctionID string) (models.Auction, bool, error) {
+	var auction models.Auction
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auctionID})
+	if err != nil {
+		return auction, false, err
+	}
+
+	auctionJSON, err := stub.GetState(auctionKey)
+	if err != nil {
+		return auction, false, err
+	}
+	if auctionJSON == nil {
+		return auction, false, nil
+	}
+
+	err = json.Unmarshal(auctionJSON, &auction)
+	return auction, true, err
+}
+
+// Auctions the parcel was put on, with the leg for relay parcels
+func getParcelAuctionLinks(stub shim.ChaincodeStubInterface, parcelID int) ([]models.AuctionHasParcel, error) {
+	auctionsHasParcel, err := GetAuctionsHasParcel(stub)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []models.AuctionHasParcel
+	for _, auctionHasParcel := range auctionsHasParcel {
+		if auctionHasParcel.ParcelID == parcelID {
+			links = append(links, auctionHasParcel)
+		}
+	}
+
+	return links, nil
+}
+
+// The running auction the parcel can be taken out of
+func (s *AuctionSmartContract) readReleasableAuction(stub shim.ChaincodeStubInterface, link models.AuctionHasParcel) (models.Auction, error) {
+	auction, exists, err := s.readAuction(stub, link.AuctionID)
+	if err != nil {
+		return auction, err
+	}
+	if !exists {
+		return auction, fmt.Errorf("The auction %s of the parcel with id %d does not exist", link.AuctionID, link.ParcelID)
+	}
+	if auction.State != models.AuctionOpen && auction.State != models.AuctionScheduled {
+		return auction, fmt.Errorf("The auction %s of the parcel with id %d is %s", auction.ID, link.ParcelID, auction.State)
+	}
+	return auction, nil
+}
+
+// Takes the parcel out of a running auction. An auction left without parcels is
+// cancelled, which refunds its winning bid. Returns whether it was cancelled.
+func (s *AuctionSmartContract) releaseParcelFromAuction(stub shim.ChaincodeStubInterface, link models.AuctionHasParcel, actorID int, reason string) (bool, error) {
+	auction, err := s.readReleasableAuction(stub, link)
+	if err != nil {
+		return false, err
+	}
+
+	auctionHasParcels, err := getAuctionHasParcels(stub, auction.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if len(auctionHasParcels) == 1 {
+		_, err = s.cancelAuction(stub, &auction, actorID, reason)
+		return err == nil, err
+	}
+
+	linkKey, err := s.CreateCompositeKey(stub, EntityAuctionHasParcel, []string{auction.ID, fmt.Sprint(link.ParcelID)})
+	if err != nil {
+		return false, err
+	}
+	return false, stub.DelState(linkKey)
+}
+
+// Deletes the auction with its parcel links, bids, proxy bids and invites. Open
+// and scheduled auctions are cancelled first so the winning bid is refunded and
+// the parcels go back to Pending. Awarded auctions have parcels on the way and
+// are not deleted. The transitions are kept as the history of the auction.
+func (s *AuctionSmartContract) deleteAuctionCascade(stub shim.ChaincodeStubInterface, auction models.Auction, actorID int) (int, int, error) {
+	switch auction.State {
+	case models.AuctionOpen, models.AuctionScheduled:
+		_, err := s.cancelAuction(stub, &auction, actorID, "Auction deleted")
+		if err != nil {
+			return 0, 0, err
+		}
+	case models.AuctionClosedBids, models.AuctionAwarded:
+		return 0, 0, fmt.Errorf("The auction %s is %s and its parcels are not delivered yet", auction.ID, auction.State)
+	}
+
+	deletedLinks, err := deleteByPartialKey(stub, EntityAuctionHasParcel, []string{auction.ID})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bids, err := getBidsForAuction(stub, auction.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, bid := range bids {
+		bidKey, err := s.CreateCompositeKey(stub, EntityBid, []string{fmt.Sprint(bid.ID), fmt.Sprint(bid.AuctionID)})
+		if err != nil {
+			return 0, 0, err
+		}
+
+		err = stub.DelState(bidKey)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	_, err = deleteByPartialKey(stub, EntityProxyBid, []string{auction.ID})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	_, err = deleteByPartialKey(stub, EntityAuctionInvite, []string{auction.ID})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{auction.ID})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return deletedLinks, len(bids), stub.DelState(auctionKey)
+}
+
+// The logistic operator cancels a parcel that is Pending, or on Auction: the
+// parcel leaves its auction, and the auction is cancelled when it was the only
+// parcel on it. Relay parcels can be cancelled until one of their legs is awarded.
+func (s *AuctionSmartContract) CancelParcel(stub shim.ChaincodeStubInterface, logisticOperatorID int, parcelID int) pb.Response {
+	fmt.Println("CancelParcel Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if parcel.LogisticOperatorId != logisticOperatorID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("The parcel with id ", parcel.ID, " do not belong to current user")))
+	}
+
+	if parcel.State != models.State(models.ParcelStatePending) && parcel.State != models.State(models.ParcelStateAuction) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The parcel with id ", parcel.ID, " can only be cancelled on 'Pending' or 'Auction' state.")))
+	}
+
+	var links []models.AuctionHasParcel
+	legs, err := getParcelLegs(stub, parcel.ID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	for _, leg := range legs {
+		switch leg.State {
+		case models.State(models.ParcelStatePending):
+		case models.State(models.ParcelStateAuction):
+			links = append(links, models.AuctionHasParcel{AuctionID: leg.AuctionID, ParcelID: parcel.ID, Leg: leg.Leg})
+		default:
+			return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The leg %d of the parcel with id %d was already awarded", leg.Leg, parcel.ID)))
+		}
+	}
+	if parcel.RelayLegs == 0 && parcel.State == models.State(models.ParcelStateAuction) {
+		links = append(links, models.AuctionHasParcel{AuctionID: parcel.AuctionId, ParcelID: parcel.ID})
+	}
+	for _, link := range links {
+		_, err = s.readReleasableAuction(stub, link)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+		}
+	}
+
+	var response struct {
+		Parcel            models.Parcel `json:"parcel"`
+		CancelledAuctions []string      `json:"cancelled_auctions"`
+		DetachedAuctions  []string      `json:"detached_auctions"`
+	}
+	response.CancelledAuctions = []string{}
+	response.DetachedAuctions = []string{}
+
+	for _, link := range links {
+		cancelled, err := s.releaseParcelFromAuction(stub, link, logisticOperatorID, fmt.Sprint("Parcel ", parcel.ID, " cancelled"))
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if cancelled {
+			response.CancelledAuctions = append(response.CancelledAuctions, link.AuctionID)
+		} else {
+			response.DetachedAuctions = append(response.DetachedAuctions, link.AuctionID)
+		}
+	}
+
+	// Written after the auctions, which move their parcels back to Pending
+	for _, leg := range legs {
+		legKey, err := s.CreateCompositeKey(stub, EntityParcelLeg, []string{fmt.Sprint(parcel.ID), fmt.Sprintf("%03d", leg.Leg)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		leg.State = models.State(models.ParcelStateCancelled)
+		leg.AuctionID = ""
+		_, err = s.putParcelLeg(stub, legKey, leg)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	parcel.State = models.State(models.ParcelStateCancelled)
+	parcel.AuctionId = ""
+	_, err = s.putParcel(stub, parcelKey, &parcel, logisticOperatorID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	response.Parcel = parcel
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// The platform deletes a parcel with the records kept for it. A parcel put on
+// auctions is only deleted with cascade: it leaves the auctions, and the ones
+// left without parcels are deleted too. SLA breaches stay with the courier.
+func (s *AuctionSmartContract) DeleteParcel(stub shim.ChaincodeStubInterface, participantID int, parcelID int, cascade bool) pb.Response {
+	fmt.Println("DeleteParcel Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can delete records"))
+	}
+
+	parcel, parcelKey, err := s.readParcel(stub, parcelID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	links, err := getParcelAuctionLinks(stub, parcel.ID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var response struct {
+		ParcelID         int      `json:"parcel_id"`
+		DeletedAuctions  []string `json:"deleted_auctions"`
+		DetachedAuctions []string `json:"detached_auctions"`
+	}
+	response.ParcelID = parcel.ID
+	response.DeletedAuctions = []string{}
+	response.DetachedAuctions = []string{}
+
+	if len(links) > 0 && !cascade {
+		auctionIDs := []string{}
+		for _, link := range links {
+			auctionIDs = append(auctionIDs, link.AuctionID)
+		}
+		return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprint("The parcel with id ", parcel.ID, " is on the auctions ", auctionIDs, ", delete them first or use cascade")))
+	}
+
+	// Every auction is checked before the first one is deleted
+	auctions := map[string]models.Auction{}
+	for _, link := range links {
+		auction, exists, err := s.readAuction(stub, link.AuctionID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		if !exists {
+			continue
+		}
+
+		if auction.State == models.AuctionClosedBids || auction.State == models.AuctionAwarded {
+			return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprintf("The auction %s is %s and its parcels are not delivered yet", auction.ID, auction.State)))
+		}
+		auctions[auction.ID] = auction
+	}
+
+	for _, link := range links {
+		auction, exists := auctions[link.AuctionID]
+		if exists {
+			auctionHasParcels, err := getAuctionHasParcels(stub, auction.ID)
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+
+			if len(auctionHasParcels) == 1 {
+				_, _, err = s.deleteAuctionCascade(stub, auction, participantID)
+				if err != nil {
+					return shim.Error(err.Error())
+				}
+				response.DeletedAuctions = append(response.DeletedAuctions, auction.ID)
+				continue
+			}
+		}
+
+		linkKey, err := s.CreateCompositeKey(stub, EntityAuctionHasParcel, []string{link.AuctionID, fmt.Sprint(parcel.ID)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		err = stub.DelState(linkKey)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		response.DetachedAuctions = append(response.DetachedAuctions, link.AuctionID)
+	}
+
+	parcelEntities := []Entity{EntityParcelLeg, EntityParcelHandover, EntityDeliveryConfirmation, EntityDeliveryAttempt, EntityDeliveryPreference, EntityParcelAmendment}
+	for _, entity := range parcelEntities {
+		_, err = deleteByPartialKey(stub, entity, []string{fmt.Sprint(parcel.ID)})
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
+	err = stub.DelState(parcelKey)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// The platform deletes an auction. An auction with parcels or bids is only
+// deleted with cascade, see deleteAuctionCascade.
+func (s *AuctionSmartContract) DeleteAuction(stub shim.ChaincodeStubInterface, participantID int, auctionID string, cascade bool) pb.Response {
+	fmt.Println("DeleteAuction Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can delete records"))
+	}
+
+	auction, exists, err := s.readAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The auction ", auctionID, " does not exist")))
+	}
+
+	if !cascade {
+		auctionHasParcels, err := getAuctionHasParcels(stub, auction.ID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		bids, err := getBidsForAuction(stub, auction.ID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		if len(auctionHasParcels) > 0 || len(bids) > 0 {
+			return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprintf("The auction %s has %d parcels and %d bids, delete them first or use cascade", auction.ID, len(auctionHasParcels), len(bids))))
+		}
+	}
+
+	if auction.State == models.AuctionClosedBids || auction.State == models.AuctionAwarded {
+		return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprintf("The auction %s is %s and its parcels are not delivered yet", auction.ID, auction.State)))
+	}
+
+	var response struct {
+		AuctionID    string `json:"auction_id"`
+		DeletedLinks int    `json:"deleted_parcel_links"`
+		DeletedBids  int    `json:"deleted_bids"`
+	}
+	response.AuctionID = auction.ID
+
+	response.DeletedLinks, response.DeletedBids, err = s.deleteAuctionCascade(stub, auction, participantID)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// The platform deletes a bid. The winning bid of an auction holds its reserved
+// Bitcircles and is only removed with the auction, unless the auction was
+// cancelled (already refunded) or no longer exists (refunded here).
+func (s *AuctionSmartContract) DeleteBid(stub shim.ChaincodeStubInterface, participantID int, bidID string, auctionID string) pb.Response {
+	fmt.Println("DeleteBid Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can delete records"))
+	}
+
+	bidKey, err := s.CreateCompositeKey(stub, EntityBid, []string{bidID, auctionID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	bidJSON, err := s.ReadEntity(stub, bidKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	var bid models.Bid
+	err = json.Unmarshal(bidJSON, &bid)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	auction, exists, err := s.readAuction(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if bid.Status == models.BitStatusLowerBid {
+		if exists && auction.State != models.AuctionCancelled {
+			return shim.Success(createErrorResponse(http.StatusConflict, fmt.Sprintf("The bid %s is the winning bid of the auction %s, cancel or delete the auction instead", bid.ID, auction.ID)))
+		}
+		if !exists {
+			err = s.releaseEscrow(stub, bid)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+		}
+	}
+
+	err = stub.DelState(bidKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(bidJSON)
+}
+
+// ** -----------------------------------------------------
+// ** REMOVAL (parcel cancellation and single record deletion)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,253 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelParcel(t *testing.T) {
+	tests := []struct {
+		name               string
+		before             func(c *testContract)
+		callerID           int
+		logisticOperatorID int
+		code               int
+		cancelledAuctions  []string
+		detachedAuctions   []string
+	}{
+		{"pending parcel", nil, testOperatorID, testOperatorID, 0, []string{}, []string{}},
+		{"only parcel of its auction", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "80.00", "5", "3")
+		}, testOperatorID, testOperatorID, 0, []string{"A1"}, []string{}},
+		{"one of the parcels of its auction", func(c *testContract) {
+			c.parcel(2, nil).auction("A1", []int{1, 2}, nil)
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "80.00", "5", "3")
+		}, testOperatorID, testOperatorID, 0, []string{}, []string{"A1"}},
+		{"by another operator", nil, 8, 8, http.StatusForbidden, nil, nil},
+		{"signed by another operator", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+		}, 8, testOperatorID, http.StatusForbidden, nil, nil},
+		{"awarded parcel", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+			c.award("b1", "A1", "80.00", "5", "3")
+		}, testOperatorID, testOperatorID, http.StatusBadRequest, nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{3: 100}).parcel(1, nil)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			c.as(test.callerID)
+			var parcel models.Parcel
+			if test.code != 0 {
+				c.fails(test.code, "CancelParcel", fmt.Sprint(test.logisticOperatorID), "1")
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+				require.NotEqual(t, models.State(models.ParcelStateCancelled), parcel.State)
+				var auction models.Auction
+				if c.entity(EntityAuction, &auction, "A1") {
+					require.NotEqual(t, models.AuctionCancelled, auction.State)
+				}
+				return
+			}
+
+			var response struct {
+				Parcel            models.Parcel `json:"parcel"`
+				CancelledAuctions []string      `json:"cancelled_auctions"`
+				DetachedAuctions  []string      `json:"detached_auctions"`
+			}
+			c.ok(&response, "CancelParcel", fmt.Sprint(test.logisticOperatorID), "1")
+			require.EqualValues(t, models.ParcelStateCancelled, response.Parcel.State)
+			require.Empty(t, response.Parcel.AuctionId)
+			require.Equal(t, test.cancelledAuctions, response.CancelledAuctions)
+			require.Equal(t, test.detachedAuctions, response.DetachedAuctions)
+
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, models.ParcelStateCancelled, parcel.State)
+
+			// The winning bid is only refunded when its auction is cancelled
+			var auction models.Auction
+			if len(test.cancelledAuctions) > 0 {
+				require.True(t, c.entity(EntityAuction, &auction, "A1"))
+				require.Equal(t, models.AuctionCancelled, auction.State)
+				require.Zero(t, c.wallet(3).Escrowed)
+			}
+			if len(test.detachedAuctions) > 0 {
+				require.True(t, c.entity(EntityAuction, &auction, "A1"))
+				require.Equal(t, models.AuctionOpen, auction.State)
+				require.Equal(t, 5, c.wallet(3).Escrowed)
+				require.False(t, c.entity(EntityAuctionHasParcel, &models.AuctionHasParcel{}, "A1", "1"))
+			}
+		})
+	}
+}
+
+func TestDeleteParcel(t *testing.T) {
+	tests := []struct {
+		name            string
+		before          func(c *testContract)
+		callerID        int
+		cascade         string
+		code            int
+		deletedAuctions []string
+	}{
+		{"pending parcel", nil, PlatformWalletId, "false", 0, []string{}},
+		{"by another participant", nil, testOperatorID, "false", http.StatusForbidden, nil},
+		{"on auction without cascade", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+		}, PlatformWalletId, "false", http.StatusConflict, nil},
+		{"on auction with cascade", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+		}, PlatformWalletId, "true", 0, []string{"A1"}},
+		{"on an awarded auction", func(c *testContract) {
+			c.auction("A1", []int{1}, nil)
+			c.award("b1", "A1", "80.00", "5", "3")
+		}, PlatformWalletId, "true", http.StatusConflict, nil},
+		{"on an expired auction and an awarded one", func(c *testContract) {
+			c.auction("A0", []int{1}, nil)
+			c.after(6*time.Hour).ok(nil, "CloseExpiredAuctions", "A0")
+			c.auction("A1", []int{1}, nil)
+			c.award("b1", "A1", "80.00", "5", "3")
+		}, PlatformWalletId, "true", http.StatusConflict, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{3: 100}).parcel(1, nil)
+			c.as(11).ok(nil, "SetDeliveryPreferences", "11", toJSON(t, models.DeliveryPreference{ParcelID: 1, HoldAtDepot: true}))
+			c.as(PlatformWalletId)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			var parcel models.Parcel
+			if test.code != 0 {
+				recordsBefore := len(c.stub.State)
+				c.as(test.callerID).fails(test.code, "DeleteParcel", fmt.Sprint(test.callerID), "1", test.cascade)
+				require.True(t, c.entity(EntityParcel, &parcel, "1"))
+
+				// Nothing is deleted when one of the auctions stops the delete
+				require.Equal(t, recordsBefore, len(c.stub.State))
+				return
+			}
+
+			var response struct {
+				ParcelID        int      `json:"parcel_id"`
+				DeletedAuctions []string `json:"deleted_auctions"`
+			}
+			c.as(test.callerID).ok(&response, "DeleteParcel", fmt.Sprint(test.callerID), "1", test.cascade)
+			require.Equal(t, 1, response.ParcelID)
+			require.Equal(t, test.deletedAuctions, response.DeletedAuctions)
+
+			// The records kept for the parcel go with it
+			require.False(t, c.entity(EntityParcel, &parcel, "1"))
+			require.False(t, c.entity(EntityDeliveryPreference, &models.DeliveryPreference{}, "1"))
+			require.False(t, c.entity(EntityAuction, &models.Auction{}, "A1"))
+			c.fails(http.StatusNotFound, "DeleteParcel", fmt.Sprint(test.callerID), "1")
+		})
+	}
+}
+
+func TestDeleteAuction(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    func(c *testContract)
+		callerID  int
+		auctionID string
+		cascade   string
+		code      int
+	}{
+		{"by another participant", nil, testOperatorID, "A1", "true", http.StatusForbidden},
+		{"unknown auction", nil, PlatformWalletId, "A2", "true", http.StatusNotFound},
+		{"with parcels and bids without cascade", nil, PlatformWalletId, "A1", "false", http.StatusConflict},
+		{"awarded auction", func(c *testContract) {
+			c.after(6*time.Hour).ok(nil, "CloseExpiredAuctions", "A1")
+		}, PlatformWalletId, "A1", "true", http.StatusConflict},
+		{"open auction with cascade", nil, PlatformWalletId, "A1", "true", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{3: 100}).parcel(1, nil)
+			c.auction("A1", []int{1}, nil)
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "80.00", "5", "3")
+			if test.before != nil {
+				test.before(c)
+			}
+
+			var auction models.Auction
+			if test.code != 0 {
+				c.as(test.callerID).fails(test.code, "DeleteAuction", fmt.Sprint(test.callerID), test.auctionID, test.cascade)
+				require.True(t, c.entity(EntityAuction, &auction, "A1"))
+				return
+			}
+
+			var response struct {
+				AuctionID    string `json:"auction_id"`
+				DeletedLinks int    `json:"deleted_parcel_links"`
+				DeletedBids  int    `json:"deleted_bids"`
+			}
+			c.as(test.callerID).ok(&response, "DeleteAuction", fmt.Sprint(test.callerID), test.auctionID, test.cascade)
+			require.Equal(t, 1, response.DeletedLinks)
+			require.Equal(t, 1, response.DeletedBids)
+			require.False(t, c.entity(EntityAuction, &auction, "A1"))
+			require.False(t, c.entity(EntityBid, &models.Bid{}, "b1", "A1"))
+
+			// The auction is cancelled first, which refunds the bid and frees the parcel
+			require.Zero(t, c.wallet(3).Escrowed)
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+			require.EqualValues(t, models.ParcelStatePending, parcel.State)
+		})
+	}
+}
+
+func TestDeleteBid(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   func(c *testContract)
+		callerID int
+		bidID    string
+		code     int
+	}{
+		{"by another participant", nil, 3, "b1", http.StatusForbidden},
+		{"unknown bid", nil, PlatformWalletId, "b3", http.StatusNotFound},
+		{"outbid bid", nil, PlatformWalletId, "b1", 0},
+		{"winning bid", nil, PlatformWalletId, "b2", http.StatusConflict},
+		{"winning bid of a cancelled auction", func(c *testContract) {
+			c.ok(nil, "CancelAuction", fmt.Sprint(PlatformWalletId), "A1", "No longer needed")
+		}, PlatformWalletId, "b2", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+			c.auction("A1", []int{1}, nil)
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "2")
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "A1", "80.00", "5", "3")
+			if test.before != nil {
+				test.before(c)
+			}
+
+			var bid models.Bid
+			if test.code != 0 {
+				c.as(test.callerID).fails(test.code, "DeleteBid", fmt.Sprint(test.callerID), test.bidID, "A1")
+				require.True(t, c.entity(EntityBid, &bid, "b2", "A1"))
+				return
+			}
+
+			c.as(test.callerID).ok(&bid, "DeleteBid", fmt.Sprint(test.callerID), test.bidID, "A1")
+			require.Equal(t, test.bidID, bid.ID)
+			require.False(t, c.entity(EntityBid, &bid, test.bidID, "A1"))
+			require.Zero(t, c.wallet(2).Escrowed)
+		})
+	}
+}
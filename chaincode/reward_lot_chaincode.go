@@ -0,0 +1,293 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** REWARD EXPIRY (reward Bitcircles tracked by lots)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetParticipantWalletById", "2"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["SweepExpiredRewards", "0"]}'
+*/
+
+// Reward lot as updated by the current transaction
+type bookRewardLot struct {
+	lot models.RewardLot
+	key string
+}
+
+// Lots are kept in the order they were credited: REWARD_LOT <participant> <date> <transaction id>
+func (s *AuctionSmartContract) rewardLotKey(stub shim.ChaincodeStubInterface, lot models.RewardLot) (string, error) {
+	return s.CreateCompositeKey(stub, EntityRewardLot, []string{fmt.Sprint(lot.ParticipantID), lot.CreditedAt.UTC().Format(walletStatementDateLayout), string(lot.TransactionID)})
+}
+
+func getParticipantRewardLots(stub shim.ChaincodeStubInterface, participantID int) ([]models.RewardLot, []string, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityRewardLot), []string{fmt.Sprint(participantID)})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	lots := []models.RewardLot{}
+	keys := []string{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var lot models.RewardLot
+		err = json.Unmarshal(response.Value, &lot)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lots = append(lots, lot)
+		keys = append(keys, response.Key)
+	}
+
+	return lots, keys, nil
+}
+
+// The open lots of a wallet, oldest first, are read once and kept on the scope
+// of the transaction like its wallets
+func (s *AuctionSmartContract) getBookRewardLots(stub shim.ChaincodeStubInterface, participantID int) ([]*bookRewardLot, error) {
+	var books []*bookRewardLot
+	found := false
+	withTxScope(stub, func(scope *txScope) {
+		books, found = scope.rewardLots[participantID]
+	})
+	if found {
+		return books, nil
+	}
+
+	lots, keys, err := getParticipantRewardLots(stub, participantID)
+	if err != nil {
+		return nil, err
+	}
+
+	books = []*bookRewardLot{}
+	for i, lot := range lots {
+		if lot.State == models.RewardLotOpen {
+			books = append(books, &bookRewardLot{lot: lot, key: keys[i]})
+		}
+	}
+
+	withTxScope(stub, func(scope *txScope) {
+		scope.rewardLots[participantID] = books
+	})
+	return books, nil
+}
+
+func (s *AuctionSmartContract) putBookRewardLot(stub shim.ChaincodeStubInterface, book *bookRewardLot) error {
+	dataLot, err := json.Marshal(book.lot)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, book.key, dataLot)
+	return err
+}
+
+// Rewards credited while the expiry is off (0 days) never expire
+func (s *AuctionSmartContract) creditRewardLot(stub shim.ChaincodeStubInterface, participantID int, transaction models.BitcircleTransaction) error {
+	config, err := s.getPlatformConfig(stub)
+	if err != nil || config.RewardExpiryDays == 0 {
+		return err
+	}
+
+	books, err := s.getBookRewardLots(stub, participantID)
+	if err != nil {
+		return err
+	}
+
+	lot := models.RewardLot{
+		ParticipantID: participantID,
+		TransactionID: transaction.ID,
+		Amount:        transaction.BitcircleAmount,
+		Remaining:     transaction.BitcircleAmount,
+		State:         models.RewardLotOpen,
+		CreditedAt:    transaction.Date,
+		ExpiresAt:     transaction.Date.AddDate(0, 0, config.RewardExpiryDays),
+	}
+
+	lotKey, err := s.rewardLotKey(stub, lot)
+	if err != nil {
+		return err
+	}
+
+	book := &bookRewardLot{lot: lot, key: lotKey}
+	withTxScope(stub, func(scope *txScope) {
+		scope.rewardLots[participantID] = append(books, book)
+	})
+	return s.putBookRewardLot(stub, book)
+}
+
+// Spends the oldest open lots first, the rest of the amount are Bitcircles that
+// were not rewards
+func (s *AuctionSmartContract) consumeRewardLots(stub shim.ChaincodeStubInterface, participantID int, bitcircleAmount int) error {
+	books, err := s.getBookRewardLots(stub, participantID)
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		if bitcircleAmount == 0 {
+			break
+		}
+		if book.lot.State != models.RewardLotOpen {
+			continue
+		}
+
+		consumed := book.lot.Remaining
+		if consumed > bitcircleAmount {
+			consumed = bitcircleAmount
+		}
+		bitcircleAmount = bitcircleAmount - consumed
+
+		book.lot.Remaining = book.lot.Remaining - consumed
+		if book.lot.Remaining == 0 {
+			book.lot.State = models.RewardLotSpent
+		}
+
+		err = s.putBookRewardLot(stub, book)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remaining Bitcircles of the open lots of the wallet, and those of them that
+// expire up to the given date (the expired ones not swept yet included)
+func getRewardLotTotals(stub shim.ChaincodeStubInterface, participantID int, expiringUntil time.Time) (int, int, error) {
+	lots, _, err := getParticipantRewardLots(stub, participantID)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	rewards := 0
+	expiringSoon := 0
+	for _, lot := range lots {
+		if lot.State != models.RewardLotOpen {
+			continue
+		}
+		rewards = rewards + lot.Remaining
+		if !lot.ExpiresAt.After(expiringUntil) {
+			expiringSoon = expiringSoon + lot.Remaining
+		}
+	}
+
+	return rewards, expiringSoon, nil
+}
+
+// Takes the remaining Bitcircles of the expired lots back to the treasury. Only
+// the usable balance is taken, the Bitcircles escrowed by bids stay on the wallet.
+func (s *AuctionSmartContract) SweepExpiredRewards(stub shim.ChaincodeStubInterface, participantID int) pb.Response {
+	fmt.Println("SweepExpiredRewards Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can sweep the expired rewards"))
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityRewardLot), []string{})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	participantIDs := []int{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var lot models.RewardLot
+		err = json.Unmarshal(response.Value, &lot)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		expired := lot.State == models.RewardLotOpen && !lot.ExpiresAt.After(txTime)
+		if expired && (len(participantIDs) == 0 || participantIDs[len(participantIDs)-1] != lot.ParticipantID) {
+			participantIDs = append(participantIDs, lot.ParticipantID)
+		}
+	}
+
+	var response struct {
+		SweptAt         time.Time          `json:"swept_at"`
+		BitcircleAmount int                `json:"bitcircle_amount"`
+		Lots            []models.RewardLot `json:"lots"`
+	}
+	response.SweptAt = txTime
+	response.Lots = []models.RewardLot{}
+
+	for _, lotParticipantID := range participantIDs {
+		books, err := s.getBookRewardLots(stub, lotParticipantID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		for _, book := range books {
+			if book.lot.State != models.RewardLotOpen || book.lot.ExpiresAt.After(txTime) {
+				continue
+			}
+
+			wallet, err := s.getBookWallet(stub, lotParticipantID)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			expired := book.lot.Remaining
+			if expired > wallet.wallet.UsableBalance {
+				expired = wallet.wallet.UsableBalance
+			}
+
+			description := fmt.Sprint("Reward lot ", book.lot.TransactionID, " expired.")
+			err = s.postBitcircles(stub, models.LedgerReasonRewardExpiry, availableAccount(lotParticipantID), availableAccount(PlatformWalletId), expired, LedgerReferenceRewardLot, ledgerReferenceID(lotParticipantID, book.lot.TransactionID), description)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			book.lot.Expired = expired
+			book.lot.Remaining = 0
+			book.lot.State = models.RewardLotExpired
+			err = s.putBookRewardLot(stub, book)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			response.BitcircleAmount = response.BitcircleAmount + expired
+			response.Lots = append(response.Lots, book.lot)
+		}
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** REWARD EXPIRY (reward Bitcircles tracked by lots)
+// ** -> END
+// ** -----------------------------------------------------
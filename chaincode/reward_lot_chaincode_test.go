@@ -0,0 +1,199 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Delivers a parcel of the given reward through courier 3 and returns the date
+// its reward was credited
+func (c *testContract) deliverParcel(parcelID int, reward int) time.Time {
+	c.t.Helper()
+	auctionID := fmt.Sprint("A", parcelID)
+	c.parcel(parcelID, map[string]interface{}{"bitcircle_reward": reward}).auction(auctionID, []int{parcelID}, nil)
+	c.award(fmt.Sprint("b", parcelID), auctionID, "80.00", "0", "3")
+	c.as(3).ok(nil, "ConfirmPickup", "3", fmt.Sprint(parcelID), testProofHash("pickup"))
+	c.ok(nil, "ConfirmDelivery", "3", fmt.Sprint(parcelID), testProofHash("delivery"))
+	c.as(11).ok(nil, "CountersignDelivery", "11", fmt.Sprint(parcelID))
+	c.as(PlatformWalletId)
+	return c.ledger.now
+}
+
+// Reward lots of the participant, oldest first
+func (c *testContract) rewardLots(participantID int) []models.RewardLot {
+	c.t.Helper()
+	prefix, err := c.stub.CreateCompositeKey(string(EntityRewardLot), []string{fmt.Sprint(participantID)})
+	require.NoError(c.t, err)
+
+	keys := []string{}
+	for key := range c.stub.State {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	lots := []models.RewardLot{}
+	for _, key := range keys {
+		var lot models.RewardLot
+		require.NoError(c.t, json.Unmarshal(c.stub.State[key], &lot))
+		lots = append(lots, lot)
+	}
+	return lots
+}
+
+// Courier 3 only has the rewards of parcel 1 (10 Bitcircles) and, thirty days
+// later, parcel 2 (6 Bitcircles). Returns the dates they were credited.
+func newRewardLotContract(t *testing.T) (*testContract, time.Time, time.Time) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 0})
+	firstReward := c.deliverParcel(1, 10)
+	c.after(30 * 24 * time.Hour)
+	secondReward := c.deliverParcel(2, 6)
+	return c, firstReward, secondReward
+}
+
+func TestConsumeRewardLots(t *testing.T) {
+	tests := []struct {
+		name      string
+		spent     int
+		remaining []int
+		states    []models.RewardLotState
+	}{
+		{"nothing spent", 0, []int{10, 6}, []models.RewardLotState{models.RewardLotOpen, models.RewardLotOpen}},
+		{"part of the oldest lot", 4, []int{6, 6}, []models.RewardLotState{models.RewardLotOpen, models.RewardLotOpen}},
+		{"the oldest lot", 10, []int{0, 6}, []models.RewardLotState{models.RewardLotSpent, models.RewardLotOpen}},
+		{"into the newest lot", 12, []int{0, 4}, []models.RewardLotState{models.RewardLotSpent, models.RewardLotOpen}},
+		{"every lot", 16, []int{0, 0}, []models.RewardLotState{models.RewardLotSpent, models.RewardLotSpent}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _, _ := newRewardLotContract(t)
+			if test.spent > 0 {
+				c.ok(nil, "TransferBitcircles", "3", "2", fmt.Sprint(test.spent), "false", "Shared delivery")
+			}
+
+			lots := c.rewardLots(3)
+			require.Len(t, lots, 2)
+			for i, lot := range lots {
+				require.Equal(t, test.remaining[i], lot.Remaining, i)
+				require.Equal(t, test.states[i], lot.State, i)
+			}
+
+			var wallet struct {
+				Rewards int `json:"rewards"`
+			}
+			c.ok(&wallet, "GetParticipantWalletById", "3")
+			require.Equal(t, 16-test.spent, wallet.Rewards)
+
+			// Bitcircles received by the other wallet are not rewards
+			require.Empty(t, c.rewardLots(2))
+		})
+	}
+}
+
+func TestRewardsExpiringSoon(t *testing.T) {
+	c, firstReward, secondReward := newRewardLotContract(t)
+
+	tests := []struct {
+		name         string
+		at           time.Time
+		expiringSoon int
+	}{
+		{"after the rewards", secondReward, 0},
+		{"a month before the first expires", firstReward.AddDate(0, 0, 335), 10},
+		{"a month before the second expires", secondReward.AddDate(0, 0, 335), 16},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var wallet struct {
+				Rewards      int `json:"rewards"`
+				ExpiringSoon int `json:"expiring_soon"`
+			}
+			c.at(test.at).ok(&wallet, "GetParticipantWalletById", "3")
+			require.Equal(t, 16, wallet.Rewards)
+			require.Equal(t, test.expiringSoon, wallet.ExpiringSoon)
+		})
+	}
+}
+
+func TestSweepExpiredRewards(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   func(c *testContract)
+		after    time.Duration
+		swept    int
+		expired  []int
+		balance  int
+		escrowed int
+	}{
+		{"before any expiry", nil, 364 * 24 * time.Hour, 0, []int{0, 0}, 16, 0},
+		{"the first lot expired", nil, 365 * 24 * time.Hour, 10, []int{10, 0}, 6, 0},
+		{"every lot expired", nil, 396 * 24 * time.Hour, 16, []int{10, 6}, 0, 0},
+		{"what remains of spent lots", func(c *testContract) {
+			c.ok(nil, "TransferBitcircles", "3", "2", "12", "false", "Shared delivery")
+		}, 396 * 24 * time.Hour, 4, []int{0, 4}, 0, 0},
+		{"escrowed Bitcircles stay", func(c *testContract) {
+			c.parcel(3, nil).auction("A3", []int{3}, nil)
+			c.ok(nil, "ParcelDeliveryBidingRequest", "b3", "A3", "80.00", "12", "3")
+		}, 396 * 24 * time.Hour, 4, []int{4, 0}, 12, 12},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, firstReward, _ := newRewardLotContract(t)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			c.as(2).fails(http.StatusForbidden, "SweepExpiredRewards", "2")
+
+			var response struct {
+				BitcircleAmount int                `json:"bitcircle_amount"`
+				Lots            []models.RewardLot `json:"lots"`
+			}
+			c.as(PlatformWalletId).at(firstReward.Add(test.after)).ok(&response, "SweepExpiredRewards", "0")
+			require.Equal(t, test.swept, response.BitcircleAmount)
+
+			for i, lot := range c.rewardLots(3) {
+				require.Equal(t, test.expired[i], lot.Expired, i)
+				// Expired lots are either swept or already spent
+				if !lot.ExpiresAt.After(firstReward.Add(test.after)) {
+					require.NotEqual(t, models.RewardLotOpen, lot.State, i)
+					require.Zero(t, lot.Remaining, i)
+				}
+			}
+
+			wallet := c.wallet(3)
+			require.Equal(t, test.balance, wallet.Balance)
+			require.Equal(t, test.escrowed, wallet.Escrowed)
+
+			// Swept lots are not swept again
+			c.ok(&response, "SweepExpiredRewards", "0")
+			require.Zero(t, response.BitcircleAmount)
+		})
+	}
+}
+
+func TestRewardExpiryOff(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{3: 0})
+	c.ok(nil, "SetPlatformConfig", "0", `{"reward_expiry_days": 0}`)
+	firstReward := c.deliverParcel(1, 10)
+
+	require.Empty(t, c.rewardLots(3))
+	var response struct {
+		BitcircleAmount int `json:"bitcircle_amount"`
+	}
+	c.at(firstReward.AddDate(10, 0, 0)).ok(&response, "SweepExpiredRewards", "0")
+	require.Zero(t, response.BitcircleAmount)
+	require.Equal(t, 10, c.wallet(3).Balance)
+}
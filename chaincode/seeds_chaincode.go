@@ -24,7 +24,10 @@ func (s *AuctionSmartContract) SeedParcel(stub shim.ChaincodeStubInterface, parc
 		if parcel.State == models.State(models.ParcelStatePending) ||
 			parcel.State == models.State(models.ParcelStateAuction) ||
 			parcel.State == models.State(models.ParcelStateDelivery) ||
-			parcel.State == models.State(models.ParcelStateDelivered) {
+			parcel.State == models.State(models.ParcelStateDelivered) ||
+			parcel.State == models.State(models.ParcelStateFailedAttempt) ||
+			parcel.State == models.State(models.ParcelStateReturnedToSender) ||
+			parcel.State == models.State(models.ParcelStateCancelled) {
 			// Check if parcel already exists
 			if parcelRecordExists, err := s.EntityRecordExists(stub, parcelKey); err != nil {
 				return shim.Error(err.Error())
@@ -52,6 +55,11 @@ func (s *AuctionSmartContract) SeedParcel(stub shim.ChaincodeStubInterface, parc
 func (s *AuctionSmartContract) SeedAuction(stub shim.ChaincodeStubInterface, auctions []models.Auction, auctionsHasParcels []models.AuctionHasParcel) pb.Response {
 	fmt.Println("Seed Auctions invoke")
 	for _, auction := range auctions {
+		// Seeded auctions keep their state, it only has to be a known one
+		if _, ok := auctionTransitions[auction.State]; !ok || auction.State == "" {
+			return shim.Error(fmt.Sprint("Auction ", auction.ID, " have an invalid State: ", auction.State))
+		}
+
 		var auctionKey string
 		auctionKey, err := s.CreateCompositeKey(stub, EntityAuction, []string{fmt.Sprint(auction.ID)})
 		if err != nil {
@@ -0,0 +1,261 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** SETTLEMENT (money owed for awarded auctions)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["MarkSettlementPaid", "2", "A1", "SEPA-2022-000123"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["DisputeSettlement", "4", "A1", "Payment not received"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetOperatorSettlements", "2", "PENDING"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetCourierSettlements", "4"]}'
+*/
+
+func (s *AuctionSmartContract) putSettlement(stub shim.ChaincodeStubInterface, settlement models.Settlement) ([]byte, error) {
+	settlementKey, err := s.CreateCompositeKey(stub, EntitySettlement, []string{settlement.AuctionID})
+	if err != nil {
+		return nil, err
+	}
+
+	dataSettlement, err := json.Marshal(settlement)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, settlementKey, dataSettlement)
+	return dataSettlement, err
+}
+
+func (s *AuctionSmartContract) readSettlement(stub shim.ChaincodeStubInterface, auctionID string) (models.Settlement, error) {
+	var settlement models.Settlement
+
+	settlementKey, err := s.CreateCompositeKey(stub, EntitySettlement, []string{auctionID})
+	if err != nil {
+		return settlement, err
+	}
+
+	settlementJSON, err := stub.GetState(settlementKey)
+	if err != nil {
+		return settlement, err
+	}
+	if settlementJSON == nil {
+		return settlement, fmt.Errorf("The auction %s has no settlement", auctionID)
+	}
+
+	err = json.Unmarshal(settlementJSON, &settlement)
+	return settlement, err
+}
+
+// Records what the logistic operator owes for the winning bid of the auction.
+// The platform fee is rounded down to the minor unit.
+func (s *AuctionSmartContract) recordSettlement(stub shim.ChaincodeStubInterface, auction models.Auction, winnerBid models.Bid) error {
+	config, err := s.getPlatformConfig(stub)
+	if err != nil {
+		return err
+	}
+
+	awardedAt, err := getTxTime(stub)
+	if err != nil {
+		return err
+	}
+
+	platformFee := winnerBid.MoneyAmount * int64(config.SettlementFeePercent) / 100
+
+	_, err = s.putSettlement(stub, models.Settlement{
+		AuctionID:          auction.ID,
+		BidID:              winnerBid.ID,
+		LogisticOperatorID: auction.ParticipantId,
+		CourierID:          winnerBid.CourierID,
+		MoneyAmount:        winnerBid.MoneyAmount,
+		PlatformFee:        platformFee,
+		CourierAmount:      winnerBid.MoneyAmount - platformFee,
+		Currency:           winnerBid.Currency,
+		State:              models.SettlementPending,
+		AwardedAt:          awardedAt,
+		DueDate:            awardedAt.AddDate(0, 0, config.SettlementDueDays),
+	})
+	return err
+}
+
+// Only the logistic operator that owes the settlement marks it paid, with the
+// reference of the payment made off-chain. Disputed settlements can be paid.
+func (s *AuctionSmartContract) MarkSettlementPaid(stub shim.ChaincodeStubInterface, logisticOperatorID int, auctionID string, paymentReference string) pb.Response {
+	fmt.Println("MarkSettlementPaid Invoke")
+	err := checkCaller(stub, logisticOperatorID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	settlement, err := s.readSettlement(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if settlement.LogisticOperatorID != logisticOperatorID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("The settlement of the auction ", auctionID, " is not owed by the logistic operator ", logisticOperatorID)))
+	}
+
+	if paymentReference == "" {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "A payment reference is required to mark a settlement paid"))
+	}
+
+	if settlement.State == models.SettlementPaid {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The settlement of the auction ", auctionID, " is already paid")))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	settlement.State = models.SettlementPaid
+	settlement.PaymentReference = paymentReference
+	settlement.PaidAt = &currentTime
+
+	dataSettlement, err := s.putSettlement(stub, settlement)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataSettlement)
+}
+
+// The logistic operator or the courier of the settlement dispute it, a paid
+// settlement too when the courier did not receive the payment
+func (s *AuctionSmartContract) DisputeSettlement(stub shim.ChaincodeStubInterface, participantID int, auctionID string, reason string) pb.Response {
+	fmt.Println("DisputeSettlement Invoke")
+	err := checkCaller(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusForbidden, err.Error()))
+	}
+
+	settlement, err := s.readSettlement(stub, auctionID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	if participantID != settlement.LogisticOperatorID && participantID != settlement.CourierID {
+		return shim.Success(createErrorResponse(http.StatusForbidden, fmt.Sprint("Only the logistic operator or the courier can dispute the settlement of the auction ", auctionID)))
+	}
+
+	if reason == "" {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "A reason is required to dispute a settlement"))
+	}
+
+	if settlement.State == models.SettlementDisputed {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The settlement of the auction ", auctionID, " is already disputed")))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	settlement.State = models.SettlementDisputed
+	settlement.DisputedBy = participantID
+	settlement.DisputeReason = reason
+	settlement.DisputedAt = &currentTime
+
+	dataSettlement, err := s.putSettlement(stub, settlement)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataSettlement)
+}
+
+type settlementTotals struct {
+	MoneyAmount   int64 `json:"money_amount"`
+	PlatformFee   int64 `json:"platform_fee"`
+	CourierAmount int64 `json:"courier_amount"`
+}
+
+// Settlements of the participant in the given state (all by default), by due
+// date, with their totals per currency
+func getParticipantSettlements(stub shim.ChaincodeStubInterface, participantID int, state models.SettlementState, ofParticipant func(models.Settlement) int) pb.Response {
+	if state != "" && state != models.SettlementPending && state != models.SettlementPaid && state != models.SettlementDisputed {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Invalid settlement state ", state, ", most be PENDING, PAID or DISPUTED")))
+	}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntitySettlement), []string{})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	var response struct {
+		ParticipantID int                         `json:"participant_id"`
+		State         models.SettlementState      `json:"state,omitempty"`
+		Totals        map[string]settlementTotals `json:"totals"`
+		Settlements   []models.Settlement         `json:"settlements"`
+	}
+	response.ParticipantID = participantID
+	response.State = state
+	response.Totals = map[string]settlementTotals{}
+	response.Settlements = []models.Settlement{}
+
+	for iterator.HasNext() {
+		record, err := iterator.Next()
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		var settlement models.Settlement
+		err = json.Unmarshal(record.Value, &settlement)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+
+		if ofParticipant(settlement) != participantID || (state != "" && settlement.State != state) {
+			continue
+		}
+
+		totals := response.Totals[settlement.Currency]
+		totals.MoneyAmount = totals.MoneyAmount + settlement.MoneyAmount
+		totals.PlatformFee = totals.PlatformFee + settlement.PlatformFee
+		totals.CourierAmount = totals.CourierAmount + settlement.CourierAmount
+		response.Totals[settlement.Currency] = totals
+
+		response.Settlements = append(response.Settlements, settlement)
+	}
+
+	sort.SliceStable(response.Settlements, func(i, j int) bool {
+		return response.Settlements[i].DueDate.Before(response.Settlements[j].DueDate)
+	})
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+func (s *AuctionSmartContract) GetOperatorSettlements(stub shim.ChaincodeStubInterface, logisticOperatorID int, state models.SettlementState) pb.Response {
+	return getParticipantSettlements(stub, logisticOperatorID, state, func(settlement models.Settlement) int {
+		return settlement.LogisticOperatorID
+	})
+}
+
+func (s *AuctionSmartContract) GetCourierSettlements(stub shim.ChaincodeStubInterface, courierID int, state models.SettlementState) pb.Response {
+	return getParticipantSettlements(stub, courierID, state, func(settlement models.Settlement) int {
+		return settlement.CourierID
+	})
+}
+
+// ** -----------------------------------------------------
+// ** SETTLEMENT (money owed for awarded auctions)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,202 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSettlement(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		amount        string
+		auction       map[string]interface{}
+		moneyAmount   int64
+		platformFee   int64
+		courierAmount int64
+		dueDays       int
+	}{
+		{"default fee", "", "80.00", nil, 8000, 400, 7600, 30},
+		{"fee rounded down", "", "80.99", nil, 8099, 404, 7695, 30},
+		{"without fee", `{"settlement_fee_percent": 0, "settlement_due_days": 7}`, "80.00", nil, 8000, 0, 8000, 7},
+		{"currency without minor unit", "", "1234", map[string]interface{}{"currency": "JPY", "maximum_accepted_licitation": 100000}, 1234, 61, 1173, 30},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t)
+			if test.config != "" {
+				c.ok(nil, "SetPlatformConfig", "0", test.config)
+			}
+			c.wallets(map[int]int{3: 100}).parcel(1, nil).auction("A1", []int{1}, test.auction)
+			c.award("b1", "A1", test.amount, "0", "3")
+			awardedAt := c.ledger.now
+
+			var settlement models.Settlement
+			require.True(t, c.entity(EntitySettlement, &settlement, "A1"))
+			require.Equal(t, testOperatorID, settlement.LogisticOperatorID)
+			require.Equal(t, 3, settlement.CourierID)
+			require.Equal(t, test.moneyAmount, settlement.MoneyAmount)
+			require.Equal(t, test.platformFee, settlement.PlatformFee)
+			require.Equal(t, test.courierAmount, settlement.CourierAmount)
+			require.Equal(t, models.SettlementPending, settlement.State)
+			require.Equal(t, awardedAt.AddDate(0, 0, test.dueDays), settlement.DueDate)
+		})
+	}
+
+	// Auctions closed without bids owe nothing
+	c := newTestContract(t).parcel(1, nil).auction("A1", []int{1}, nil)
+	c.after(6*time.Hour).ok(nil, "CloseExpiredAuctions", "A1")
+	require.False(t, c.entity(EntitySettlement, &models.Settlement{}, "A1"))
+}
+
+func TestMarkSettlementPaid(t *testing.T) {
+	tests := []struct {
+		name               string
+		before             func(c *testContract)
+		callerID           int
+		logisticOperatorID int
+		auctionID          string
+		paymentReference   string
+		code               int
+	}{
+		{"by the logistic operator", nil, testOperatorID, testOperatorID, "A1", "SEPA-2023-000123", 0},
+		{"disputed settlement", func(c *testContract) {
+			c.as(3).ok(nil, "DisputeSettlement", "3", "A1", "Payment not received")
+		}, testOperatorID, testOperatorID, "A1", "SEPA-2023-000123", 0},
+		{"by another logistic operator", nil, 8, 8, "A1", "SEPA-2023-000123", http.StatusForbidden},
+		{"by the courier as the logistic operator", nil, 3, testOperatorID, "A1", "SEPA-2023-000123", http.StatusForbidden},
+		{"without payment reference", nil, testOperatorID, testOperatorID, "A1", "", http.StatusBadRequest},
+		{"paid already", func(c *testContract) {
+			c.as(testOperatorID).ok(nil, "MarkSettlementPaid", fmt.Sprint(testOperatorID), "A1", "SEPA-2023-000122")
+		}, testOperatorID, testOperatorID, "A1", "SEPA-2023-000123", http.StatusBadRequest},
+		{"unknown auction", nil, testOperatorID, testOperatorID, "A2", "SEPA-2023-000123", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newAwardedContract(t, "80.00", nil)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			c.as(test.callerID)
+			if test.code != 0 {
+				c.fails(test.code, "MarkSettlementPaid", fmt.Sprint(test.logisticOperatorID), test.auctionID, test.paymentReference)
+				return
+			}
+
+			var settlement models.Settlement
+			c.ok(&settlement, "MarkSettlementPaid", fmt.Sprint(test.logisticOperatorID), test.auctionID, test.paymentReference)
+			require.Equal(t, models.SettlementPaid, settlement.State)
+			require.Equal(t, test.paymentReference, settlement.PaymentReference)
+			require.True(t, settlement.PaidAt.Equal(c.ledger.now))
+		})
+	}
+}
+
+func TestDisputeSettlement(t *testing.T) {
+	tests := []struct {
+		name          string
+		before        func(c *testContract)
+		callerID      int
+		participantID int
+		reason        string
+		code          int
+	}{
+		{"by the courier", nil, 3, 3, "Payment not received", 0},
+		{"by the logistic operator", nil, testOperatorID, testOperatorID, "Parcel not delivered", 0},
+		{"paid settlement", func(c *testContract) {
+			c.as(testOperatorID).ok(nil, "MarkSettlementPaid", fmt.Sprint(testOperatorID), "A1", "SEPA-2023-000123")
+		}, 3, 3, "Payment not received", 0},
+		{"by another courier", nil, 2, 2, "Payment not received", http.StatusForbidden},
+		{"by another courier as the courier", nil, 2, 3, "Payment not received", http.StatusForbidden},
+		{"without reason", nil, 3, 3, "", http.StatusBadRequest},
+		{"disputed already", func(c *testContract) {
+			c.as(testOperatorID).ok(nil, "DisputeSettlement", fmt.Sprint(testOperatorID), "A1", "Parcel not delivered")
+		}, 3, 3, "Payment not received", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newAwardedContract(t, "80.00", nil)
+			if test.before != nil {
+				test.before(c)
+			}
+
+			c.as(test.callerID)
+			if test.code != 0 {
+				c.fails(test.code, "DisputeSettlement", fmt.Sprint(test.participantID), "A1", test.reason)
+				return
+			}
+
+			var settlement models.Settlement
+			c.ok(&settlement, "DisputeSettlement", fmt.Sprint(test.participantID), "A1", test.reason)
+			require.Equal(t, models.SettlementDisputed, settlement.State)
+			require.Equal(t, test.participantID, settlement.DisputedBy)
+			require.Equal(t, test.reason, settlement.DisputeReason)
+		})
+	}
+}
+
+func TestGetSettlements(t *testing.T) {
+	// A1 is paid to courier 3, A2 later to courier 2 and A3 to courier 3 in JPY
+	c := newAwardedContract(t, "80.00", nil)
+	c.as(testOperatorID).ok(nil, "MarkSettlementPaid", fmt.Sprint(testOperatorID), "A1", "SEPA-2023-000123")
+	c.as(PlatformWalletId).parcel(2, nil).parcel(3, nil)
+	c.auction("A2", []int{2}, nil)
+	c.auction("A3", []int{3}, map[string]interface{}{"currency": "JPY", "maximum_accepted_licitation": 100000})
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b2", "A2", "60.00", "0", "2")
+	c.award("b3", "A3", "1000", "0", "3")
+	c.after(time.Hour).ok(nil, "CloseExpiredAuctions", "A2")
+
+	tests := []struct {
+		name          string
+		function      string
+		participantID int
+		state         string
+		auctionIDs    []string
+		totals        map[string]settlementTotals
+	}{
+		{"operator", "GetOperatorSettlements", testOperatorID, "", []string{"A1", "A3", "A2"}, map[string]settlementTotals{
+			"EUR": {MoneyAmount: 14000, PlatformFee: 700, CourierAmount: 13300},
+			"JPY": {MoneyAmount: 1000, PlatformFee: 50, CourierAmount: 950},
+		}},
+		{"operator pending", "GetOperatorSettlements", testOperatorID, "PENDING", []string{"A3", "A2"}, map[string]settlementTotals{
+			"EUR": {MoneyAmount: 6000, PlatformFee: 300, CourierAmount: 5700},
+			"JPY": {MoneyAmount: 1000, PlatformFee: 50, CourierAmount: 950},
+		}},
+		{"courier", "GetCourierSettlements", 3, "", []string{"A1", "A3"}, map[string]settlementTotals{
+			"EUR": {MoneyAmount: 8000, PlatformFee: 400, CourierAmount: 7600},
+			"JPY": {MoneyAmount: 1000, PlatformFee: 50, CourierAmount: 950},
+		}},
+		{"courier paid", "GetCourierSettlements", 3, "PAID", []string{"A1"}, map[string]settlementTotals{
+			"EUR": {MoneyAmount: 8000, PlatformFee: 400, CourierAmount: 7600},
+		}},
+		{"another operator", "GetOperatorSettlements", 8, "", []string{}, map[string]settlementTotals{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var response struct {
+				Totals      map[string]settlementTotals `json:"totals"`
+				Settlements []models.Settlement         `json:"settlements"`
+			}
+			c.ok(&response, test.function, fmt.Sprint(test.participantID), test.state)
+
+			auctionIDs := []string{}
+			for _, settlement := range response.Settlements {
+				auctionIDs = append(auctionIDs, settlement.AuctionID)
+			}
+			require.Equal(t, test.auctionIDs, auctionIDs)
+			require.Equal(t, test.totals, response.Totals)
+		})
+	}
+
+	c.fails(http.StatusBadRequest, "GetCourierSettlements", "3", "OVERDUE")
+}
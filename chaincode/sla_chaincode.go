@@ -0,0 +1,175 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** SLA (RequiredDeliveryDate enforcement)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetCourierSlaBreaches", "4"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetOperatorSlaBreaches", "2"]}'
+*/
+
+// Started hours between the required delivery date and the delivery
+func getHoursLate(requiredDeliveryDate time.Time, deliveredAt time.Time) int {
+	if !deliveredAt.After(requiredDeliveryDate) {
+		return 0
+	}
+
+	late := deliveredAt.Sub(requiredDeliveryDate)
+	hoursLate := int(late / time.Hour)
+	if late%time.Hour != 0 {
+		hoursLate = hoursLate + 1
+	}
+	return hoursLate
+}
+
+// Every hour late is charged the PenaltyPerHour of the last tier started at that hour
+func getSlaPenalty(tiers []models.SlaPenaltyTier, hoursLate int) int {
+	penalty := 0
+	for hour := 0; hour < hoursLate; hour++ {
+		penaltyPerHour := 0
+		for _, tier := range tiers {
+			if hour >= tier.FromHoursLate {
+				penaltyPerHour = tier.PenaltyPerHour
+			}
+		}
+		penalty = penalty + penaltyPerHour
+	}
+	return penalty
+}
+
+// Checks the delivery against the RequiredDeliveryDate of the parcel and stores
+// a breach when it is late. The penalty is capped at the courier reward.
+func (s *AuctionSmartContract) checkDeliverySla(stub shim.ChaincodeStubInterface, parcel models.Parcel, reward int, confirmation *models.DeliveryConfirmation) error {
+	confirmation.HoursLate = getHoursLate(parcel.RequiredDeliveryDate, confirmation.DeliveredAt)
+	confirmation.SlaPenalty = 0
+	if confirmation.HoursLate == 0 {
+		return nil
+	}
+
+	config, err := s.getPlatformConfig(stub)
+	if err != nil {
+		return err
+	}
+
+	confirmation.SlaPenalty = getSlaPenalty(config.SlaPenaltyTiers, confirmation.HoursLate)
+	if confirmation.SlaPenalty > reward {
+		confirmation.SlaPenalty = reward
+	}
+
+	breach := models.SlaBreach{
+		ParcelID:             parcel.ID,
+		AuctionID:            confirmation.AuctionID,
+		CourierID:            confirmation.CourierID,
+		LogisticOperatorID:   parcel.LogisticOperatorId,
+		RequiredDeliveryDate: parcel.RequiredDeliveryDate,
+		DeliveredAt:          confirmation.DeliveredAt,
+		HoursLate:            confirmation.HoursLate,
+		Penalty:              confirmation.SlaPenalty,
+		TxID:                 stub.GetTxID(),
+	}
+
+	breachKey, err := s.CreateCompositeKey(stub, EntitySlaBreach, []string{fmt.Sprint(parcel.ID), breach.TxID})
+	if err != nil {
+		return err
+	}
+
+	dataBreach, err := json.Marshal(breach)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, breachKey, dataBreach)
+	return err
+}
+
+type slaBreachSummary struct {
+	ParticipantID  int                `json:"participant_id"`
+	BreachCount    int                `json:"breach_count"`
+	TotalHoursLate int                `json:"total_hours_late"`
+	TotalPenalty   int                `json:"total_penalty"`
+	Breaches       []models.SlaBreach `json:"breaches"`
+}
+
+func getSlaBreachSummary(stub shim.ChaincodeStubInterface, participantID int, matches func(models.SlaBreach) bool) (slaBreachSummary, error) {
+	summary := slaBreachSummary{ParticipantID: participantID, Breaches: []models.SlaBreach{}}
+
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntitySlaBreach), []string{})
+	if err != nil {
+		return summary, err
+	}
+	defer iterator.Close()
+
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return summary, err
+		}
+
+		var breach models.SlaBreach
+		err = json.Unmarshal(response.Value, &breach)
+		if err != nil {
+			return summary, err
+		}
+
+		if !matches(breach) {
+			continue
+		}
+
+		summary.BreachCount = summary.BreachCount + 1
+		summary.TotalHoursLate = summary.TotalHoursLate + breach.HoursLate
+		summary.TotalPenalty = summary.TotalPenalty + breach.Penalty
+		summary.Breaches = append(summary.Breaches, breach)
+	}
+
+	return summary, nil
+}
+
+func (s *AuctionSmartContract) GetCourierSlaBreaches(stub shim.ChaincodeStubInterface, courierID int) pb.Response {
+	summary, err := getSlaBreachSummary(stub, courierID, func(breach models.SlaBreach) bool {
+		return breach.CourierID == courierID
+	})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(summaryJSON)
+}
+
+func (s *AuctionSmartContract) GetOperatorSlaBreaches(stub shim.ChaincodeStubInterface, logisticOperatorID int) pb.Response {
+	summary, err := getSlaBreachSummary(stub, logisticOperatorID, func(breach models.SlaBreach) bool {
+		return breach.LogisticOperatorID == logisticOperatorID
+	})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(summaryJSON)
+}
+
+// ** -----------------------------------------------------
+// ** SLA (RequiredDeliveryDate enforcement)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,104 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHoursLate(t *testing.T) {
+	tests := []struct {
+		name      string
+		late      time.Duration
+		hoursLate int
+	}{
+		{"early", -time.Hour, 0},
+		{"on the required date", 0, 0},
+		{"one second late", time.Second, 1},
+		{"one hour late", time.Hour, 1},
+		{"into the second hour", 61 * time.Minute, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.hoursLate, getHoursLate(testStartTime, testStartTime.Add(test.late)))
+		})
+	}
+}
+
+func TestGetSlaPenalty(t *testing.T) {
+	tiers := []models.SlaPenaltyTier{
+		{FromHoursLate: 0, PenaltyPerHour: 1},
+		{FromHoursLate: 24, PenaltyPerHour: 2},
+	}
+
+	tests := []struct {
+		hoursLate int
+		penalty   int
+	}{
+		{0, 0},
+		{1, 1},
+		{24, 24},
+		{25, 26},
+		{30, 36},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.hoursLate, " hours late"), func(t *testing.T) {
+			require.Equal(t, test.penalty, getSlaPenalty(tiers, test.hoursLate))
+		})
+	}
+}
+
+func TestDeliverySla(t *testing.T) {
+	tests := []struct {
+		name      string
+		late      time.Duration
+		hoursLate int
+		penalty   int
+	}{
+		{"on time", -time.Hour, 0, 0},
+		{"two hours late", 2 * time.Hour, 2, 2},
+		{"in the fourth hour late", 3*time.Hour + 30*time.Minute, 4, 4},
+		{"penalty capped at the reward", 30 * time.Hour, 30, 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newDeliveryContract(t)
+			var parcel models.Parcel
+			require.True(t, c.entity(EntityParcel, &parcel, "1"))
+
+			c.as(3).ok(nil, "ConfirmPickup", "3", "1", testProofHash("pickup"))
+			var confirmation models.DeliveryConfirmation
+			c.at(parcel.RequiredDeliveryDate.Add(test.late)).ok(&confirmation, "ConfirmDelivery", "3", "1", testProofHash("delivery"))
+			require.Equal(t, test.hoursLate, confirmation.HoursLate)
+			require.Equal(t, test.penalty, confirmation.SlaPenalty)
+
+			// The penalty is charged back from the reward at countersign
+			c.as(11).ok(&confirmation, "CountersignDelivery", "11", "1")
+			require.Equal(t, 10-test.penalty, confirmation.RewardPaid)
+			require.Equal(t, 110-test.penalty, c.wallet(3).Balance)
+
+			breachCount := 0
+			if test.hoursLate != 0 {
+				breachCount = 1
+			}
+			for function, participantID := range map[string]int{"GetCourierSlaBreaches": 3, "GetOperatorSlaBreaches": testOperatorID} {
+				var summary slaBreachSummary
+				c.ok(&summary, function, fmt.Sprint(participantID))
+				require.Equal(t, breachCount, summary.BreachCount, function)
+				require.Equal(t, test.hoursLate, summary.TotalHoursLate, function)
+				require.Equal(t, test.penalty, summary.TotalPenalty, function)
+			}
+
+			// Breaches are only counted for their courier and operator
+			var summary slaBreachSummary
+			c.ok(&summary, "GetCourierSlaBreaches", "2")
+			require.Zero(t, summary.BreachCount)
+		})
+	}
+}
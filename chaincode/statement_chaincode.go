@@ -0,0 +1,371 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** WALLET STATEMENT (Bitcircle transactions of a participant in time order)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetWalletStatement", "2", "2022-05-01T00:00:00Z", "2022-06-01T00:00:00Z", "20", ""]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["IndexBitcircleTransactions", "0"]}'
+*/
+
+const DefaultStatementPageSize = 50
+const MaxStatementPageSize = 500
+
+// The index of a participant is kept on simple keys, composite keys can not be
+// queried by range: WALLET_STATEMENT_<participant>_<date>_<transaction id>
+const walletStatementKeyPrefix = "WALLET_STATEMENT_"
+const walletStatementDateLayout = "20060102T150405.000000000"
+
+type statementDirection string
+
+const (
+	StatementIn       statementDirection = "IN"
+	StatementOut      statementDirection = "OUT"
+	StatementInternal statementDirection = "INTERNAL"
+)
+
+// Value of an index entry, with the balance of the wallet after the transaction
+type walletStatementIndex struct {
+	TransactionID models.BitcircleTransactionID `json:"transaction_id"`
+	BalanceAfter  int                           `json:"balance_after"`
+}
+
+type walletStatementEntry struct {
+	TransactionID   models.BitcircleTransactionID `json:"transaction_id"`
+	Date            time.Time                     `json:"date"`
+	Direction       statementDirection            `json:"direction"`
+	CounterpartyID  int                           `json:"counterparty_id"`
+	BitcircleAmount int                           `json:"bitcircle_amount"`
+	Reason          models.LedgerReason           `json:"reason,omitempty"`
+	Description     string                        `json:"description"`
+	IsReward        bool                          `json:"is_reward"`
+	BalanceAfter    int                           `json:"balance_after"`
+	change          int                           // of the participant balance
+}
+
+func walletStatementPrefix(participantID int) string {
+	return fmt.Sprint(walletStatementKeyPrefix, participantID, "_")
+}
+
+// Bound of a range query on the index, no date is the start or end of the index
+func walletStatementBound(participantID int, date *time.Time, end bool) string {
+	if date == nil {
+		if end {
+			return walletStatementPrefix(participantID) + "~"
+		}
+		return walletStatementPrefix(participantID)
+	}
+	return walletStatementPrefix(participantID) + date.UTC().Format(walletStatementDateLayout)
+}
+
+func walletStatementKey(participantID int, transaction models.BitcircleTransaction) string {
+	id := string(transaction.ID)
+	// Numbered transactions are padded to keep their order within the same date
+	if strings.Trim(id, "0123456789") == "" {
+		id = fmt.Sprintf("%012s", id)
+	}
+	return fmt.Sprint(walletStatementBound(participantID, &transaction.Date, false), "_", id)
+}
+
+// Participants of the transaction with a wallet, the supply has none
+func walletStatementParticipants(transaction models.BitcircleTransaction) []int {
+	participantIDs := []int{}
+	for _, participantID := range []int{transaction.SenderParticipantId, transaction.ReceiverParticipantId} {
+		if participantID == SupplyParticipantId || (len(participantIDs) > 0 && participantIDs[0] == participantID) {
+			continue
+		}
+		participantIDs = append(participantIDs, participantID)
+	}
+	return participantIDs
+}
+
+// Change of the participant balance by the transaction, and the other participant.
+// Escrow movements stay on the wallet and do not change its balance.
+func walletStatementChange(participantID int, transaction models.BitcircleTransaction) (int, int) {
+	change := 0
+	counterpartyID := participantID
+	for _, line := range getLedgerLines(transaction) {
+		if line.ParticipantID == participantID {
+			change = change + line.Credit - line.Debit
+		} else {
+			counterpartyID = line.ParticipantID
+		}
+	}
+	return change, counterpartyID
+}
+
+func (s *AuctionSmartContract) putWalletStatementIndex(stub shim.ChaincodeStubInterface, participantID int, transaction models.BitcircleTransaction, balanceAfter int) error {
+	dataIndex, err := json.Marshal(walletStatementIndex{TransactionID: transaction.ID, BalanceAfter: balanceAfter})
+	if err != nil {
+		return err
+	}
+
+	return stub.PutState(walletStatementKey(participantID, transaction), dataIndex)
+}
+
+// Indexes a transaction posted by postBitcircles, its wallets are already on the
+// book of the current transaction with their balance after it
+func (s *AuctionSmartContract) indexBitcircleTransaction(stub shim.ChaincodeStubInterface, transaction models.BitcircleTransaction) error {
+	for _, participantID := range walletStatementParticipants(transaction) {
+		book, err := s.getBookWallet(stub, participantID)
+		if err != nil {
+			return err
+		}
+
+		err = s.putWalletStatementIndex(stub, participantID, transaction, book.wallet.Balance)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Transactions of the index entries read by the iterator, with the entries
+func (s *AuctionSmartContract) readWalletTransactions(stub shim.ChaincodeStubInterface, iterator shim.StateQueryIteratorInterface) ([]models.BitcircleTransaction, []walletStatementIndex, error) {
+	transactions := []models.BitcircleTransaction{}
+	indexes := []walletStatementIndex{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var index walletStatementIndex
+		err = json.Unmarshal(response.Value, &index)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transactionKey, err := s.CreateCompositeKey(stub, EntityBitcircleTransaction, []string{string(index.TransactionID)})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transactionJSON, err := s.ReadEntity(stub, transactionKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var transaction models.BitcircleTransaction
+		err = json.Unmarshal(transactionJSON, &transaction)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		transactions = append(transactions, transaction)
+		indexes = append(indexes, index)
+	}
+
+	return transactions, indexes, nil
+}
+
+func (s *AuctionSmartContract) readWalletStatementEntries(stub shim.ChaincodeStubInterface, participantID int, iterator shim.StateQueryIteratorInterface) ([]walletStatementEntry, error) {
+	transactions, indexes, err := s.readWalletTransactions(stub, iterator)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []walletStatementEntry{}
+	for i, transaction := range transactions {
+		change, counterpartyID := walletStatementChange(participantID, transaction)
+		entry := walletStatementEntry{
+			TransactionID:   transaction.ID,
+			Date:            transaction.Date,
+			Direction:       StatementInternal,
+			CounterpartyID:  counterpartyID,
+			BitcircleAmount: transaction.BitcircleAmount,
+			Reason:          transaction.Reason,
+			Description:     transaction.Description,
+			IsReward:        transaction.IsReward,
+			BalanceAfter:    indexes[i].BalanceAfter,
+			change:          change,
+		}
+		if change > 0 {
+			entry.Direction = StatementIn
+		}
+		if change < 0 {
+			entry.Direction = StatementOut
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Balance of the wallet before the index key: the one before the next entry, or
+// the current balance when there is none
+func (s *AuctionSmartContract) getWalletBalanceBefore(stub shim.ChaincodeStubInterface, wallet models.Wallet, key string) (int, error) {
+	iterator, _, err := stub.GetStateByRangeWithPagination(key, walletStatementBound(wallet.ParticipantId, nil, true), 1, "")
+	if err != nil {
+		return 0, err
+	}
+	defer iterator.Close()
+
+	entries, err := s.readWalletStatementEntries(stub, wallet.ParticipantId, iterator)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return wallet.Balance, nil
+	}
+	return entries[0].BalanceAfter - entries[0].change, nil
+}
+
+// Transactions of the wallet from (inclusive) to (exclusive), oldest first, a
+// page at a time. The opening and closing balances are those of the page.
+func (s *AuctionSmartContract) GetWalletStatement(stub shim.ChaincodeStubInterface, participantID int, from *time.Time, to *time.Time, pageSize int, bookmark string) pb.Response {
+	if pageSize == 0 {
+		pageSize = DefaultStatementPageSize
+	}
+	if pageSize < 0 || pageSize > MaxStatementPageSize {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("Page size between 1 and ", MaxStatementPageSize)))
+	}
+
+	if from != nil && to != nil && !to.After(*from) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The end of the statement most be after its start"))
+	}
+
+	if bookmark != "" && !strings.HasPrefix(bookmark, walletStatementPrefix(participantID)) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Invalid bookmark"))
+	}
+
+	wallet, _, err := s.GetWallet(stub, participantID)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+	}
+
+	startKey := walletStatementBound(participantID, from, false)
+	endKey := walletStatementBound(participantID, to, true)
+
+	iterator, metadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, int32(pageSize), bookmark)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	defer iterator.Close()
+
+	entries, err := s.readWalletStatementEntries(stub, participantID, iterator)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var response struct {
+		ParticipantID  int                    `json:"participant_id"`
+		From           *time.Time             `json:"from,omitempty"`
+		To             *time.Time             `json:"to,omitempty"`
+		OpeningBalance int                    `json:"opening_balance"`
+		ClosingBalance int                    `json:"closing_balance"`
+		Entries        []walletStatementEntry `json:"entries"`
+		Bookmark       string                 `json:"bookmark,omitempty"`
+	}
+	response.ParticipantID = participantID
+	response.From = from
+	response.To = to
+	response.Entries = entries
+
+	if len(entries) > 0 {
+		response.OpeningBalance = entries[0].BalanceAfter - entries[0].change
+		response.ClosingBalance = entries[len(entries)-1].BalanceAfter
+	} else {
+		response.OpeningBalance, err = s.getWalletBalanceBefore(stub, wallet, endKey)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+		}
+		response.ClosingBalance = response.OpeningBalance
+	}
+
+	// A full page may be followed by more entries
+	if len(entries) == pageSize {
+		response.Bookmark = metadata.Bookmark
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// Adds the transactions recorded before the statements to the index. The balances
+// are computed backwards from the current balance of each wallet.
+func (s *AuctionSmartContract) IndexBitcircleTransactions(stub shim.ChaincodeStubInterface, participantID int) pb.Response {
+	fmt.Println("IndexBitcircleTransactions Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can index the Bitcircle transactions"))
+	}
+
+	transactions, err := GetAllBitCircleTransactions(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	walletTransactions := map[int][]models.BitcircleTransaction{}
+	for _, transaction := range transactions {
+		for _, walletID := range walletStatementParticipants(transaction) {
+			walletTransactions[walletID] = append(walletTransactions[walletID], transaction)
+		}
+	}
+
+	walletIDs := []int{}
+	for walletID := range walletTransactions {
+		walletIDs = append(walletIDs, walletID)
+	}
+	sort.Ints(walletIDs)
+
+	for _, walletID := range walletIDs {
+		wallet, _, err := s.GetWallet(stub, walletID)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusNotFound, err.Error()))
+		}
+
+		ordered := walletTransactions[walletID]
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return walletStatementKey(walletID, ordered[i]) < walletStatementKey(walletID, ordered[j])
+		})
+
+		balance := wallet.Balance
+		for i := len(ordered) - 1; i >= 0; i-- {
+			err = s.putWalletStatementIndex(stub, walletID, ordered[i], balance)
+			if err != nil {
+				return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+			}
+
+			change, _ := walletStatementChange(walletID, ordered[i])
+			balance = balance - change
+		}
+	}
+
+	var response struct {
+		Indexed int `json:"indexed"`
+	}
+	response.Indexed = len(transactions)
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** WALLET STATEMENT (Bitcircle transactions of a participant in time order)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,130 @@
+package micolec
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type walletStatement struct {
+	OpeningBalance int                    `json:"opening_balance"`
+	ClosingBalance int                    `json:"closing_balance"`
+	Entries        []walletStatementEntry `json:"entries"`
+	Bookmark       string                 `json:"bookmark"`
+}
+
+// Wallet 2 is granted 100 Bitcircles, sends 30 to wallet 3, gets 10 back and
+// reserves 5 for a bid. Returns the dates of the two transfers.
+func newStatementContract(t *testing.T) (*testContract, time.Time, time.Time) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+
+	c.ok(nil, "TransferBitcircles", "2", "3", "30", "false", "Shared delivery")
+	sent := c.ledger.now
+	c.ok(nil, "TransferBitcircles", "3", "2", "10", "false", "Change")
+	received := c.ledger.now
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "2")
+	return c, sent, received
+}
+
+func statementSummary(entries []walletStatementEntry) []string {
+	summary := []string{}
+	for _, entry := range entries {
+		summary = append(summary, fmt.Sprint(entry.Direction, " ", entry.BitcircleAmount, " ", entry.BalanceAfter))
+	}
+	return summary
+}
+
+func TestGetWalletStatement(t *testing.T) {
+	c, sent, received := newStatementContract(t)
+
+	tests := []struct {
+		name           string
+		from           string
+		to             string
+		entries        []string
+		openingBalance int
+		closingBalance int
+	}{
+		{"whole statement", "", "", []string{"IN 100 100", "OUT 30 70", "IN 10 80", "INTERNAL 5 80"}, 0, 80},
+		{"from the first transfer", sent.Format(time.RFC3339), "", []string{"OUT 30 70", "IN 10 80", "INTERNAL 5 80"}, 100, 80},
+		{"up to the second transfer", "", received.Format(time.RFC3339), []string{"IN 100 100", "OUT 30 70"}, 0, 70},
+		{"between the transfers", sent.Format(time.RFC3339), received.Format(time.RFC3339), []string{"OUT 30 70"}, 100, 70},
+		{"before the wallet", testStartTime.Add(-time.Hour).Format(time.RFC3339), testStartTime.Format(time.RFC3339), []string{}, 0, 0},
+		{"after the last transaction", c.ledger.now.Add(time.Hour).Format(time.RFC3339), "", []string{}, 80, 80},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var statement walletStatement
+			c.ok(&statement, "GetWalletStatement", "2", test.from, test.to)
+			require.Equal(t, test.entries, statementSummary(statement.Entries))
+			require.Equal(t, test.openingBalance, statement.OpeningBalance)
+			require.Equal(t, test.closingBalance, statement.ClosingBalance)
+			require.Empty(t, statement.Bookmark)
+		})
+	}
+
+	c.fails(http.StatusBadRequest, "GetWalletStatement", "2", "", "", fmt.Sprint(MaxStatementPageSize+1))
+	c.fails(http.StatusBadRequest, "GetWalletStatement", "2", received.Format(time.RFC3339), sent.Format(time.RFC3339))
+	c.fails(http.StatusBadRequest, "GetWalletStatement", "2", "", "", "2", walletStatementPrefix(3))
+	c.fails(http.StatusNotFound, "GetWalletStatement", "4")
+}
+
+func TestWalletStatementPages(t *testing.T) {
+	c, _, _ := newStatementContract(t)
+
+	pages := []struct {
+		entries        []string
+		openingBalance int
+		closingBalance int
+	}{
+		{[]string{"IN 100 100", "OUT 30 70"}, 0, 70},
+		{[]string{"IN 10 80", "INTERNAL 5 80"}, 70, 80},
+	}
+
+	bookmark := ""
+	for i, page := range pages {
+		require.True(t, i == 0 || bookmark != "", "page %d", i)
+		var statement walletStatement
+		c.ok(&statement, "GetWalletStatement", "2", "", "", "2", bookmark)
+		require.Equal(t, page.entries, statementSummary(statement.Entries), "page %d", i)
+		require.Equal(t, page.openingBalance, statement.OpeningBalance, "page %d", i)
+		require.Equal(t, page.closingBalance, statement.ClosingBalance, "page %d", i)
+		bookmark = statement.Bookmark
+	}
+
+	// The last page ends the index and has no bookmark
+	require.Empty(t, bookmark)
+}
+
+func TestIndexBitcircleTransactions(t *testing.T) {
+	c, _, _ := newStatementContract(t)
+
+	var indexed walletStatement
+	c.ok(&indexed, "GetWalletStatement", "2")
+
+	// Transactions recorded before the statements have no index
+	c.stub.MockTransactionStart("unindexed")
+	for key := range c.stub.State {
+		if strings.HasPrefix(key, walletStatementKeyPrefix) {
+			require.NoError(t, c.stub.DelState(key))
+		}
+	}
+	c.stub.MockTransactionEnd("unindexed")
+
+	var statement walletStatement
+	c.ok(&statement, "GetWalletStatement", "2")
+	require.Empty(t, statement.Entries)
+
+	c.as(2).fails(http.StatusForbidden, "IndexBitcircleTransactions", "2")
+	c.as(PlatformWalletId).ok(nil, "IndexBitcircleTransactions", "0")
+	c.ok(&statement, "GetWalletStatement", "2")
+	require.Equal(t, statementSummary(indexed.Entries), statementSummary(statement.Entries))
+	require.Equal(t, indexed.OpeningBalance, statement.OpeningBalance)
+	require.Equal(t, indexed.ClosingBalance, statement.ClosingBalance)
+}
@@ -0,0 +1,243 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** BITCIRCLE SUPPLY (treasury, mint and burn)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["MintBitcircles", "0", "10000", "Initial supply"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["BurnBitcircles", "0", "500", "Unused promotion"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["CreateParticipantWallet", "{\"participant_id\":4}", "100"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode query -C ch1 -n mycc -c '{"Args":["GetBitcircleSupply"]}'
+*/
+
+// Counterpart of the treasury on the transactions of minted and burned Bitcircles
+const SupplyParticipantId = -1
+
+// The treasury is the platform wallet, created on Init
+func (s *AuctionSmartContract) initTreasury(stub shim.ChaincodeStubInterface) error {
+	treasuryKey, err := s.CreateCompositeKey(stub, EntityWallet, []string{fmt.Sprint(PlatformWalletId)})
+	if err != nil {
+		return err
+	}
+
+	treasuryExists, err := s.EntityRecordExists(stub, treasuryKey)
+	if err != nil || treasuryExists {
+		return err
+	}
+
+	dataTreasury, err := json.Marshal(models.Wallet{ParticipantId: PlatformWalletId})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, treasuryKey, dataTreasury)
+	return err
+}
+
+func (s *AuctionSmartContract) getBitcircleSupply(stub shim.ChaincodeStubInterface) (models.BitcircleSupply, string, error) {
+	var supply models.BitcircleSupply
+
+	supplyKey, err := s.CreateCompositeKey(stub, EntityBitcircleSupply, []string{"CURRENT"})
+	if err != nil {
+		return supply, supplyKey, err
+	}
+
+	supplyJSON, err := stub.GetState(supplyKey)
+	if err != nil || supplyJSON == nil {
+		return supply, supplyKey, err
+	}
+
+	err = json.Unmarshal(supplyJSON, &supply)
+	return supply, supplyKey, err
+}
+
+// Mints (positive amount) or burns (negative amount) Bitcircles on the treasury.
+// The ledger entry is posted before the supply is written, so an error fails the
+// transaction.
+func (s *AuctionSmartContract) updateBitcircleSupply(stub shim.ChaincodeStubInterface, bitcircleAmount int, description string) (models.Wallet, error) {
+	treasury, err := s.getBookWallet(stub, PlatformWalletId)
+	if err != nil {
+		return models.Wallet{}, err
+	}
+
+	supply, supplyKey, err := s.getBitcircleSupply(stub)
+	if err != nil {
+		return treasury.wallet, err
+	}
+
+	supply.UpdatedAt, err = getTxTime(stub)
+	if err != nil {
+		return treasury.wallet, err
+	}
+
+	if bitcircleAmount > 0 {
+		supply.Minted = supply.Minted + bitcircleAmount
+		err = s.postBitcircles(stub, models.LedgerReasonMint, supplyAccount, availableAccount(PlatformWalletId), bitcircleAmount, LedgerReferenceSupply, "CURRENT", description)
+	} else {
+		supply.Burned = supply.Burned - bitcircleAmount
+		err = s.postBitcircles(stub, models.LedgerReasonBurn, availableAccount(PlatformWalletId), supplyAccount, -bitcircleAmount, LedgerReferenceSupply, "CURRENT", description)
+	}
+	if err != nil {
+		return treasury.wallet, err
+	}
+
+	dataSupply, err := json.Marshal(supply)
+	if err != nil {
+		return treasury.wallet, err
+	}
+
+	_, err = s.UpsertEntityRecord(stub, supplyKey, dataSupply)
+	return treasury.wallet, err
+}
+
+// Grants come out of the treasury usable balance
+func (s *AuctionSmartContract) checkTreasuryGrant(stub shim.ChaincodeStubInterface, bitcircleAmount int) error {
+	treasury, err := s.getBookWallet(stub, PlatformWalletId)
+	if err != nil {
+		return err
+	}
+
+	if treasury.wallet.UsableBalance < bitcircleAmount {
+		return fmt.Errorf("The treasury only has %d usable Bitcircles to grant", treasury.wallet.UsableBalance)
+	}
+	return nil
+}
+
+// Moves the grant of a new wallet out of the treasury
+func (s *AuctionSmartContract) grantFromTreasury(stub shim.ChaincodeStubInterface, participantID int, bitcircleAmount int) error {
+	err := s.checkTreasuryGrant(stub, bitcircleAmount)
+	if err != nil {
+		return err
+	}
+
+	description := fmt.Sprint("Wallet grant to participant ", participantID)
+	return s.postBitcircles(stub, models.LedgerReasonGrant, availableAccount(PlatformWalletId), availableAccount(participantID), bitcircleAmount, LedgerReferenceWallet, fmt.Sprint(participantID), description)
+}
+
+func (s *AuctionSmartContract) MintBitcircles(stub shim.ChaincodeStubInterface, participantID int, bitcircleAmount int, description string) pb.Response {
+	fmt.Println("MintBitcircles Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can mint Bitcircles"))
+	}
+
+	if !(bitcircleAmount > 0) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Bitcircle amount most be Higher than 0"))
+	}
+
+	if description == "" {
+		description = "Bitcircles minted"
+	}
+
+	treasury, err := s.updateBitcircleSupply(stub, bitcircleAmount, description)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	treasuryJSON, err := json.Marshal(treasury)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(treasuryJSON)
+}
+
+// Only the usable Bitcircles of the treasury can be burned
+func (s *AuctionSmartContract) BurnBitcircles(stub shim.ChaincodeStubInterface, participantID int, bitcircleAmount int, description string) pb.Response {
+	fmt.Println("BurnBitcircles Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can burn Bitcircles"))
+	}
+
+	if !(bitcircleAmount > 0) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Bitcircle amount most be Higher than 0"))
+	}
+
+	if description == "" {
+		description = "Bitcircles burned"
+	}
+
+	book, err := s.getBookWallet(stub, PlatformWalletId)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if book.wallet.UsableBalance < bitcircleAmount {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("The treasury only has %d usable Bitcircles to burn", book.wallet.UsableBalance)))
+	}
+
+	treasury, err := s.updateBitcircleSupply(stub, -bitcircleAmount, description)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	treasuryJSON, err := json.Marshal(treasury)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+
+	return shim.Success(treasuryJSON)
+}
+
+// Outstanding Bitcircles (minted minus burned) are either on the treasury or
+// circulating on the participant wallets, part of them escrowed by bids.
+// Unbacked are the balances that were never minted, from wallets created with
+// a balance before the supply was managed.
+func (s *AuctionSmartContract) GetBitcircleSupply(stub shim.ChaincodeStubInterface) pb.Response {
+	supply, _, err := s.getBitcircleSupply(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	wallets, err := GetWallets(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	var response struct {
+		Minted      int `json:"minted"`
+		Burned      int `json:"burned"`
+		Outstanding int `json:"outstanding"`
+		Treasury    int `json:"treasury"`
+		Circulating int `json:"circulating"`
+		Escrowed    int `json:"escrowed"`
+		Unbacked    int `json:"unbacked"`
+		Wallets     int `json:"wallets"`
+	}
+	response.Minted = supply.Minted
+	response.Burned = supply.Burned
+	response.Outstanding = supply.Minted - supply.Burned
+
+	for _, wallet := range wallets {
+		response.Escrowed = response.Escrowed + wallet.Escrowed
+		if wallet.ParticipantId == PlatformWalletId {
+			response.Treasury = wallet.Balance
+			continue
+		}
+		response.Circulating = response.Circulating + wallet.Balance
+		response.Wallets = response.Wallets + 1
+	}
+	response.Unbacked = response.Treasury + response.Circulating - response.Outstanding
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
+}
+
+// ** -----------------------------------------------------
+// ** BITCIRCLE SUPPLY (treasury, mint and burn)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,128 @@
+package micolec
+
+import (
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type bitcircleSupply struct {
+	Minted      int `json:"minted"`
+	Burned      int `json:"burned"`
+	Outstanding int `json:"outstanding"`
+	Treasury    int `json:"treasury"`
+	Circulating int `json:"circulating"`
+	Escrowed    int `json:"escrowed"`
+	Unbacked    int `json:"unbacked"`
+	Wallets     int `json:"wallets"`
+}
+
+func TestMintAndBurnBitcircles(t *testing.T) {
+	tests := []struct {
+		name          string
+		function      string
+		caller        func(c *testContract) *testContract
+		participantID string
+		amount        string
+		code          int
+		treasury      int
+	}{
+		{"mint by the platform", "MintBitcircles", nil, "0", "500", 0, 1500},
+		{"burn by the platform", "BurnBitcircles", nil, "0", "500", 0, 500},
+		{"burn the whole treasury", "BurnBitcircles", nil, "0", "1000", 0, 0},
+		{"mint by another participant", "MintBitcircles", func(c *testContract) *testContract { return c.as(2) }, "2", "500", http.StatusForbidden, 1000},
+		{"mint signed by another participant", "MintBitcircles", func(c *testContract) *testContract { return c.as(2) }, "0", "500", http.StatusForbidden, 1000},
+		{"burn signed by another participant", "BurnBitcircles", func(c *testContract) *testContract { return c.as(2) }, "0", "500", http.StatusForbidden, 1000},
+		{"mint without identity", "MintBitcircles", func(c *testContract) *testContract { return c.anonymous() }, "0", "500", http.StatusForbidden, 1000},
+		{"mint nothing", "MintBitcircles", nil, "0", "0", http.StatusBadRequest, 1000},
+		{"burn more than the treasury", "BurnBitcircles", nil, "0", "1001", http.StatusBadRequest, 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100})
+			caller := c
+			if test.caller != nil {
+				caller = test.caller(c)
+			}
+
+			if test.code != 0 {
+				caller.fails(test.code, test.function, test.participantID, test.amount)
+			} else {
+				var treasury models.Wallet
+				caller.ok(&treasury, test.function, test.participantID, test.amount)
+				require.Equal(t, test.treasury, treasury.Balance)
+			}
+
+			var supply bitcircleSupply
+			c.as(PlatformWalletId).ok(&supply, "GetBitcircleSupply")
+			require.Equal(t, test.treasury, supply.Treasury)
+			require.Equal(t, 100, supply.Circulating)
+			require.Equal(t, supply.Treasury+supply.Circulating, supply.Outstanding)
+			require.Zero(t, supply.Unbacked)
+		})
+	}
+}
+
+func TestCreateParticipantWallet(t *testing.T) {
+	tests := []struct {
+		name     string
+		callerID int
+		wallet   string
+		grant    string
+		code     int
+		balance  int
+	}{
+		{"granted by the platform", PlatformWalletId, `{"participant_id": 4}`, "100", 0, 100},
+		{"without grant", 4, `{"participant_id": 4}`, "0", 0, 0},
+		{"granted by the participant itself", 4, `{"participant_id": 4}`, "100", http.StatusForbidden, 0},
+		{"grant above the treasury", PlatformWalletId, `{"participant_id": 4}`, "1001", http.StatusBadRequest, 0},
+		{"negative grant", PlatformWalletId, `{"participant_id": 4}`, "-1", http.StatusBadRequest, 0},
+		{"with a balance", PlatformWalletId, `{"participant_id": 4, "balance": 10}`, "0", http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t)
+			c.ok(nil, "MintBitcircles", "0", "1000")
+
+			if test.code != 0 {
+				c.as(test.callerID).fails(test.code, "CreateParticipantWallet", test.wallet, test.grant)
+
+				// Nothing is written, the wallet can be created again
+				c.as(PlatformWalletId).ok(nil, "CreateParticipantWallet", `{"participant_id": 4}`, "10")
+				require.Equal(t, 10, c.wallet(4).Balance)
+				require.Equal(t, 990, c.wallet(PlatformWalletId).Balance)
+				return
+			}
+
+			var wallet models.Wallet
+			c.as(test.callerID).ok(&wallet, "CreateParticipantWallet", test.wallet, test.grant)
+			require.Equal(t, test.balance, wallet.Balance)
+			require.Equal(t, test.balance, c.wallet(4).Balance)
+			require.Equal(t, 1000-test.balance, c.wallet(PlatformWalletId).Balance)
+		})
+	}
+}
+
+func TestGetBitcircleSupply(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100}).parcel(1, nil)
+	c.auction("A1", []int{1}, nil)
+	c.ok(nil, "ParcelDeliveryBidingRequest", "b1", "A1", "90.00", "5", "3")
+	c.ok(nil, "BurnBitcircles", "0", "300", "Unused promotion")
+
+	var supply bitcircleSupply
+	c.ok(&supply, "GetBitcircleSupply")
+	require.Equal(t, bitcircleSupply{
+		Minted:      1200,
+		Burned:      300,
+		Outstanding: 900,
+		Treasury:    700,
+		Circulating: 200,
+		Escrowed:    5,
+		Unbacked:    0,
+		Wallets:     2,
+	}, supply)
+}
@@ -56,7 +56,8 @@ func (s *AuctionSmartContract) GetParticipantWallet(stub shim.ChaincodeStubInter
 	return wallet, nil
 }
 
-func (s *AuctionSmartContract) CreateParticipantWallet(stub shim.ChaincodeStubInterface, wallet models.Wallet) pb.Response {
+// Wallets start at zero, or with a grant from the treasury
+func (s *AuctionSmartContract) CreateParticipantWallet(stub shim.ChaincodeStubInterface, wallet models.Wallet, grant int) pb.Response {
 	fmt.Println("CreateParticipantWallet Invoke")
 	walletKey, err := s.CreateCompositeKey(stub, EntityWallet, []string{fmt.Sprint(wallet.ParticipantId)})
 	if err != nil {
@@ -70,6 +71,28 @@ func (s *AuctionSmartContract) CreateParticipantWallet(stub shim.ChaincodeStubIn
 		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprintf("This Participant (%d) already has a wallet", wallet.ParticipantId)))
 	}
 
+	if wallet.Balance != 0 || wallet.UsableBalance != 0 || wallet.Escrowed != 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Wallets start with a zero balance, the Bitcircles are granted by the treasury"))
+	}
+
+	if grant < 0 {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Grant most be Higher Equal 0"))
+	}
+
+	// Only the platform grants Bitcircles out of the treasury, and only what the
+	// treasury can pay: the grant is checked before the wallet is written
+	if grant > 0 {
+		err = checkCaller(stub, PlatformWalletId)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can grant Bitcircles to a new wallet"))
+		}
+
+		err = s.checkTreasuryGrant(stub, grant)
+		if err != nil {
+			return shim.Success(createErrorResponse(http.StatusBadRequest, err.Error()))
+		}
+	}
+
 	walletJson, err := json.Marshal(wallet)
 	if err != nil {
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
@@ -80,6 +103,24 @@ func (s *AuctionSmartContract) CreateParticipantWallet(stub shim.ChaincodeStubIn
 		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
 	}
 
+	if grant > 0 {
+		s.addBookWallet(stub, wallet, walletKey)
+		err = s.grantFromTreasury(stub, wallet.ParticipantId, grant)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		book, err := s.getBookWallet(stub, wallet.ParticipantId)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+
+		walletJson, err = json.Marshal(book.wallet)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+	}
+
 	return shim.Success(walletJson)
 }
 
@@ -98,7 +139,53 @@ func (s *AuctionSmartContract) GetParticipantWalletById(stub shim.ChaincodeStubI
 		return shim.Success(createErrorResponse(http.StatusNotFound, "Participant wallet not found"))
 	}
 
-	return shim.Success(walletJson)
+	config, err := s.getPlatformConfig(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	// Rewards are the Bitcircles of the open reward lots, part of the balance
+	var response struct {
+		models.Wallet
+		Rewards           int                  `json:"rewards"`
+		ExpiringSoon      int                  `json:"expiring_soon"`
+		ExpiringSoonUntil time.Time            `json:"expiring_soon_until"`
+		Frozen            *models.WalletFreeze `json:"frozen,omitempty"`
+		Held              int                  `json:"held"`
+		Holds             []models.WalletHold  `json:"holds"`
+	}
+	err = json.Unmarshal(walletJson, &response.Wallet)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response.ExpiringSoonUntil = txTime.AddDate(0, 0, config.RewardExpiringSoonDays)
+	response.Rewards, response.ExpiringSoon, err = getRewardLotTotals(stub, participantId, response.ExpiringSoonUntil)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response.Frozen, err = getWalletFreeze(stub, participantId)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	response.Holds, response.Held, err = getActiveWalletHolds(stub, participantId)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(responseJSON)
 }
 
 // func (s *AuctionSmartContract) UpdateBitcircles(stub shim.ChaincodeStubInterface, participantId int, bitcircleAmount int) error {
@@ -146,105 +233,18 @@ func (s *AuctionSmartContract) GetParticipantWalletById(stub shim.ChaincodeStubI
 // 	return nil
 // }
 
-func (s *AuctionSmartContract) RefundBitcirclesForAuction(stub shim.ChaincodeStubInterface, participantId int, bitcircleAmmount int, moneyAmount float32, auctionId string, isReward bool) error {
-	fmt.Println("Refound Bitcircles")
-	walletKey, err := s.CreateCompositeKey(stub, EntityWallet, []string{fmt.Sprint(participantId)})
-	if err != nil {
-		return err
-	}
-	walletJson, err := s.ReadEntity(stub, walletKey)
-	if err != nil {
-		return err
-	}
-	if walletJson == nil {
-		return fmt.Errorf("Wallet not found")
-	}
-
-	var wallet models.Wallet
-	err = json.Unmarshal(walletJson, &wallet)
-	if err != nil {
-		return err
-	}
-
-	wallet.UsableBalance = wallet.UsableBalance + bitcircleAmmount
-
-	dataWallet, err := json.Marshal(wallet)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.UpsertEntityRecord(stub, walletKey, dataWallet)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func (s *AuctionSmartContract) ReserveBitcirclesForBid(stub shim.ChaincodeStubInterface, participantId int, bitcircleAmmount int, moneyAmount float32, auctionId string, isReward bool) error {
-	fmt.Println("PayBitcircles")
-	walletKey, err := s.CreateCompositeKey(stub, EntityWallet, []string{fmt.Sprint(participantId)})
-	if err != nil {
-		return err
-	}
-	walletJson, err := s.ReadEntity(stub, walletKey)
-	if err != nil {
-		return err
-	}
-	if walletJson == nil {
-		return fmt.Errorf("Wallet not found")
-	}
-
-	var wallet models.Wallet
-	err = json.Unmarshal(walletJson, &wallet)
-	if err != nil {
-		return err
-	}
-
-	if wallet.UsableBalance < bitcircleAmmount {
-		return errors.New("Insufficient balance on your wallet")
-	}
-
-	wallet.UsableBalance = wallet.UsableBalance - bitcircleAmmount
-
-	dataWallet, err := json.Marshal(wallet)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.UpsertEntityRecord(stub, walletKey, dataWallet)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (s *AuctionSmartContract) VerifyWalletAmount(stub shim.ChaincodeStubInterface, participantId int, bitcircleAmount int) error {
 	fmt.Println("Verify if wallet have the ammount")
-	walletKey, err := s.CreateCompositeKey(stub, EntityWallet, []string{fmt.Sprint(participantId)})
-	if err != nil {
-		return err
-	}
-	walletJson, err := s.ReadEntity(stub, walletKey)
-	if err != nil {
-		return err
-	}
-	if walletJson == nil {
-		return fmt.Errorf("Wallet not found")
-	}
-
-	var wallet models.Wallet
-	err = json.Unmarshal(walletJson, &wallet)
+	book, err := s.getBookWallet(stub, participantId)
 	if err != nil {
 		return err
 	}
 
-	if wallet.UsableBalance < bitcircleAmount {
+	if book.wallet.UsableBalance < bitcircleAmount {
 		return errors.New("Insufficient balance on your wallet")
 	}
 
-	return nil
+	return checkWalletSpendable(stub, book.wallet, bitcircleAmount)
 }
 
 func (s *AuctionSmartContract) GetWallet(stub shim.ChaincodeStubInterface, participantIdd int) (models.Wallet, string, error) {
@@ -294,13 +294,16 @@ func GetTransactionId(stub shim.ChaincodeStubInterface) (int, error) {
 	return len(transactions) + 1, nil
 }
 
+// The isReward argument is only echoed back: delivery rewards are paid by the
+// delivery flows, a transfer between wallets never creates reward lots
 func (s *AuctionSmartContract) TransferBitcircles(stub shim.ChaincodeStubInterface, senderParticipantId int, receiverParticipantId int, bitcircleAmmount int, isReward bool, description string) pb.Response {
 	err := s.VerifyWalletAmount(stub, senderParticipantId, bitcircleAmmount)
 	if err != nil {
 		return shim.Success(createErrorResponse(500, err.Error()))
 	}
 
-	err = s.TransferBitcirclesBetweenWallets(stub, senderParticipantId, receiverParticipantId, bitcircleAmmount, isReward, description)
+	// Direct transfers are paid from the usable balance, only captureEscrow spends reserved Bitcircles
+	err = s.postBitcircles(stub, models.LedgerReasonTransfer, availableAccount(senderParticipantId), availableAccount(receiverParticipantId), bitcircleAmmount, LedgerReferenceWallet, fmt.Sprint(receiverParticipantId), description)
 	if err != nil {
 		return shim.Success(createErrorResponse(500, err.Error()))
 	}
@@ -327,92 +330,31 @@ func (s *AuctionSmartContract) TransferBitcircles(stub shim.ChaincodeStubInterfa
 	return shim.Success(resJSON)
 }
 
-func (s *AuctionSmartContract) TransferBitcirclesBetweenWallets(stub shim.ChaincodeStubInterface, senderParticipantId int, receiverParticipantId int, bitcircleAmmount int, isReward bool, description string) error {
-	fmt.Println("PayBitcircles")
-
-	transactionError := false
-
-	// Start a new transaction
-	transactionId, err := s.StartTransaction(stub)
-	if err != nil {
-		return err
-	}
-	// Roolback in case of error Or Commit in case of success
-	defer s.CloseTransaction(stub, transactionId, transactionError)
-
-	senderWallet, senderWalletKey, err := s.GetWallet(stub, senderParticipantId)
-	if err != nil {
-		return err
-	}
-
-	receiverWallet, receiverWalletKey, err := s.GetWallet(stub, receiverParticipantId)
-	if err != nil {
-		return err
-	}
-
-	currentTime := time.Now()
-	currentDate := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), 0, 0, 0, 0, time.UTC)
-
-	senderWallet.Balance = senderWallet.Balance - bitcircleAmmount
-	if isReward {
-		senderWallet.UsableBalance = senderWallet.UsableBalance - bitcircleAmmount
-	}
-	senderWallet.LastMovement = currentDate
-
-	receiverWallet.Balance = receiverWallet.Balance + bitcircleAmmount
-	receiverWallet.UsableBalance = receiverWallet.UsableBalance + bitcircleAmmount
-	receiverWallet.LastMovement = currentDate
-
-	var bitcircletransaction models.BitcircleTransaction
-	// transactionId, err := GetTransactionId(stub)
-	// if err != nil {
-	// 	return err
-	// }
-
-	Bitcircle_Transaction_ID = Bitcircle_Transaction_ID + 1
-
-	bitcircletransaction.ID = Bitcircle_Transaction_ID
-	bitcircletransaction.BitcircleAmount = bitcircleAmmount
-	bitcircletransaction.SenderParticipantId = senderParticipantId
-	bitcircletransaction.ReceiverParticipantId = receiverParticipantId
-	bitcircletransaction.Date = currentDate
-	bitcircletransaction.Description = description
+func (s *AuctionSmartContract) putBitcircleTransaction(stub shim.ChaincodeStubInterface, bitcircletransaction models.BitcircleTransaction) (models.BitcircleTransaction, error) {
+	// Unique and the same on every endorsing peer
+	sequence := 0
+	withTxScope(stub, func(scope *txScope) {
+		scope.bitcircleTransactions = scope.bitcircleTransactions + 1
+		sequence = scope.bitcircleTransactions
+	})
+	bitcircletransaction.ID = models.BitcircleTransactionID(fmt.Sprintf("%s-%03d", stub.GetTxID(), sequence))
 
 	bitcircleTransactionKey, err := s.CreateCompositeKey(stub, EntityBitcircleTransaction, []string{fmt.Sprint(bitcircletransaction.ID)})
 	if err != nil {
-		return err
+		return bitcircletransaction, err
 	}
 
 	dataBitcircleTransaction, err := json.Marshal(bitcircletransaction)
 	if err != nil {
-		return err
-	}
-	_, err = s.UpsertEntityRecord(stub, bitcircleTransactionKey, dataBitcircleTransaction)
-	if err != nil {
-		return err
-	}
-
-	dataSenderWallet, err := json.Marshal(senderWallet)
-	if err != nil {
-		return err
-	}
-
-	_, err = s.UpsertEntityRecord(stub, senderWalletKey, dataSenderWallet)
-	if err != nil {
-		return err
-	}
-
-	dataReceiverWallet, err := json.Marshal(receiverWallet)
-	if err != nil {
-		return err
+		return bitcircletransaction, err
 	}
 
-	_, err = s.UpsertEntityRecord(stub, receiverWalletKey, dataReceiverWallet)
+	_, err = s.UpsertEntityRecord(stub, bitcircleTransactionKey, dataBitcircleTransaction)
 	if err != nil {
-		return err
+		return bitcircletransaction, err
 	}
 
-	return nil
+	return bitcircletransaction, s.indexBitcircleTransaction(stub, bitcircletransaction)
 }
 
 func GetAllBitCircleTransactions(stub shim.ChaincodeStubInterface) ([]models.BitcircleTransaction, error) {
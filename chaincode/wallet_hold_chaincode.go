@@ -0,0 +1,329 @@
+package micolec
+
+import (
+	"encoding/json"
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ** -----------------------------------------------------
+// ** WALLET HOLDS (freezes and dispute holds set by the platform)
+// ** -> START
+// ** -----------------------------------------------------
+
+/*
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["FreezeWallet", "0", "2", "Compromised account"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["UnfreezeWallet", "0", "2"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["PlaceWalletHold", "0", "2", "30", "Dispute on parcel 12", "2022-06-01T00:00:00Z"]}'
+CORE_PEER_ADDRESS=127.0.0.1:7051 peer chaincode invoke -o 127.0.0.1:7050 -C ch1 -n mycc -c '{"Args":["ReleaseWalletHold", "0", "2", "<hold id>"]}'
+*/
+
+func holdIsActive(hold models.WalletHold, at time.Time) bool {
+	return hold.State == models.WalletHoldActive && (hold.ExpiresAt == nil || hold.ExpiresAt.After(at))
+}
+
+func getWalletFreeze(stub shim.ChaincodeStubInterface, participantID int) (*models.WalletFreeze, error) {
+	freezeKey, err := stub.CreateCompositeKey(string(EntityWalletFreeze), []string{fmt.Sprint(participantID)})
+	if err != nil {
+		return nil, err
+	}
+
+	freezeJSON, err := stub.GetState(freezeKey)
+	if err != nil || freezeJSON == nil {
+		return nil, err
+	}
+
+	var freeze models.WalletFreeze
+	err = json.Unmarshal(freezeJSON, &freeze)
+	return &freeze, err
+}
+
+func getWalletHolds(stub shim.ChaincodeStubInterface, participantID int) ([]models.WalletHold, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(string(EntityWalletHold), []string{fmt.Sprint(participantID)})
+	if err != nil {
+		return nil, err
+	}
+	defer iterator.Close()
+
+	holds := []models.WalletHold{}
+	for iterator.HasNext() {
+		response, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var hold models.WalletHold
+		err = json.Unmarshal(response.Value, &hold)
+		if err != nil {
+			return nil, err
+		}
+
+		holds = append(holds, hold)
+	}
+
+	return holds, nil
+}
+
+// Active holds of the wallet and the Bitcircles they keep
+func getActiveWalletHolds(stub shim.ChaincodeStubInterface, participantID int) ([]models.WalletHold, int, error) {
+	txTime, err := getTxTime(stub)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	holds, err := getWalletHolds(stub, participantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	activeHolds := []models.WalletHold{}
+	held := 0
+	for _, hold := range holds {
+		if holdIsActive(hold, txTime) {
+			activeHolds = append(activeHolds, hold)
+			held = held + hold.BitcircleAmount
+		}
+	}
+
+	return activeHolds, held, nil
+}
+
+// Returns an error when the wallet is frozen or the amount is not free of holds
+func checkWalletSpendable(stub shim.ChaincodeStubInterface, wallet models.Wallet, bitcircleAmount int) error {
+	freeze, err := getWalletFreeze(stub, wallet.ParticipantId)
+	if err != nil {
+		return err
+	}
+	if freeze != nil {
+		return fmt.Errorf("The wallet of the participant %d is frozen: %s", wallet.ParticipantId, freeze.Reason)
+	}
+
+	_, held, err := getActiveWalletHolds(stub, wallet.ParticipantId)
+	if err != nil {
+		return err
+	}
+	if held > 0 && wallet.UsableBalance-held < bitcircleAmount {
+		return fmt.Errorf("Insufficient balance on your wallet, %d Bitcircles are on hold", held)
+	}
+
+	return nil
+}
+
+// Usable Bitcircles of the wallet free of holds, none when it is frozen
+func getSpendableBitcircles(stub shim.ChaincodeStubInterface, wallet models.Wallet) (int, error) {
+	freeze, err := getWalletFreeze(stub, wallet.ParticipantId)
+	if err != nil || freeze != nil {
+		return 0, err
+	}
+
+	_, held, err := getActiveWalletHolds(stub, wallet.ParticipantId)
+	if err != nil {
+		return 0, err
+	}
+
+	if wallet.UsableBalance < held {
+		return 0, nil
+	}
+	return wallet.UsableBalance - held, nil
+}
+
+// The treasury is run by the platform and can not be frozen or held
+func (s *AuctionSmartContract) checkWalletRestriction(stub shim.ChaincodeStubInterface, participantID int, walletParticipantID int) (int, string) {
+	if !isPlatformCaller(stub, participantID) {
+		return http.StatusForbidden, "Only the platform can freeze or hold wallets"
+	}
+
+	if walletParticipantID == PlatformWalletId {
+		return http.StatusBadRequest, "The treasury wallet can not be frozen or held"
+	}
+
+	_, _, err := s.GetWallet(stub, walletParticipantID)
+	if err != nil {
+		return http.StatusNotFound, err.Error()
+	}
+
+	return 0, ""
+}
+
+func (s *AuctionSmartContract) FreezeWallet(stub shim.ChaincodeStubInterface, participantID int, walletParticipantID int, reason string) pb.Response {
+	fmt.Println("FreezeWallet Invoke")
+	status, message := s.checkWalletRestriction(stub, participantID, walletParticipantID)
+	if status != 0 {
+		return shim.Success(createErrorResponse(status, message))
+	}
+
+	if reason == "" {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "A reason is required to freeze a wallet"))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	freeze := models.WalletFreeze{
+		ParticipantID: walletParticipantID,
+		Reason:        reason,
+		FrozenBy:      participantID,
+		Date:          currentTime,
+	}
+
+	freezeKey, err := s.CreateCompositeKey(stub, EntityWalletFreeze, []string{fmt.Sprint(walletParticipantID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataFreeze, err := json.Marshal(freeze)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, freezeKey, dataFreeze)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataFreeze)
+}
+
+func (s *AuctionSmartContract) UnfreezeWallet(stub shim.ChaincodeStubInterface, participantID int, walletParticipantID int) pb.Response {
+	fmt.Println("UnfreezeWallet Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can freeze or hold wallets"))
+	}
+
+	freezeKey, err := s.CreateCompositeKey(stub, EntityWalletFreeze, []string{fmt.Sprint(walletParticipantID)})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	exists, err := s.EntityRecordExists(stub, freezeKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if !exists {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The wallet of the participant ", walletParticipantID, " is not frozen")))
+	}
+
+	err = stub.DelState(freezeKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(nil)
+}
+
+// A hold may keep more Bitcircles than the wallet has, the participant can only
+// spend what is above the held amount
+func (s *AuctionSmartContract) PlaceWalletHold(stub shim.ChaincodeStubInterface, participantID int, walletParticipantID int, bitcircleAmount int, reason string, expiresAt *time.Time) pb.Response {
+	fmt.Println("PlaceWalletHold Invoke")
+	status, message := s.checkWalletRestriction(stub, participantID, walletParticipantID)
+	if status != 0 {
+		return shim.Success(createErrorResponse(status, message))
+	}
+
+	if !(bitcircleAmount > 0) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "Bitcircle amount most be Higher than 0"))
+	}
+
+	if reason == "" {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "A reason is required to hold Bitcircles"))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if expiresAt != nil && !expiresAt.After(currentTime) {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, "The expiry of the hold most be in the future"))
+	}
+
+	hold := models.WalletHold{
+		ID:              stub.GetTxID(),
+		ParticipantID:   walletParticipantID,
+		BitcircleAmount: bitcircleAmount,
+		Reason:          reason,
+		State:           models.WalletHoldActive,
+		PlacedBy:        participantID,
+		PlacedAt:        currentTime,
+		ExpiresAt:       expiresAt,
+	}
+
+	holdKey, err := s.CreateCompositeKey(stub, EntityWalletHold, []string{fmt.Sprint(walletParticipantID), hold.ID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	dataHold, err := json.Marshal(hold)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, holdKey, dataHold)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataHold)
+}
+
+func (s *AuctionSmartContract) ReleaseWalletHold(stub shim.ChaincodeStubInterface, participantID int, walletParticipantID int, holdID string) pb.Response {
+	fmt.Println("ReleaseWalletHold Invoke")
+	if !isPlatformCaller(stub, participantID) {
+		return shim.Success(createErrorResponse(http.StatusForbidden, "Only the platform can freeze or hold wallets"))
+	}
+
+	holdKey, err := s.CreateCompositeKey(stub, EntityWalletHold, []string{fmt.Sprint(walletParticipantID), holdID})
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	holdJSON, err := stub.GetState(holdKey)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+	if holdJSON == nil {
+		return shim.Success(createErrorResponse(http.StatusNotFound, fmt.Sprint("The hold ", holdID, " of the wallet of the participant ", walletParticipantID, " does not exist")))
+	}
+
+	var hold models.WalletHold
+	err = json.Unmarshal(holdJSON, &hold)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	if hold.State != models.WalletHoldActive {
+		return shim.Success(createErrorResponse(http.StatusBadRequest, fmt.Sprint("The hold ", holdID, " is already ", hold.State)))
+	}
+
+	currentTime, err := getTxTime(stub)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	hold.State = models.WalletHoldReleased
+	hold.ReleasedAt = &currentTime
+
+	dataHold, err := json.Marshal(hold)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	_, err = s.UpsertEntityRecord(stub, holdKey, dataHold)
+	if err != nil {
+		return shim.Success(createErrorResponse(http.StatusInternalServerError, err.Error()))
+	}
+
+	return shim.Success(dataHold)
+}
+
+// ** -----------------------------------------------------
+// ** WALLET HOLDS (freezes and dispute holds set by the platform)
+// ** -> END
+// ** -----------------------------------------------------
@@ -0,0 +1,136 @@
+package micolec
+
+import (
+	"fmt"
+	"micolec/chaincode/models"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type walletHolds struct {
+	models.Wallet
+	Frozen *models.WalletFreeze `json:"frozen"`
+	Held   int                  `json:"held"`
+	Holds  []models.WalletHold  `json:"holds"`
+}
+
+func TestHoldIsActive(t *testing.T) {
+	expiresAt := testStartTime.Add(time.Hour)
+	tests := []struct {
+		name   string
+		hold   models.WalletHold
+		at     time.Time
+		active bool
+	}{
+		{"without expiry", models.WalletHold{State: models.WalletHoldActive}, testStartTime, true},
+		{"before its expiry", models.WalletHold{State: models.WalletHoldActive, ExpiresAt: &expiresAt}, testStartTime, true},
+		{"at its expiry", models.WalletHold{State: models.WalletHoldActive, ExpiresAt: &expiresAt}, expiresAt, false},
+		{"released", models.WalletHold{State: models.WalletHoldReleased}, testStartTime, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.active, holdIsActive(test.hold, test.at))
+		})
+	}
+}
+
+func TestWalletRestrictionChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		callerID int
+		args     []string
+		code     int
+	}{
+		{"freeze by another participant", 3, []string{"FreezeWallet", "3", "2", "Compromised account"}, http.StatusForbidden},
+		{"freeze signed by another participant", 3, []string{"FreezeWallet", "0", "2", "Compromised account"}, http.StatusForbidden},
+		{"freeze the treasury", PlatformWalletId, []string{"FreezeWallet", "0", "0", "Compromised account"}, http.StatusBadRequest},
+		{"freeze an unknown wallet", PlatformWalletId, []string{"FreezeWallet", "0", "4", "Compromised account"}, http.StatusNotFound},
+		{"freeze without reason", PlatformWalletId, []string{"FreezeWallet", "0", "2", ""}, http.StatusBadRequest},
+		{"unfreeze a wallet not frozen", PlatformWalletId, []string{"UnfreezeWallet", "0", "2"}, http.StatusNotFound},
+		{"hold by another participant", 3, []string{"PlaceWalletHold", "3", "2", "30", "Dispute"}, http.StatusForbidden},
+		{"hold nothing", PlatformWalletId, []string{"PlaceWalletHold", "0", "2", "0", "Dispute"}, http.StatusBadRequest},
+		{"hold without reason", PlatformWalletId, []string{"PlaceWalletHold", "0", "2", "30", ""}, http.StatusBadRequest},
+		{"hold expired already", PlatformWalletId, []string{"PlaceWalletHold", "0", "2", "30", "Dispute", testStartTime.Format(time.RFC3339)}, http.StatusBadRequest},
+		{"release an unknown hold", PlatformWalletId, []string{"ReleaseWalletHold", "0", "2", "tx1"}, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100})
+			c.as(test.callerID).fails(test.code, test.args...)
+
+			var wallet walletHolds
+			c.as(PlatformWalletId).ok(&wallet, "GetParticipantWalletById", "2")
+			require.Nil(t, wallet.Frozen)
+			require.Empty(t, wallet.Holds)
+		})
+	}
+}
+
+func TestFreezeWallet(t *testing.T) {
+	c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100})
+	c.ok(nil, "FreezeWallet", "0", "2", "Compromised account")
+
+	var wallet walletHolds
+	c.ok(&wallet, "GetParticipantWalletById", "2")
+	require.NotNil(t, wallet.Frozen)
+	require.Equal(t, "Compromised account", wallet.Frozen.Reason)
+
+	// A frozen wallet can still receive Bitcircles but not spend them
+	c.fails(http.StatusInternalServerError, "TransferBitcircles", "2", "3", "1", "false", "Shared delivery")
+	c.ok(nil, "TransferBitcircles", "3", "2", "10", "false", "Change")
+
+	c.ok(nil, "UnfreezeWallet", "0", "2")
+	c.ok(nil, "TransferBitcircles", "2", "3", "110", "false", "Shared delivery")
+	require.Zero(t, c.wallet(2).Balance)
+}
+
+func TestWalletHolds(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresIn time.Duration
+		release   bool
+		after     time.Duration
+		held      int
+		spendable int
+	}{
+		{"active hold", 0, false, 0, 60, 40},
+		{"hold before its expiry", 2 * time.Hour, false, time.Hour, 60, 40},
+		{"expired hold", time.Hour, false, 2 * time.Hour, 0, 100},
+		{"released hold", 0, true, 0, 0, 100},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestContract(t).wallets(map[int]int{2: 100, 3: 100})
+			args := []string{"PlaceWalletHold", "0", "2", "60", "Dispute on parcel 12"}
+			if test.expiresIn != 0 {
+				args = append(args, c.ledger.now.Add(test.expiresIn).Format(time.RFC3339))
+			}
+
+			var hold models.WalletHold
+			c.ok(&hold, args...)
+			require.Equal(t, models.WalletHoldActive, hold.State)
+			if test.release {
+				c.ok(&hold, "ReleaseWalletHold", "0", "2", hold.ID)
+				require.Equal(t, models.WalletHoldReleased, hold.State)
+				require.NotNil(t, hold.ReleasedAt)
+				c.fails(http.StatusBadRequest, "ReleaseWalletHold", "0", "2", hold.ID)
+			}
+			c.after(test.after)
+
+			var wallet walletHolds
+			c.ok(&wallet, "GetParticipantWalletById", "2")
+			require.Equal(t, test.held, wallet.Held)
+			require.Equal(t, 100, wallet.UsableBalance)
+
+			// Only the Bitcircles above the held amount can be spent
+			c.fails(http.StatusInternalServerError, "TransferBitcircles", "2", "3", fmt.Sprint(test.spendable+1), "false", "Shared delivery")
+			c.ok(nil, "TransferBitcircles", "2", "3", fmt.Sprint(test.spendable), "false", "Shared delivery")
+		})
+	}
+}